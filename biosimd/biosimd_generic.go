@@ -426,6 +426,64 @@ func ASCIIToSeq8(dst, src []byte) {
 	}
 }
 
+var asciiToSeq8IUPACTable = func() (t [256]byte) {
+	for i := range t {
+		t[i] = 15
+	}
+	for code, bases := range []string{"-", "A", "C", "M", "G", "R", "S", "V", "TU", "W", "Y", "H", "K", "D", "B", "N"} {
+		for i := 0; i < len(bases); i++ {
+			t[bases[i]] = byte(code)
+			if bases[i] != '-' {
+				t[bases[i]+'a'-'A'] = byte(code)
+			}
+		}
+	}
+	return
+}()
+
+// ASCIIToSeq8IUPACInplace is like ASCIIToSeq8Inplace, but converts all IUPAC
+// nucleotide codes, in either case, to their seq8 codes, the 4-bit codes of
+// BAM records, and gaps to 0:
+//   '-' -> 0
+//   'A' -> 1, 'C' -> 2, 'M' -> 3, 'G' -> 4, 'R' -> 5, 'S' -> 6, 'V' -> 7
+//   'T'/'U' -> 8, 'W' -> 9, 'Y' -> 10, 'H' -> 11, 'K' -> 12, 'D' -> 13
+//   'B' -> 14, 'N' -> 15
+//   anything else -> 15
+// A, C, G, T and N are converted as by ASCIIToSeq8Inplace.
+func ASCIIToSeq8IUPACInplace(main []byte) {
+	for pos, origByte := range main {
+		main[pos] = asciiToSeq8IUPACTable[origByte]
+	}
+}
+
+// Seq8IsValid returns true iff every byte of seq is a legal seq8 code, i.e.
+// has its high nibble clear.  All 16 low-nibble values are legal: they are the
+// "=ACMGRSVTWYHKDBN" codes of the BAM format, of which ASCIIToSeq8 produces
+// 1, 2, 4, 8 and 15.  Since seq8 stores one code per byte, it has the same
+// layout on big- and little-endian machines; this catches data that is not
+// seq8 at all, such as ASCII or packed sequences.
+func Seq8IsValid(seq []byte) bool {
+	for _, b := range seq {
+		if b > 15 {
+			return false
+		}
+	}
+	return true
+}
+
+// Seq8ToASCIIInplace is the inverse of ASCIIToSeq8IUPACInplace, except for
+// gaps: it converts each seq8 code to its character in the BAM format's
+// "=ACMGRSVTWYHKDBN", e.g. 1 -> 'A', 8 -> 'T' and 15 -> 'N', so it also
+// inverts ASCIIToSeq8Inplace for uppercase ACGTN.  Only the low nibble of each
+// byte is used.
+func Seq8ToASCIIInplace(main []byte) {
+	for pos, code := range main {
+		main[pos] = seq8ToASCIITable[code&15]
+	}
+}
+
+var seq8ToASCIITable = [16]byte{'=', 'A', 'C', 'M', 'G', 'R', 'S', 'V', 'T', 'W', 'Y', 'H', 'K', 'D', 'B', 'N'}
+
 var asciiTo2bitTable = [...]byte{
 	0, 0, 0, 1, 3, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0,
 	0, 0, 0, 1, 3, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0,
@@ -7,6 +7,7 @@ package biosimd_test
 import (
 	"bytes"
 	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/Schaudge/grailbase/simd"
@@ -535,6 +536,98 @@ func TestASCIIToSeq8(t *testing.T) {
 	}
 }
 
+func TestSeq8Layout(t *testing.T) {
+	// The seq8 encoding of a sequence must not depend on the byte order or
+	// word size of the machine, on either the SIMD or the scalar path.
+	src := []byte("ACGTNacgtnXACGTACGTACGTACGTACGT")
+	want := []byte{1, 2, 4, 8, 15, 1, 2, 4, 8, 15, 15, 1, 2, 4, 8, 1, 2, 4, 8, 1, 2, 4, 8, 1, 2, 4, 8, 1, 2, 4, 8}
+	for _, n := range []int{5, 16, 17, len(src)} {
+		dst := make([]byte, n)
+		biosimd.ASCIIToSeq8(dst, src[:n])
+		if !bytes.Equal(dst, want[:n]) {
+			t.Fatalf("ASCIIToSeq8(%q) = %v, want %v", src[:n], dst, want[:n])
+		}
+		copy(dst, src)
+		biosimd.ASCIIToSeq8Inplace(dst)
+		if !bytes.Equal(dst, want[:n]) {
+			t.Fatalf("ASCIIToSeq8Inplace(%q) = %v, want %v", src[:n], dst, want[:n])
+		}
+		if !biosimd.Seq8IsValid(dst) {
+			t.Fatalf("Seq8IsValid(%v) = false", dst)
+		}
+	}
+	// Packing puts the first base in the high nibble.
+	packed := make([]byte, 3)
+	biosimd.PackSeq(packed, want[:5])
+	if !bytes.Equal(packed, []byte{0x12, 0x48, 0xf0}) {
+		t.Fatalf("PackSeq(%v) = %x", want[:5], packed)
+	}
+}
+
+func TestASCIIToSeq8IUPAC(t *testing.T) {
+	const codes = "-ACMGRSVTWYHKDBN"
+	for i := 0; i < 256; i++ {
+		b := byte(i)
+		want := byte(15)
+		if j := strings.IndexByte(codes, bytes.ToUpper([]byte{b})[0]); j >= 0 {
+			want = byte(j)
+		} else if b == 'U' || b == 'u' {
+			want = 8
+		}
+		got := []byte{b}
+		biosimd.ASCIIToSeq8IUPACInplace(got)
+		if got[0] != want {
+			t.Errorf("ASCIIToSeq8IUPACInplace(%q) = %d, want %d", b, got[0], want)
+		}
+	}
+	// ACGTN are encoded as by ASCIIToSeq8Inplace.
+	seq := []byte("ACGTNacgtnXx")
+	want := append([]byte(nil), seq...)
+	biosimd.ASCIIToSeq8Inplace(want)
+	biosimd.ASCIIToSeq8IUPACInplace(seq)
+	if !bytes.Equal(seq, want) {
+		t.Errorf("got %v, want %v", seq, want)
+	}
+}
+
+func TestSeq8IsValid(t *testing.T) {
+	for _, tc := range []struct {
+		seq  []byte
+		want bool
+	}{
+		{nil, true},
+		{[]byte{0, 1, 2, 4, 8, 15, 3, 9}, true},
+		{[]byte{1, 2, 16}, false},
+		{[]byte("ACGT"), false},
+		{[]byte{0x12, 0x48}, false},
+	} {
+		if got := biosimd.Seq8IsValid(tc.seq); got != tc.want {
+			t.Errorf("Seq8IsValid(%v) = %v, want %v", tc.seq, got, tc.want)
+		}
+	}
+}
+
+func TestSeq8ToASCII(t *testing.T) {
+	const iupac = "=ACMGRSVTWYHKDBN"
+	seq := []byte(strings.ToLower(iupac[1:]) + iupac[1:])
+	biosimd.ASCIIToSeq8IUPACInplace(seq)
+	biosimd.Seq8ToASCIIInplace(seq)
+	if got, want := string(seq), iupac[1:]+iupac[1:]; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	seq = []byte("ACGTNacgtnXR")
+	biosimd.ASCIIToSeq8Inplace(seq)
+	biosimd.Seq8ToASCIIInplace(seq)
+	if got, want := string(seq), "ACGTNACGTNNN"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	seq = []byte{0, 0x11, 0xf8}
+	biosimd.Seq8ToASCIIInplace(seq)
+	if got, want := string(seq), "=AT"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
 /*
 Benchmark results:
   MacBook Pro (15-inch, 2016)
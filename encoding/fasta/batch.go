@@ -0,0 +1,202 @@
+package fasta
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Schaudge/grailbase/unsafe"
+)
+
+// batchMaxGap is the largest gap, in bases, between consecutive ranges that
+// GetBatch fetches with a single Get.
+const batchMaxGap = 4096
+
+// GetBatch returns the bases of each [start, end) range of the given sequence,
+// in the order of ranges. Consecutive ranges that overlap or lie close together
+// are fetched with a single Get call, which reduces the number of reads made by
+// an Indexed for clustered queries.
+func GetBatch(f Fasta, seqName string, ranges [][2]uint64) ([]string, error) {
+	results, _, err := GetBatchContext(context.Background(), f, seqName, ranges)
+	return results, err
+}
+
+// GetBatchContext is like GetBatch, but checks ctx before each group of
+// coalesced ranges. It returns the number n of ranges fetched; results[:n] are
+// filled even if an error, including ctx.Err(), is returned.
+func GetBatchContext(ctx context.Context, f Fasta, seqName string, ranges [][2]uint64) ([]string, int, error) {
+	if err := checkUnpacked(f, seqName, "GetBatch"); err != nil {
+		return nil, 0, err
+	}
+	results := make([]string, len(ranges))
+	for i, r := range ranges {
+		if err := checkRange(r[0], r[1]); err != nil {
+			return nil, 0, fmt.Errorf("range %d: %w", i, err)
+		}
+	}
+	for i := 0; i < len(ranges); {
+		if err := ctx.Err(); err != nil {
+			return results, i, err
+		}
+		// Extend the group [i, j) while the next range is close to the union of
+		// the group, and the union stays small enough.
+		start, end := ranges[i][0], ranges[i][1]
+		j := i + 1
+		for ; j < len(ranges); j++ {
+			newStart, newEnd := start, end
+			if r := ranges[j]; r[0] < newStart {
+				newStart = r[0]
+			}
+			if r := ranges[j]; r[1] > newEnd {
+				newEnd = r[1]
+			}
+			if ranges[j][0] > end+batchMaxGap || ranges[j][1]+batchMaxGap < start ||
+				newEnd-newStart > streamChunkSize {
+				break
+			}
+			start, end = newStart, newEnd
+		}
+		seq, err := f.Get(seqName, start, end)
+		if err != nil {
+			return results, i, err
+		}
+		for ; i < j; i++ {
+			results[i] = seq[ranges[i][0]-start : ranges[i][1]-start]
+		}
+	}
+	return results, len(ranges), nil
+}
+
+// RefBasesAt returns the base at each of the given positions of the given
+// sequence, in the encoding of f and in the order of positions, e.g., to check
+// the REF alleles of variant sites. Positions need not be sorted. They are
+// visited in sorted order, and nearby ones are fetched with a single Get call,
+// as by GetBatch, which is much faster than a Get per position for dense
+// sites. The TwoBit encoding, which cannot represent single bases, is not
+// supported.
+func RefBasesAt(f Fasta, seqName string, positions []uint64) ([]byte, error) {
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnpacked(f, seqName, "RefBasesAt"); err != nil {
+		return nil, err
+	}
+	order := make([]int, len(positions))
+	for i, pos := range positions {
+		if pos >= seqLen {
+			return nil, fmt.Errorf("position %d is past end of sequence %s: %d", pos, seqName, seqLen)
+		}
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return positions[order[i]] < positions[order[j]] })
+	bases := make([]byte, len(positions))
+	for i := 0; i < len(order); {
+		start, end := positions[order[i]], positions[order[i]]+1
+		j := i + 1
+		for ; j < len(order); j++ {
+			pos := positions[order[j]]
+			if pos > end+batchMaxGap || pos+1-start > streamChunkSize {
+				break
+			}
+			end = pos + 1
+		}
+		seq, err := f.Get(seqName, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for ; i < j; i++ {
+			bases[order[i]] = seq[positions[order[i]]-start]
+		}
+	}
+	return bases, nil
+}
+
+// MergeRegions returns the union of the given [start, end) ranges as a list of
+// disjoint ranges in increasing order. Overlapping and adjacent ranges are
+// merged, and empty ranges are dropped. ranges is not modified.
+func MergeRegions(ranges [][2]uint64) [][2]uint64 {
+	sorted := make([][2]uint64, 0, len(ranges))
+	for _, r := range ranges {
+		if r[0] < r[1] {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+	var merged [][2]uint64
+	for _, r := range sorted {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			if r[1] > merged[n-1][1] {
+				merged[n-1][1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// GetBatchMerged is like GetBatch, but fetches the merged cover of ranges, as
+// computed by MergeRegions, so that bases covered by several ranges are read
+// once. It returns the cover, the bases of each of its ranges, and for each
+// input range, the index in cover of the range containing it. The bases of
+// ranges[i] are thus seqs[j][ranges[i][0]-cover[j][0]:ranges[i][1]-cover[j][0]]
+// for j = coverIndex[i].
+func GetBatchMerged(f Fasta, seqName string, ranges [][2]uint64) (cover [][2]uint64, seqs []string, coverIndex []int, err error) {
+	if err := checkUnpacked(f, seqName, "GetBatchMerged"); err != nil {
+		return nil, nil, nil, err
+	}
+	for i, r := range ranges {
+		if err := checkRange(r[0], r[1]); err != nil {
+			return nil, nil, nil, fmt.Errorf("range %d: %w", i, err)
+		}
+	}
+	cover = MergeRegions(ranges)
+	seqs = make([]string, len(cover))
+	for i, r := range cover {
+		if seqs[i], err = f.Get(seqName, r[0], r[1]); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	coverIndex = make([]int, len(ranges))
+	for i, r := range ranges {
+		// The last cover range starting at or before r.
+		coverIndex[i] = sort.Search(len(cover), func(j int) bool { return cover[j][0] > r[0] }) - 1
+	}
+	return cover, seqs, coverIndex, nil
+}
+
+// GetConcat returns the concatenation, in the order of ranges, of the bases of
+// each range of the given sequence, reverse-complemented for ranges on the
+// reverse strand, e.g., to assemble a transcript from its exons. Ranges are
+// fetched as by GetBatch. Reverse-complementing is only supported for the
+// ASCII encodings of f.
+func GetConcat(f Fasta, seqName string, ranges []StrandedRange) (string, error) {
+	if err := checkUnpacked(f, seqName, "GetConcat"); err != nil {
+		return "", err
+	}
+	bounds := make([][2]uint64, len(ranges))
+	total := 0
+	for i, r := range ranges {
+		bounds[i] = [2]uint64{r.Start, r.End}
+		if r.End > r.Start {
+			total += int(r.End - r.Start)
+		}
+	}
+	seqs, err := GetBatch(f, seqName, bounds)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, total)
+	pos := 0
+	for i, seq := range seqs {
+		dst := buf[pos : pos+len(seq)]
+		if ranges[i].Reverse {
+			ReverseComplement(dst, unsafe.StringToBytes(seq))
+		} else {
+			copy(dst, seq)
+		}
+		pos += len(seq)
+	}
+	return unsafe.BytesToString(buf), nil
+}
@@ -0,0 +1,144 @@
+package fasta_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+// cancelingFasta cancels a context after a given number of Get calls.
+type cancelingFasta struct {
+	fasta.Fasta
+	calls, cancelAfter int
+	cancel             func()
+}
+
+func (f *cancelingFasta) Get(seqName string, start, end uint64) (string, error) {
+	f.calls++
+	if f.calls == f.cancelAfter {
+		f.cancel()
+	}
+	return f.Fasta.Get(seqName, start, end)
+}
+
+func TestGetBatch(t *testing.T) {
+	seq := strings.Repeat("ACGTTGCA", 2000)
+	base, err := fasta.New(strings.NewReader(">s\n" + seq + "\n"))
+	assert.NoError(t, err)
+	ranges := [][2]uint64{{0, 10}, {5, 20}, {100, 110}, {15000, 15010}, {14990, 15005}, {3, 4}}
+
+	fa := &cancelingFasta{Fasta: base, cancel: func() {}}
+	results, err := fasta.GetBatch(fa, "s", ranges)
+	assert.NoError(t, err)
+	for i, r := range ranges {
+		assert.EQ(t, results[i], seq[r[0]:r[1]])
+	}
+	// {0,10},{5,20},{100,110} are coalesced, as are {15000,15010},{14990,15005}.
+	assert.EQ(t, fa.calls, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fa = &cancelingFasta{Fasta: base, cancelAfter: 1, cancel: cancel}
+	results, n, err := fasta.GetBatchContext(ctx, fa, "s", ranges)
+	assert.EQ(t, err, context.Canceled)
+	assert.EQ(t, n, 3)
+	for i, r := range ranges[:n] {
+		assert.EQ(t, results[i], seq[r[0]:r[1]])
+	}
+
+	_, n, err = fasta.GetBatchContext(context.Background(), base, "s", [][2]uint64{{0, 1}, {16000, 16001}})
+	assert.NotNil(t, err)
+	assert.EQ(t, n, 1)
+	_, err = fasta.GetBatch(base, "s", [][2]uint64{{5, 5}})
+	assert.Regexp(t, err, "start must be less than end")
+}
+
+func TestRefBasesAt(t *testing.T) {
+	seq := strings.Repeat("ACGTTGCA", 2000)
+	base, err := fasta.New(strings.NewReader(">s\n" + seq + "\n"))
+	assert.NoError(t, err)
+	fa := &cancelingFasta{Fasta: base, cancel: func() {}}
+	positions := []uint64{15000, 3, 0, 9500, 3, 15999, 5000, 100}
+	bases, err := fasta.RefBasesAt(fa, "s", positions)
+	assert.NoError(t, err)
+	for i, pos := range positions {
+		assert.EQ(t, bases[i], seq[pos], "position %d", pos)
+	}
+	// {0, 3, 3, 100}, {5000}, {9500} and {15000, 15999} are fetched together.
+	assert.EQ(t, fa.calls, 4)
+
+	bases, err = fasta.RefBasesAt(base, "s", nil)
+	assert.NoError(t, err)
+	assert.EQ(t, len(bases), 0)
+	_, err = fasta.RefBasesAt(base, "s", []uint64{1, 16000})
+	assert.Regexp(t, err, "position 16000 is past end")
+	_, err = fasta.RefBasesAt(base, "x", []uint64{1})
+	assert.NotNil(t, err)
+
+	twoBit, err := fasta.New(strings.NewReader(">s\n"+seq+"\n"), fasta.OptEncoding(fasta.TwoBit))
+	assert.NoError(t, err)
+	_, err = fasta.RefBasesAt(twoBit, "s", []uint64{50, 90})
+	assert.Regexp(t, err, "RefBasesAt does not support the TwoBit encoding")
+}
+
+func TestGetConcat(t *testing.T) {
+	base, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	fa, stats := fasta.NewInstrumented(base)
+	// seq1 is AcGTACGTACGT.
+	seq, err := fasta.GetConcat(fa, "seq1", []fasta.StrandedRange{
+		{Start: 0, End: 3},
+		{Start: 8, End: 12, Reverse: true},
+		{Start: 1, End: 2, Reverse: true},
+	})
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "AcG"+"ACGT"+"g")
+	// The ranges are close together, so a single Get suffices.
+	assert.EQ(t, stats.Calls(), map[string]int64{"seq1": 1})
+
+	seq, err = fasta.GetConcat(fa, "seq1", nil)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "")
+	_, err = fasta.GetConcat(fa, "seq1", []fasta.StrandedRange{{Start: 0, End: 3}, {Start: 5, End: 4}})
+	assert.Regexp(t, err, "range 1: reversed range")
+	_, err = fasta.GetConcat(fa, "seq1", []fasta.StrandedRange{{Start: 10, End: 13}})
+	assert.NotNil(t, err)
+}
+
+func TestMergeRegions(t *testing.T) {
+	for _, tt := range []struct {
+		ranges, want [][2]uint64
+	}{
+		{nil, nil},
+		{[][2]uint64{{3, 3}}, nil},
+		{[][2]uint64{{5, 8}, {0, 2}}, [][2]uint64{{0, 2}, {5, 8}}},
+		{[][2]uint64{{5, 8}, {0, 5}, {9, 10}}, [][2]uint64{{0, 8}, {9, 10}}},
+		{[][2]uint64{{0, 10}, {2, 4}, {9, 12}, {12, 13}, {20, 30}, {25, 26}}, [][2]uint64{{0, 13}, {20, 30}}},
+	} {
+		assert.EQ(t, fasta.MergeRegions(tt.ranges), tt.want, "%v", tt.ranges)
+	}
+}
+
+func TestGetBatchMerged(t *testing.T) {
+	base, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	fa, stats := fasta.NewInstrumented(base)
+	ranges := [][2]uint64{{6, 9}, {0, 3}, {1, 4}, {8, 10}, {2, 3}}
+	cover, seqs, coverIndex, err := fasta.GetBatchMerged(fa, "seq1", ranges)
+	assert.NoError(t, err)
+	assert.EQ(t, cover, [][2]uint64{{0, 4}, {6, 10}})
+	assert.EQ(t, seqs, []string{"AcGT", "GTAC"})
+	assert.EQ(t, coverIndex, []int{1, 0, 0, 1, 0})
+	assert.EQ(t, stats.BasesRead(), uint64(8))
+	for i, r := range ranges {
+		j := coverIndex[i]
+		assert.EQ(t, seqs[j][r[0]-cover[j][0]:r[1]-cover[j][0]], "AcGTACGTACGT"[r[0]:r[1]])
+	}
+
+	_, _, _, err = fasta.GetBatchMerged(fa, "seq1", [][2]uint64{{0, 1}, {4, 4}})
+	assert.Regexp(t, err, "range 1: empty range")
+	_, _, _, err = fasta.GetBatchMerged(fa, "seq1", [][2]uint64{{10, 13}})
+	assert.NotNil(t, err)
+}
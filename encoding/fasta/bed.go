@@ -0,0 +1,115 @@
+package fasta
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Schaudge/grailbase/tsv"
+)
+
+// WriteExtentsBED writes a BED file listing the full extent of each sequence in
+// f, i.e., one "<name>\t0\t<length>" line per sequence, in SeqNames() order.
+func WriteExtentsBED(f Fasta, out io.Writer) error {
+	w := tsv.NewWriter(out)
+	for _, seqName := range f.SeqNames() {
+		seqLen, err := f.Len(seqName)
+		if err != nil {
+			return err
+		}
+		w.WriteString(seqName)
+		w.WriteInt64(0)
+		w.WriteInt64(int64(seqLen))
+		if err := w.EndLine(); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// bedRegion is a region read from a BED file.
+type bedRegion struct {
+	Region
+	line int // 1-based line number in the BED file.
+	rank int // position of the sequence in f.SeqNames().
+}
+
+// GetBED reads the regions listed in a BED file, and calls fn with each of
+// them and its bases, as returned by f.Get. BED coordinates are 0-based and
+// half-open, like those of this package; columns after the third are ignored,
+// as are blank lines and "#", "track" and "browser" header lines. The regions
+// are fetched in the order of their sequences in f.SeqNames(), i.e. of their
+// offsets in the FASTA file for an Indexed, and then of their starts, for
+// locality; regions of zero length are passed with an empty sequence. All of
+// the BED file is parsed before any region is fetched, so a malformed line or
+// an unknown sequence is reported, with its line number, before fn is called.
+// GetBED stops at the first error returned by fn, and returns it.
+func GetBED(f Fasta, bed io.Reader, fn func(region Region, seq string) error) error {
+	rank := make(map[string]int)
+	for i, seqName := range f.SeqNames() {
+		rank[seqName] = i
+	}
+	var regions []bedRegion
+	scanner := bufio.NewScanner(bed)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(strings.TrimSpace(line)) == 0 || line[0] == '#' ||
+			strings.HasPrefix(line, "track") || strings.HasPrefix(line, "browser") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			return fmt.Errorf("BED line %d: expected at least 3 tab-separated columns, got %d", lineNum, len(fields))
+		}
+		r := bedRegion{Region: Region{SeqName: fields[0]}, line: lineNum}
+		var err error
+		if r.Start, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return fmt.Errorf("BED line %d: invalid start: %v", lineNum, err)
+		}
+		if r.End, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+			return fmt.Errorf("BED line %d: invalid end: %v", lineNum, err)
+		}
+		if r.End < r.Start {
+			return fmt.Errorf("BED line %d: end %d is before start %d", lineNum, r.End, r.Start)
+		}
+		seqLen, err := f.Len(r.SeqName)
+		if err != nil {
+			return fmt.Errorf("BED line %d: %w", lineNum, err)
+		}
+		if r.End > seqLen {
+			return fmt.Errorf("BED line %d: end %d is past the end of sequence %s of length %d", lineNum, r.End, r.SeqName, seqLen)
+		}
+		var ok bool
+		if r.rank, ok = rank[r.SeqName]; !ok {
+			// The name is accepted by f, but is not one of its SeqNames,
+			// e.g. due to OptNormalizeName.
+			r.rank = len(rank)
+		}
+		regions = append(regions, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		if regions[i].rank != regions[j].rank {
+			return regions[i].rank < regions[j].rank
+		}
+		return regions[i].Start < regions[j].Start
+	})
+	for _, r := range regions {
+		var seq string
+		if r.End > r.Start {
+			var err error
+			if seq, err = f.Get(r.SeqName, r.Start, r.End); err != nil {
+				return fmt.Errorf("BED line %d: %w", r.line, err)
+			}
+		}
+		if err := fn(r.Region, seq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package fasta_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestWriteExtentsBED(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	assert.NoError(t, fasta.WriteExtentsBED(fa, &out))
+	assert.EQ(t, out.String(), "seq1\t0\t12\nseq2\t0\t8\n")
+}
+
+func TestGetBED(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	const bed = "track name=test\n# comment\nseq2\t4\t8\tx\t0\t+\nseq1\t5\t7\n\nseq1\t0\t3\r\nseq2\t2\t2\n"
+	var got []string
+	assert.NoError(t, fasta.GetBED(fa, strings.NewReader(bed), func(r fasta.Region, seq string) error {
+		got = append(got, r.String()+" "+seq)
+		return nil
+	}))
+	assert.EQ(t, got, []string{"seq1:0-3 AcG", "seq1:5-7 CG", "seq2:2-2 ", "seq2:4-8 ACGT"})
+
+	errStop := errors.New("stop")
+	n := 0
+	err = fasta.GetBED(fa, strings.NewReader(bed), func(fasta.Region, string) error {
+		n++
+		return errStop
+	})
+	assert.True(t, err == errStop)
+	assert.EQ(t, n, 1)
+
+	for _, tt := range []struct {
+		bed, err string
+	}{
+		{"seq1\t0\t3\nseq1 0 3\n", "BED line 2: expected at least 3 tab-separated columns"},
+		{"seq1\tx\t3\n", "BED line 1: invalid start"},
+		{"seq1\t0\t-3\n", "BED line 1: invalid end"},
+		{"seq1\t4\t3\n", "BED line 1: end 3 is before start 4"},
+		{"seq1\t0\t3\nseq3\t0\t3\n", "BED line 2: sequence not found"},
+		{"seq2\t0\t9\n", "BED line 1: end 9 is past the end of sequence seq2 of length 8"},
+	} {
+		called := false
+		err := fasta.GetBED(fa, strings.NewReader(tt.bed), func(fasta.Region, string) error {
+			called = true
+			return nil
+		})
+		assert.Regexp(t, err, tt.err)
+		assert.False(t, called)
+	}
+}
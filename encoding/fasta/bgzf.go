@@ -0,0 +1,155 @@
+package fasta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/Schaudge/hts/bgzf"
+)
+
+// scanBGZFBlocks decompresses the BGZF file r to locate each of its non-empty
+// blocks.
+func scanBGZFBlocks(r io.ReadSeeker) ([]compressedBlock, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	bg, err := bgzf.NewReader(r, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer bg.Close()
+	var (
+		blocks []compressedBlock
+		uOff   uint64
+		buf    = make([]byte, 1)
+	)
+	for {
+		// Read skips empty blocks, so the byte read is the first of the next
+		// non-empty block.
+		if _, err := bg.Read(buf[:1]); err == io.EOF {
+			return blocks, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("block after uncompressed offset %d: %v", uOff, err)
+		}
+		cOff := bg.LastChunk().Begin.File
+		n := bg.BlockLen()
+		if n > len(buf) {
+			buf = make([]byte, n)
+		}
+		if _, err := io.ReadFull(bg, buf[:n]); err != nil {
+			return nil, fmt.Errorf("block at offset %d: %v", cOff, err)
+		}
+		blocks = append(blocks, compressedBlock{cOff: uint64(cOff), uOff: uOff})
+		uOff += uint64(1 + n)
+	}
+}
+
+// readGZI parses a BGZF index (*.gzi), as produced by "bgzip -r".
+func readGZI(r io.Reader) ([]compressedBlock, error) {
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("reading gzi: %v", err)
+	}
+	// The first block, at offset 0, is implicit.
+	blocks := []compressedBlock{{}}
+	for i := uint64(0); i < n; i++ {
+		var b [2]uint64
+		if err := binary.Read(r, binary.LittleEndian, &b); err != nil {
+			return nil, fmt.Errorf("reading gzi: %v", err)
+		}
+		blocks = append(blocks, compressedBlock{cOff: b[0], uOff: b[1]})
+	}
+	return blocks, nil
+}
+
+// writeGZI writes blocks in the BGZF index (*.gzi) format.
+func writeGZI(w io.Writer, blocks []compressedBlock) error {
+	buf := make([]uint64, 0, 1+2*len(blocks))
+	buf = append(buf, 0)
+	for _, b := range blocks {
+		if b.cOff == 0 {
+			continue
+		}
+		buf = append(buf, b.cOff, b.uOff)
+	}
+	buf[0] = uint64(len(buf)-1) / 2
+	return binary.Write(w, binary.LittleEndian, buf)
+}
+
+// bgzfDecoder decompresses the blocks of a BGZF file for a blockReader.
+type bgzfDecoder struct {
+	bg *bgzf.Reader // Blocked, so that reads stop at the end of each block.
+}
+
+// decode decompresses the block b into dst.
+func (d *bgzfDecoder) decode(b compressedBlock, dst []byte) ([]byte, error) {
+	if err := d.bg.Seek(bgzf.Offset{File: int64(b.cOff)}); err != nil {
+		return nil, fmt.Errorf("block at offset %d: %v", b.cOff, err)
+	}
+	n := d.bg.BlockLen()
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	if _, err := io.ReadFull(d.bg, dst); err != nil {
+		return nil, fmt.Errorf("block at offset %d: %v", b.cOff, err)
+	}
+	return dst, nil
+}
+
+// newIndexedBGZF creates a Fasta for the BGZF file fasta, whose non-empty
+// blocks are given, like NewIndexedBGZF. Close releases the BGZF reader.
+func newIndexedBGZF(fasta io.ReadSeeker, fai io.Reader, blocks []compressedBlock, parsedOpts opts) (Indexed, error) {
+	entries, err := parseIndex(fai, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fasta.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	bg, err := bgzf.NewReader(fasta, 1)
+	if err != nil {
+		return nil, err
+	}
+	bg.Blocked = true
+	d := &bgzfDecoder{bg: bg}
+	f, err := newLazyIndexed(newBlockReader(blocks, d.decode), entries, parsedOpts)
+	if err != nil {
+		bg.Close()
+		return nil, err
+	}
+	f.release = func() { bg.Close() }
+	return f, nil
+}
+
+// NewIndexedBGZF creates a new Fasta like NewIndexed, for a FASTA file
+// compressed with bgzip. gzi is the BGZF index (*.gzi) of the file, as
+// produced by "bgzip -r" or NewIndexedBGZFAutoIndex, and fai is the index of
+// the uncompressed FASTA, as produced by "samtools faidx". Blocks are
+// decompressed with github.com/Schaudge/hts/bgzf.
+func NewIndexedBGZF(fasta io.ReadSeeker, fai, gzi io.Reader, opts ...Opt) (Indexed, error) {
+	blocks, err := readGZI(gzi)
+	if err != nil {
+		return nil, err
+	}
+	return newIndexedBGZF(fasta, fai, blocks, makeOpts(opts...))
+}
+
+// NewIndexedBGZFAutoIndex is like NewIndexedBGZF, but builds the BGZF index in
+// memory by decompressing fasta once, so no *.gzi file is needed. To avoid
+// repeating this scan, pass OptGZIWriter to save the built index for later use
+// with NewIndexedBGZF.
+func NewIndexedBGZFAutoIndex(fasta io.ReadSeeker, fai io.Reader, opts ...Opt) (Indexed, error) {
+	blocks, err := scanBGZFBlocks(fasta)
+	if err != nil {
+		return nil, err
+	}
+	parsedOpts := makeOpts(opts...)
+	if w := parsedOpts.GZIWriter; w != nil {
+		if err := writeGZI(w, blocks); err != nil {
+			return nil, err
+		}
+	}
+	return newIndexedBGZF(fasta, fai, blocks, parsedOpts)
+}
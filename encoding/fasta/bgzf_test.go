@@ -0,0 +1,78 @@
+package fasta_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/Schaudge/hts/bgzf"
+	"github.com/grailbio/testutil/assert"
+)
+
+// bgzipFASTA compresses data with BGZF, ending a block after every blockSize
+// bytes.
+func bgzipFASTA(t *testing.T, data string, blockSize int) []byte {
+	var buf bytes.Buffer
+	w := bgzf.NewWriter(&buf, 1)
+	for len(data) > 0 {
+		n := blockSize
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err := w.Write([]byte(data[:n]))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Flush())
+		data = data[n:]
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestBGZF(t *testing.T) {
+	compressed := bgzipFASTA(t, fastaData, 7)
+	var gzi bytes.Buffer
+	auto, err := fasta.NewIndexedBGZFAutoIndex(bytes.NewReader(compressed), strings.NewReader(fastaIndex),
+		fasta.OptGZIWriter(&gzi))
+	assert.NoError(t, err)
+	withGZI, err := fasta.NewIndexedBGZF(bytes.NewReader(compressed), strings.NewReader(fastaIndex), &gzi)
+	assert.NoError(t, err)
+	for _, fa := range []fasta.Fasta{auto, withGZI} {
+		for _, tt := range []struct {
+			seq        string
+			start, end uint64
+			want       string
+		}{
+			{"seq1", 0, 12, "AcGTACGTACGT"},
+			{"seq1", 4, 7, "ACG"},
+			{"seq2", 0, 8, "ACGTACGT"},
+			{"seq2", 7, 8, "T"},
+		} {
+			got, err := fa.Get(tt.seq, tt.start, tt.end)
+			assert.NoError(t, err)
+			assert.EQ(t, got, tt.want)
+		}
+	}
+
+	// Full-size blocks, as written by bgzip.
+	seq := strings.Repeat("ACGTTGCAAC", 20000)
+	data := ">s\n" + seq + "\n"
+	compressed = bgzipFASTA(t, data, bgzf.BlockSize)
+	gzi.Reset()
+	auto, err = fasta.NewIndexedBGZFAutoIndex(bytes.NewReader(compressed), strings.NewReader("s\t200000\t3\t200000\t200001\n"),
+		fasta.OptGZIWriter(&gzi))
+	assert.NoError(t, err)
+	withGZI, err = fasta.NewIndexedBGZF(bytes.NewReader(compressed), strings.NewReader("s\t200000\t3\t200000\t200001\n"), &gzi)
+	assert.NoError(t, err)
+	for _, fa := range []fasta.Indexed{auto, withGZI} {
+		for _, r := range [][2]uint64{{0, 10}, {65270, 65290}, {199990, 200000}, {1000, 150000}} {
+			got, err := fa.Get("s", r[0], r[1])
+			assert.NoError(t, err)
+			assert.EQ(t, got, seq[r[0]:r[1]])
+		}
+		assert.NoError(t, fa.Close())
+	}
+
+	_, err = fasta.NewIndexedBGZFAutoIndex(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.Regexp(t, err, "gzip: invalid header")
+}
@@ -0,0 +1,86 @@
+package fasta
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// compressedBlock records the location of one independently compressed block
+// of a file, such as a BGZF block or a zstd frame.
+type compressedBlock struct {
+	cOff uint64 // offset of the block in the compressed file.
+	uOff uint64 // offset of the block's data in the uncompressed stream.
+	// cSize is the size of the compressed block, if recorded by the index.
+	cSize uint64
+}
+
+// blockReader is an io.ReadSeeker over the uncompressed contents of a file
+// consisting of independently compressed blocks, given their locations.
+type blockReader struct {
+	blocks []compressedBlock // sorted by uOff.
+	off    int64             // current offset in the uncompressed stream.
+	// decode decompresses a block, reusing dst if it is large enough.
+	decode func(b compressedBlock, dst []byte) ([]byte, error)
+
+	cur  int    // index of the block in data, or -1.
+	data []byte // uncompressed contents of blocks[cur].
+}
+
+func newBlockReader(blocks []compressedBlock, decode func(compressedBlock, []byte) ([]byte, error)) *blockReader {
+	return &blockReader{blocks: blocks, decode: decode, cur: -1}
+}
+
+// Seek implements io.Seeker. Only io.SeekStart and io.SeekCurrent are
+// supported.
+func (b *blockReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.off
+	default:
+		return b.off, fmt.Errorf("blockReader.Seek: unsupported whence %d", whence)
+	}
+	if offset < 0 {
+		return b.off, fmt.Errorf("blockReader.Seek: negative offset %d", offset)
+	}
+	b.off = offset
+	return b.off, nil
+}
+
+// Read implements io.Reader. Unlike most readers, it fills p unless it
+// reaches the end of the stream.
+func (b *blockReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		i := sort.Search(len(b.blocks), func(i int) bool { return b.blocks[i].uOff > uint64(b.off) }) - 1
+		if i < 0 {
+			return n, io.EOF
+		}
+		if err := b.load(i); err != nil {
+			return n, err
+		}
+		pos := uint64(b.off) - b.blocks[i].uOff
+		if pos >= uint64(len(b.data)) {
+			return n, io.EOF
+		}
+		copied := copy(p[n:], b.data[pos:])
+		n += copied
+		b.off += int64(copied)
+	}
+	return n, nil
+}
+
+// load decompresses blocks[i] into b.data, unless it is already there.
+func (b *blockReader) load(i int) error {
+	if b.cur == i {
+		return nil
+	}
+	b.cur = -1
+	data, err := b.decode(b.blocks[i], b.data)
+	if err != nil {
+		return err
+	}
+	b.data, b.cur = data, i
+	return nil
+}
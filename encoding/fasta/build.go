@@ -0,0 +1,70 @@
+package fasta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KnownBuilds maps the names of common reference assemblies to the lengths of
+// some of their chromosomes, which are enough to tell the assemblies apart.
+// Chromosome names omit any "chr" prefix. DetectBuild matches against it;
+// callers may add entries.
+var KnownBuilds = map[string]map[string]uint64{
+	"GRCh37": {"1": 249250621, "2": 243199373, "X": 155270560},
+	"GRCh38": {"1": 248956422, "2": 242193529, "X": 156040895},
+	"mm10":   {"1": 195471971, "2": 182113224, "X": 171031299},
+	"mm39":   {"1": 195154279, "2": 181755017, "X": 169476592},
+}
+
+// LengthSignature returns a stable hash of the names and lengths of the
+// sequences of f, independent of their order. Two references with the same
+// signature have the same sequence names and lengths; they are likely, but
+// not guaranteed, to be the same assembly. For an Indexed, it does not access
+// the FASTA file.
+func LengthSignature(f Fasta) (string, error) {
+	seqNames := append([]string(nil), f.SeqNames()...)
+	sort.Strings(seqNames)
+	h := sha256.New()
+	for _, seqName := range seqNames {
+		n, err := f.Len(seqName)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\t%d\n", seqName, n)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DetectBuild returns the name of the assembly in KnownBuilds whose
+// chromosome lengths all match those of f, with or without a "chr" prefix on
+// the names of f. It returns false if no assembly, or more than one, matches.
+func DetectBuild(f Fasta) (string, bool) {
+	lengths := make(map[string]uint64)
+	for _, seqName := range f.SeqNames() {
+		n, err := f.Len(seqName)
+		if err != nil {
+			continue
+		}
+		lengths[strings.TrimPrefix(seqName, "chr")] = n
+	}
+	var match string
+	for build, chroms := range KnownBuilds {
+		ok := true
+		for chrom, n := range chroms {
+			if got, present := lengths[chrom]; !present || got != n {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			if match != "" {
+				return "", false
+			}
+			match = build
+		}
+	}
+	return match, match != ""
+}
@@ -0,0 +1,54 @@
+package fasta_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestLengthSignature(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	sig, err := fasta.LengthSignature(fa)
+	assert.NoError(t, err)
+	assert.EQ(t, len(sig), 64)
+
+	// The order of the sequences does not matter.
+	reordered, err := fasta.NewWithRefOrder(fa, []string{"seq2", "seq1"})
+	assert.NoError(t, err)
+	sig2, err := fasta.LengthSignature(reordered)
+	assert.NoError(t, err)
+	assert.EQ(t, sig2, sig)
+
+	// But the lengths do.
+	other, err := fasta.New(strings.NewReader(">seq1\nACGT\n>seq2\nACGTACGT\n"))
+	assert.NoError(t, err)
+	sig3, err := fasta.LengthSignature(other)
+	assert.NoError(t, err)
+	assert.True(t, sig3 != sig)
+}
+
+func TestDetectBuild(t *testing.T) {
+	index := func(prefix string, lengths map[string]uint64) fasta.Fasta {
+		var b bytes.Buffer
+		for name, n := range lengths {
+			fmt.Fprintf(&b, "%s%s\t%d\t0\t60\t61\n", prefix, name, n)
+		}
+		fa, err := fasta.NewIndexed(nil, &b)
+		assert.NoError(t, err)
+		return fa
+	}
+	for build, lengths := range fasta.KnownBuilds {
+		for _, prefix := range []string{"", "chr"} {
+			got, ok := fasta.DetectBuild(index(prefix, lengths))
+			assert.True(t, ok)
+			assert.EQ(t, got, build)
+		}
+	}
+	_, ok := fasta.DetectBuild(index("chr", map[string]uint64{"1": 248956422, "2": 1}))
+	assert.False(t, ok)
+}
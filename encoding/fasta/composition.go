@@ -0,0 +1,125 @@
+package fasta
+
+import (
+	"fmt"
+	"math"
+)
+
+// isGC is true for the bytes representing G or C in the ASCII and Seq8
+// encodings.
+var isGC = func() (t [256]bool) {
+	for _, b := range []byte{'C', 'G', 'c', 'g', 2, 4} {
+		t[b] = true
+	}
+	return
+}()
+
+// GCWindows streams the given sequence once and returns the GC fraction of
+// each consecutive window-sized bin, starting at position 0. The last bin may
+// be shorter than window; its fraction is relative to its actual length.
+// Lowercase bases are counted.
+func GCWindows(f Fasta, seqName string, window uint64) ([]float64, error) {
+	if window == 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return nil, err
+	}
+	gc := make([]float64, 0, (seqLen+window-1)/window)
+	var nGC, nBases uint64
+	err = streamSeq(f, seqName, true, func(start uint64, chunk string) bool {
+		for i := 0; i < len(chunk); i++ {
+			if isGC[chunk[i]] {
+				nGC++
+			}
+			nBases++
+			if nBases == window {
+				gc = append(gc, float64(nGC)/float64(nBases))
+				nGC, nBases = 0, 0
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if nBases > 0 {
+		gc = append(gc, float64(nGC)/float64(nBases))
+	}
+	return gc, nil
+}
+
+type complexityOpts struct {
+	dust bool
+}
+
+// ComplexityOpt is an optional argument to Complexity.
+type ComplexityOpt func(*complexityOpts)
+
+// OptDUST makes Complexity return the DUST score of the region instead of its
+// entropy.
+func OptDUST(o *complexityOpts) {
+	o.dust = true
+}
+
+// Complexity returns a measure of the sequence complexity of the bases
+// [start, end) of the given sequence, computed in a single pass over them,
+// e.g. to skip low-complexity regions when designing probes or primers. Only
+// A, C, G and T, in either case, are counted; other bases are skipped.
+//
+// By default, it returns the Shannon entropy of the base composition, in
+// bits: from 0, for a homopolymer, to 2, for equal counts of the four bases.
+// With OptDUST, it returns the DUST score: over the l triplets of consecutive
+// counted bases, not spanning skipped ones, the sum of c*(c-1)/2 over the
+// count c of each distinct triplet, divided by l-1. It ranges from 0, when no
+// triplet repeats, to l/2, for a homopolymer; scores above 2 are
+// conventionally deemed low complexity. Regions with fewer than two triplets
+// score 0.
+func Complexity(f Fasta, seqName string, start, end uint64, opts ...ComplexityOpt) (float64, error) {
+	var o complexityOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	seq, err := f.Get(seqName, start, end)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		baseCounts    [4]int
+		tripletCounts [64]int
+		triplet, run  int // last two bases, and number of consecutive bases.
+	)
+	for i := 0; i < len(seq); i++ {
+		code := baseCode[seq[i]]
+		if code > 3 {
+			run = 0
+			continue
+		}
+		baseCounts[code]++
+		triplet = (triplet<<2 | int(code)) & 63
+		if run++; run >= 3 {
+			tripletCounts[triplet]++
+		}
+	}
+	if o.dust {
+		var l, sum int
+		for _, c := range tripletCounts {
+			l += c
+			sum += c * (c - 1) / 2
+		}
+		if l < 2 {
+			return 0, nil
+		}
+		return float64(sum) / float64(l-1), nil
+	}
+	n := baseCounts[0] + baseCounts[1] + baseCounts[2] + baseCounts[3]
+	var entropy float64
+	for _, c := range baseCounts {
+		if c > 0 {
+			p := float64(c) / float64(n)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy, nil
+}
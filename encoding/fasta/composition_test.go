@@ -0,0 +1,53 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestGCWindows(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	// seq1 is AcGTACGTACGT.
+	gc, err := fasta.GCWindows(fa, "seq1", 5)
+	assert.NoError(t, err)
+	assert.EQ(t, gc, []float64{0.4, 0.6, 0.5})
+	gc, err = fasta.GCWindows(fa, "seq1", 12)
+	assert.NoError(t, err)
+	assert.EQ(t, gc, []float64{0.5})
+
+	_, err = fasta.GCWindows(fa, "seq1", 0)
+	assert.NotNil(t, err)
+	_, err = fasta.GCWindows(fa, "seq0", 5)
+	assert.NotNil(t, err)
+}
+
+func TestComplexity(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(">s\nAAAAAAAAAAcgtaACGTNNAAAT\n"), fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	dust := []fasta.ComplexityOpt{fasta.OptDUST}
+	for _, tt := range []struct {
+		start, end uint64
+		opts       []fasta.ComplexityOpt
+		want       float64
+	}{
+		{0, 10, nil, 0},
+		{10, 18, nil, 2},
+		{18, 20, nil, 0},
+		{20, 24, nil, 0.8112781244591328},
+		{0, 10, dust, 4},
+		{10, 18, dust, 1.0 / 5},
+		{16, 24, dust, 0},
+		{20, 24, dust, 0},
+		{18, 20, dust, 0},
+	} {
+		got, err := fasta.Complexity(fa, "s", tt.start, tt.end, tt.opts...)
+		assert.NoError(t, err)
+		assert.EQ(t, got, tt.want, "%d-%d %d", tt.start, tt.end, len(tt.opts))
+	}
+	_, err = fasta.Complexity(fa, "s", 0, 25)
+	assert.NotNil(t, err)
+}
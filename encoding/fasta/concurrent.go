@@ -0,0 +1,65 @@
+package fasta
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// NewIndexedConcurrent creates a new Fasta like NewIndexed, for FASTA data
+// accessible through r. Unlike NewIndexed, whose Gets are serialized by a
+// mutex, concurrent Gets proceed in parallel: each reads exactly the bytes it
+// needs with r.ReadAt, into buffers borrowed from a package-wide pool and
+// returned once the result is built. This combines concurrency with buffer
+// reuse. r must support concurrent ReadAt calls, as *os.File does. Other
+// methods, such as SeqRawReader and Prefetch, access r as NewIndexed does.
+func NewIndexedConcurrent(r io.ReaderAt, index io.Reader, opts ...Opt) (Indexed, error) {
+	parsedOpts := makeOpts(opts...)
+	entries, err := parseIndex(index, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newLazyIndexed(io.NewSectionReader(r, 0, math.MaxInt64), entries, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	f.readerAt = r
+	return f, nil
+}
+
+// getEncAt implements GetEnc for Fastas created by NewIndexedConcurrent.
+func (f *indexedFasta) getEncAt(seqName string, start, end uint64, enc Encoding) (string, error) {
+	f.mutex.Lock()
+	closed, cache := f.closed, f.cache
+	f.mutex.Unlock()
+	if closed {
+		return "", fmt.Errorf("Get called on a closed Fasta")
+	}
+	ent, err := f.checkGet(seqName, start, end)
+	if err != nil {
+		return "", err
+	}
+	if seq, ok := cache[ent.Name]; ok {
+		return encodeCached(seq[start:end], enc), nil
+	}
+	bufs := &readBufs{}
+	if !f.opts.NoBuffer {
+		bufs = bufPool.Get().(*readBufs)
+		defer bufPool.Put(bufs)
+	}
+	offset, capacity, linePos := ent.span(start, end)
+	f.resizeBuf(&bufs.buf, int(capacity))
+	n, err := f.readerAt.ReadAt(bufs.buf, int64(offset))
+	if n > len(bufs.buf) {
+		return "", fmt.Errorf("reader returned %d bytes for a read of at most %d", n, len(bufs.buf))
+	}
+	if n < len(bufs.buf) {
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if !f.opts.NoTrailingNewline {
+			return "", fmt.Errorf("encountered unexpected end of file (bad index? file doesn't end in newline?)")
+		}
+	}
+	return f.decode(ent, bufs.buf[:n], linePos, end-start, enc, &bufs.resultBuf)
+}
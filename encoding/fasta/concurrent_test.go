@@ -0,0 +1,144 @@
+package fasta_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+// randomFasta returns a FASTA file of n random sequences of up to maxLen
+// bases, and its index.
+func randomFasta(r *rand.Rand, n, maxLen int) (data, index []byte, seqs map[string]string) {
+	var buf, idx bytes.Buffer
+	seqs = make(map[string]string)
+	for i := 0; i < n; i++ {
+		seq := make([]byte, 1+r.Intn(maxLen))
+		for j := range seq {
+			seq[j] = "ACGTNacgt"[r.Intn(9)]
+		}
+		name := fmt.Sprintf("seq%d", i)
+		seqs[name] = string(seq)
+		buf.WriteString(">" + name + "\n")
+		for len(seq) > 60 {
+			buf.Write(seq[:60])
+			buf.WriteByte('\n')
+			seq = seq[60:]
+		}
+		buf.Write(seq)
+		buf.WriteByte('\n')
+	}
+	if err := fasta.GenerateIndex(&idx, bytes.NewReader(buf.Bytes())); err != nil {
+		panic(err)
+	}
+	return buf.Bytes(), idx.Bytes(), seqs
+}
+
+func TestIndexedConcurrent(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	data, index, seqs := randomFasta(r, 20, 5000)
+	fa, err := fasta.NewIndexedConcurrent(bytes.NewReader(data), bytes.NewReader(index), fasta.OptClean)
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 500; i++ {
+				name := fmt.Sprintf("seq%d", r.Intn(len(seqs)))
+				want := seqs[name]
+				start := r.Intn(len(want))
+				end := start + 1 + r.Intn(len(want)-start)
+				got, err := fa.Get(name, uint64(start), uint64(end))
+				assert.NoError(t, err)
+				assert.EQ(t, got, strings.ToUpper(want[start:end]))
+				raw, err := fa.GetEnc(name, uint64(start), uint64(end), fasta.RawASCII)
+				assert.NoError(t, err)
+				assert.EQ(t, raw, want[start:end])
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+
+	_, err = fa.Get("seq0", 0, uint64(len(seqs["seq0"])+1))
+	assert.Regexp(t, err, "end is past end of sequence seq0")
+	_, err = fa.Get("seq0", 1, 1)
+	assert.Regexp(t, err, "empty range")
+	assert.NoError(t, fa.Close())
+	_, err = fa.Get("seq0", 0, 1)
+	assert.Regexp(t, err, "closed Fasta")
+
+	// The data is truncated by its final newline.
+	fa, err = fasta.NewIndexedConcurrent(strings.NewReader(strings.TrimSuffix(fastaData, "\n")), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	_, err = fa.Get("seq2", 0, 8)
+	assert.Regexp(t, err, "unexpected end of file")
+	fa, err = fasta.NewIndexedConcurrent(strings.NewReader(strings.TrimSuffix(fastaData, "\n")), strings.NewReader(fastaIndex), fasta.OptNoTrailingNewline)
+	assert.NoError(t, err)
+	seq, err := fa.Get("seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+}
+
+// BenchmarkConcurrentGet compares concurrent Gets of 100 bases on a Fasta
+// shared by the given number of goroutines, as served by the mutex-protected
+// read buffer of NewIndexed, per-call allocation (NewIndexed with
+// OptNoBuffer), and the pooled buffers of NewIndexedConcurrent.
+func BenchmarkConcurrentGet(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	data, index, seqs := randomFasta(r, 100, 100000)
+	var names []string
+	for name, seq := range seqs {
+		if len(seq) > 100 {
+			names = append(names, name)
+		}
+	}
+	impls := []struct {
+		name string
+		fa   func() (fasta.Fasta, error)
+	}{
+		{"mutex", func() (fasta.Fasta, error) {
+			return fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index))
+		}},
+		{"alloc", func() (fasta.Fasta, error) {
+			return fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index), fasta.OptNoBuffer)
+		}},
+		{"pool", func() (fasta.Fasta, error) {
+			return fasta.NewIndexedConcurrent(bytes.NewReader(data), bytes.NewReader(index))
+		}},
+	}
+	for _, impl := range impls {
+		for _, goroutines := range []int{1, 4, 16} {
+			b.Run(fmt.Sprintf("%s/goroutines=%d", impl.name, goroutines), func(b *testing.B) {
+				fa, err := impl.fa()
+				assert.NoError(b, err)
+				b.ReportAllocs()
+				b.ResetTimer()
+				var wg sync.WaitGroup
+				for g := 0; g < goroutines; g++ {
+					wg.Add(1)
+					go func(g int) {
+						defer wg.Done()
+						r := rand.New(rand.NewSource(int64(g)))
+						for i := g; i < b.N; i += goroutines {
+							name := names[r.Intn(len(names))]
+							n := uint64(len(seqs[name]))
+							start := uint64(r.Int63n(int64(n - 100)))
+							if _, err := fa.Get(name, start, start+100); err != nil {
+								b.Error(err)
+								return
+							}
+						}
+					}(g)
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadDict parses a sequence dictionary (*.dict), as produced by Picard
+// CreateSequenceDictionary. Such a file consists of SAM header lines, of which
+// only the @SQ lines are used. It returns one entry per @SQ line, in file
+// order, with only Name and Length set, since dictionaries do not record
+// geometry. It also returns the M5 (MD5 digest) of each sequence that has one.
+func ReadDict(r io.Reader) ([]IndexEntry, map[string]string, error) {
+	var (
+		entries []IndexEntry
+		m5      = make(map[string]string)
+		scanner = bufio.NewScanner(r)
+		lineNum int
+	)
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "@SQ\t") {
+			continue
+		}
+		var (
+			ent       IndexEntry
+			hasLength bool
+		)
+		for _, field := range strings.Split(line, "\t")[1:] {
+			switch {
+			case strings.HasPrefix(field, "SN:"):
+				ent.Name = field[3:]
+			case strings.HasPrefix(field, "LN:"):
+				var err error
+				if ent.Length, err = strconv.ParseUint(field[3:], 10, 64); err != nil {
+					return nil, nil, fmt.Errorf("dict line %d: invalid length: %v", lineNum, err)
+				}
+				hasLength = true
+			case strings.HasPrefix(field, "M5:"):
+				m5[ent.Name] = field[3:]
+			}
+		}
+		if ent.Name == "" || !hasLength {
+			return nil, nil, fmt.Errorf("dict line %d: @SQ line lacks SN or LN: %s", lineNum, line)
+		}
+		if digest, ok := m5[""]; ok { // M5 appeared before SN.
+			delete(m5, "")
+			m5[ent.Name] = digest
+		}
+		entries = append(entries, ent)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read dict: %v", err)
+	}
+	return entries, m5, nil
+}
+
+// NewFromDict creates a new Fasta like NewIndexed, but using a sequence
+// dictionary (*.dict) instead of a FASTA index. Since a dictionary lacks byte
+// offsets, fasta is scanned once to compute the geometry of each sequence.
+// Every sequence in the dictionary must be present in fasta with the same
+// length; sequences absent from the dictionary are not accessible.
+func NewFromDict(fasta io.ReadSeeker, dict io.Reader, opts ...Opt) (Indexed, error) {
+	dictEntries, _, err := ReadDict(dict)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fasta.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var index bytes.Buffer
+	if err := GenerateIndex(&index, fasta); err != nil {
+		return nil, err
+	}
+	parsedOpts := makeOpts(opts...)
+	scanned, err := parseIndex(&index, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]IndexEntry, len(scanned))
+	for _, ent := range scanned {
+		byName[ent.Name] = ent
+	}
+	entries := make([]IndexEntry, len(dictEntries))
+	for i, dictEnt := range dictEntries {
+		ent, ok := byName[dictEnt.Name]
+		if !ok {
+			return nil, fmt.Errorf("sequence %s in dict not found in FASTA", dictEnt.Name)
+		}
+		if ent.Length != dictEnt.Length {
+			return nil, fmt.Errorf("sequence %s has length %d in dict, but %d in FASTA",
+				dictEnt.Name, dictEnt.Length, ent.Length)
+		}
+		entries[i] = ent
+	}
+	return newLazyIndexed(fasta, entries, parsedOpts)
+}
+
+// RefDiscrepancy describes a reference, e.g. from an @SQ line of a BAM header,
+// that does not match the sequences of a Fasta.
+type RefDiscrepancy struct {
+	// Name is the name of the reference.
+	Name string
+	// Length is the length of the reference.
+	Length uint64
+	// Missing is true if the Fasta has no sequence of that name.
+	Missing bool
+	// SeqLength is the length of the sequence of that name in the Fasta, if
+	// any.
+	SeqLength uint64
+}
+
+// String implements fmt.Stringer.
+func (d RefDiscrepancy) String() string {
+	if d.Missing {
+		return fmt.Sprintf("reference %s is missing from the FASTA", d.Name)
+	}
+	return fmt.Sprintf("reference %s has length %d, but the FASTA sequence has length %d", d.Name, d.Length, d.SeqLength)
+}
+
+// CheckAgainstRefs compares the Name and Length of each of refs, such as the
+// entries returned by ReadDict, to the sequences of f, and returns the refs
+// that are missing from f or whose lengths differ, in the order of refs. Only
+// Len is called, so for an Indexed the check reads just the index, which
+// catches mismatched references before any bases are read.
+func CheckAgainstRefs(f Fasta, refs []IndexEntry) []RefDiscrepancy {
+	var discrepancies []RefDiscrepancy
+	for _, ref := range refs {
+		seqLen, err := f.Len(ref.Name)
+		if err != nil {
+			discrepancies = append(discrepancies, RefDiscrepancy{Name: ref.Name, Length: ref.Length, Missing: true})
+		} else if seqLen != ref.Length {
+			discrepancies = append(discrepancies, RefDiscrepancy{Name: ref.Name, Length: ref.Length, SeqLength: seqLen})
+		}
+	}
+	return discrepancies
+}
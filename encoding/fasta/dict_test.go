@@ -0,0 +1,61 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+const testDict = `@HD	VN:1.0	SO:unsorted
+@SQ	SN:seq1	LN:12	M5:0123456789abcdef0123456789abcdef	UR:file:/tmp/test.fa
+@SQ	SN:seq2	LN:8	UR:file:/tmp/test.fa
+`
+
+func TestReadDict(t *testing.T) {
+	entries, m5, err := fasta.ReadDict(strings.NewReader(testDict))
+	assert.NoError(t, err)
+	assert.EQ(t, entries, []fasta.IndexEntry{{Name: "seq1", Length: 12}, {Name: "seq2", Length: 8}})
+	assert.EQ(t, m5, map[string]string{"seq1": "0123456789abcdef0123456789abcdef"})
+
+	_, _, err = fasta.ReadDict(strings.NewReader("@SQ\tSN:seq1\n"))
+	assert.NotNil(t, err)
+	_, _, err = fasta.ReadDict(strings.NewReader("@SQ\tSN:seq1\tLN:x\n"))
+	assert.NotNil(t, err)
+}
+
+func TestNewFromDict(t *testing.T) {
+	fa, err := fasta.NewFromDict(strings.NewReader(fastaData), strings.NewReader(testDict))
+	assert.NoError(t, err)
+	assert.EQ(t, fa.SeqNames(), []string{"seq1", "seq2"})
+	seq, err := fa.Get("seq1", 4, 11)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACG")
+	seq, err = fa.Get("seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+
+	_, err = fasta.NewFromDict(strings.NewReader(fastaData), strings.NewReader("@SQ\tSN:seq1\tLN:13\n"))
+	assert.Regexp(t, err, "has length 13 in dict")
+	_, err = fasta.NewFromDict(strings.NewReader(fastaData), strings.NewReader("@SQ\tSN:seq3\tLN:13\n"))
+	assert.Regexp(t, err, "not found")
+}
+
+func TestCheckAgainstRefs(t *testing.T) {
+	// The bases are never read.
+	fa, err := fasta.NewIndexed(strings.NewReader(""), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	refs, _, err := fasta.ReadDict(strings.NewReader(testDict))
+	assert.NoError(t, err)
+	assert.EQ(t, len(fasta.CheckAgainstRefs(fa, refs)), 0)
+
+	refs = []fasta.IndexEntry{{Name: "chr1", Length: 12}, {Name: "seq2", Length: 8}, {Name: "seq1", Length: 13}}
+	discrepancies := fasta.CheckAgainstRefs(fa, refs)
+	assert.EQ(t, discrepancies, []fasta.RefDiscrepancy{
+		{Name: "chr1", Length: 12, Missing: true},
+		{Name: "seq1", Length: 13, SeqLength: 12},
+	})
+	assert.EQ(t, discrepancies[0].String(), "reference chr1 is missing from the FASTA")
+	assert.EQ(t, discrepancies[1].String(), "reference seq1 has length 13, but the FASTA sequence has length 12")
+}
@@ -0,0 +1,56 @@
+package fasta
+
+import (
+	"fmt"
+)
+
+// Diff is a base-level difference between two sequences.
+type Diff struct {
+	// Pos is the 0-based position of the difference.
+	Pos uint64
+	// A and B are the bases at Pos in the first and second sequence.
+	A, B byte
+}
+
+// DiffSeqs compares the sequence with the given name in a and b, which must
+// have equal lengths, and returns the positions where they differ in
+// increasing order. The sequences are streamed in aligned chunks, so memory
+// use does not depend on their length. Bases are compared as returned by Get,
+// so a and b should normally use the same encoding.
+func DiffSeqs(a, b Fasta, name string) ([]Diff, error) {
+	aLen, err := a.Len(name)
+	if err != nil {
+		return nil, err
+	}
+	bLen, err := b.Len(name)
+	if err != nil {
+		return nil, err
+	}
+	if aLen != bLen {
+		return nil, fmt.Errorf("sequence %s has different lengths: %d vs %d", name, aLen, bLen)
+	}
+	var diffs []Diff
+	for start := uint64(0); start < aLen; start += streamChunkSize {
+		end := start + streamChunkSize
+		if end > aLen {
+			end = aLen
+		}
+		aSeq, err := a.Get(name, start, end)
+		if err != nil {
+			return nil, err
+		}
+		bSeq, err := b.Get(name, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if aSeq == bSeq {
+			continue
+		}
+		for i := 0; i < len(aSeq); i++ {
+			if aSeq[i] != bSeq[i] {
+				diffs = append(diffs, Diff{Pos: start + uint64(i), A: aSeq[i], B: bSeq[i]})
+			}
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,29 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestDiffSeqs(t *testing.T) {
+	a, err := fasta.New(strings.NewReader(">chr1\nACGTACGT\n>chr2\nAC\n"))
+	assert.NoError(t, err)
+	b, err := fasta.New(strings.NewReader(">chr1\nACCTACGA\n>chr2\nACG\n"))
+	assert.NoError(t, err)
+
+	diffs, err := fasta.DiffSeqs(a, b, "chr1")
+	assert.NoError(t, err)
+	assert.EQ(t, diffs, []fasta.Diff{{Pos: 2, A: 'G', B: 'C'}, {Pos: 7, A: 'T', B: 'A'}})
+
+	diffs, err = fasta.DiffSeqs(a, a, "chr1")
+	assert.NoError(t, err)
+	assert.EQ(t, len(diffs), 0)
+
+	_, err = fasta.DiffSeqs(a, b, "chr2")
+	assert.Regexp(t, err, "different lengths")
+	_, err = fasta.DiffSeqs(a, b, "chr3")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,182 @@
+package fasta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Schaudge/grailbase/unsafe"
+)
+
+const (
+	// diskCacheChunkBases is the number of bases held by each file of the
+	// disk cache, except for the last chunk of a sequence.
+	diskCacheChunkBases = 1 << 18
+	// diskCacheSuffix ends the names of the chunk files of the disk cache.
+	// Other files in the cache directory are left alone.
+	diskCacheSuffix = ".fachunk"
+)
+
+// diskCache is the on-disk cache of decoded chunks set up by OptDiskCache.
+// It is only used with indexedFasta.mutex held.
+type diskCache struct {
+	dir      string
+	maxBytes int64
+	// size estimates the total size of the chunk files in dir. Since other
+	// processes may share dir, it is recomputed whenever it exceeds maxBytes.
+	size int64
+}
+
+func newDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &diskCache{dir: dir, maxBytes: maxBytes}
+	files, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range files {
+		c.size += fi.Size()
+	}
+	return c, nil
+}
+
+// indexChecksum returns a digest of the geometry of all the given entries,
+// which keys the disk cache.
+func indexChecksum(entries []IndexEntry) string {
+	h := sha256.New()
+	for _, ent := range entries {
+		fmt.Fprintf(h, "%s\t%d\t%d\t%d\t%d\n", ent.Name, ent.Length, ent.Offset, ent.LineBase, ent.LineWidth)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns the path of the file holding the given chunk of the given
+// sequence of the index with the given checksum.
+func (c *diskCache) path(indexSum, seqName string, chunk uint64) string {
+	key := sha256.Sum256([]byte(indexSum + "\x00" + seqName))
+	return filepath.Join(c.dir, fmt.Sprintf("%x-%d%s", key[:16], chunk, diskCacheSuffix))
+}
+
+// get returns the n bases stored at path, if any. A hit marks the file as
+// recently used.
+func (c *diskCache) get(path string, n uint64) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil || uint64(len(data)) != n {
+		return "", false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now) // Best effort.
+	return unsafe.BytesToString(data), true
+}
+
+// put stores seq at path, replacing the file atomically so that concurrent
+// readers never see a partial chunk, and then evicts chunks if the cache
+// exceeds its budget. Errors are ignored, since the cache is only an
+// optimization.
+func (c *diskCache) put(path, seq string) {
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.WriteString(seq)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	c.size += int64(len(seq))
+	if c.maxBytes > 0 && c.size > c.maxBytes {
+		c.evict()
+	}
+}
+
+// evict removes the least recently used chunk files until their total size is
+// within the budget.
+func (c *diskCache) evict() {
+	files, err := c.list()
+	if err != nil {
+		return
+	}
+	c.size = 0
+	for _, fi := range files {
+		c.size += fi.Size()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if ti, tj := files[i].ModTime(), files[j].ModTime(); !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return files[i].Name() < files[j].Name()
+	})
+	for _, fi := range files {
+		if c.size <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err == nil || os.IsNotExist(err) {
+			c.size -= fi.Size()
+		}
+	}
+}
+
+// list returns the chunk files in the cache directory.
+func (c *diskCache) list() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []os.FileInfo
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), diskCacheSuffix) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			files = append(files, fi)
+		}
+	}
+	return files, nil
+}
+
+// getDiskCached returns the bases [start, end) of the sequence described by
+// ent, which the caller has checked to be a valid range, in the given
+// encoding. The chunks holding them are read from the disk cache, or from
+// the FASTA file and then stored in the cache. It must be called with
+// f.mutex held.
+func (f *indexedFasta) getDiskCached(ent IndexEntry, start, end uint64, enc Encoding) (string, error) {
+	indexSum := f.currentIndex().checksum
+	raw := make([]byte, 0, end-start)
+	for chunk := start / diskCacheChunkBases; chunk*diskCacheChunkBases < end; chunk++ {
+		chunkStart, chunkEnd := chunk*diskCacheChunkBases, (chunk+1)*diskCacheChunkBases
+		if chunkEnd > ent.Length {
+			chunkEnd = ent.Length
+		}
+		path := f.diskCache.path(indexSum, ent.Name, chunk)
+		seq, ok := f.diskCache.get(path, chunkEnd-chunkStart)
+		if !ok {
+			var err error
+			if seq, err = f.getEntry(ent, chunkStart, chunkEnd, RawASCII); err != nil {
+				return "", err
+			}
+			f.diskCache.put(path, seq)
+		}
+		lo, hi := uint64(0), chunkEnd-chunkStart
+		if start > chunkStart {
+			lo = start - chunkStart
+		}
+		if end < chunkEnd {
+			hi = end - chunkStart
+		}
+		raw = append(raw, seq[lo:hi]...)
+	}
+	return encodeCached(unsafe.BytesToString(raw), enc), nil
+}
@@ -0,0 +1,94 @@
+package fasta_test
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil"
+	"github.com/grailbio/testutil/assert"
+)
+
+func chunkFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.fachunk"))
+	assert.NoError(t, err)
+	return files
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t, "", "")
+	defer cleanup()
+
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+		fasta.OptDiskCache(dir, 0))
+	assert.NoError(t, err)
+	seq, err := fa.Get("seq1", 1, 7)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "cGTACG")
+	seq, err = fa.Get("seq2", 2, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "GTACGT")
+	assert.EQ(t, len(chunkFiles(t, dir)), 2)
+
+	// Another Fasta sharing the directory reads the chunks from there, and
+	// encodes them as it is configured to.
+	fa, err = fasta.NewIndexed(strings.NewReader(""), strings.NewReader(fastaIndex),
+		fasta.OptDiskCache(dir, 0), fasta.OptClean)
+	assert.NoError(t, err)
+	seq, err = fa.Get("seq1", 0, 12)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGTACGT")
+	_, err = fa.Get("seq1", 0, 13)
+	assert.NotNil(t, err)
+
+	// A different index does not use them.
+	fa, err = fasta.NewIndexed(strings.NewReader(""), strings.NewReader(strings.Replace(fastaIndex, "seq2", "chr2", 1)),
+		fasta.OptDiskCache(dir, 0))
+	assert.NoError(t, err)
+	_, err = fa.Get("seq1", 0, 12)
+	assert.NotNil(t, err)
+
+	// Least recently used chunks are evicted beyond the budget.
+	dir, cleanup = testutil.TempDir(t, "", "")
+	defer cleanup()
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+		fasta.OptDiskCache(dir, 10))
+	assert.NoError(t, err)
+	_, err = fa.Get("seq2", 0, 1)
+	assert.NoError(t, err)
+	old := time.Now().Add(-time.Hour)
+	for _, path := range chunkFiles(t, dir) {
+		assert.NoError(t, os.Chtimes(path, old, old))
+	}
+	// Caching seq1 evicts seq2, and then seq1 itself, which is over budget.
+	_, err = fa.Get("seq1", 0, 1)
+	assert.NoError(t, err)
+	assert.EQ(t, len(chunkFiles(t, dir)), 0)
+	_, err = fa.Get("seq2", 0, 1)
+	assert.NoError(t, err)
+	assert.EQ(t, len(chunkFiles(t, dir)), 1)
+}
+
+func TestDiskCacheChunks(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t, "", "")
+	defer cleanup()
+	r := rand.New(rand.NewSource(0))
+	data, index, seqs := randomFasta(r, 1, 700000)
+	seq := seqs["seq0"]
+	n := uint64(len(seq))
+	for _, data := range [][]byte{data, nil} {
+		fa, err := fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index), fasta.OptDiskCache(dir, 0))
+		assert.NoError(t, err)
+		for _, rng := range [][2]uint64{{0, n}, {1 << 18, n}, {1<<18 - 5, 1<<18 + 5}, {n - 3, n}} {
+			got, err := fa.Get("seq0", rng[0], rng[1])
+			assert.NoError(t, err)
+			assert.True(t, got == seq[rng[0]:rng[1]], "%v", rng)
+		}
+	}
+	assert.EQ(t, len(chunkFiles(t, dir)), int((n+1<<18-1)>>18))
+}
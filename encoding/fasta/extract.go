@@ -0,0 +1,136 @@
+package fasta
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Extract writes the given regions of f to out as a FASTA file wrapped at
+// lineWidth bases per line, and its index (*.fai) to index. Each region
+// becomes a record named after Region.String(), i.e., "name:start-end" with
+// 0-based half-open coordinates. If f is an Indexed, the original bytes of the
+// FASTA file, including case, are written; otherwise the result of f.Get.
+func Extract(f Fasta, regions []Region, out io.Writer, index io.Writer, lineWidth int) error {
+	w, err := NewWriter(out, lineWidth)
+	if err != nil {
+		return err
+	}
+	for _, r := range regions {
+		if err := checkRange(r.Start, r.End); err != nil {
+			return fmt.Errorf("region %s: %w", r, err)
+		}
+		if err := w.startSeq(r.String()); err != nil {
+			return err
+		}
+		for start := r.Start; start < r.End; start += streamChunkSize {
+			end := start + streamChunkSize
+			if end > r.End {
+				end = r.End
+			}
+			seq, err := getRaw(f, r.SeqName, start, end)
+			if err != nil {
+				return fmt.Errorf("region %s: %v", r, err)
+			}
+			if err := w.writeBases(seq); err != nil {
+				return err
+			}
+		}
+		if err := w.endSeq(); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return w.WriteIndex(index)
+}
+
+// SubsetFASTAReader returns a reader of a FASTA file holding the given
+// sequences of f, in order, wrapped at lineWidth bases per line. It is the
+// streaming counterpart of Extract: records are produced as the reader is
+// read, a chunk of bases at a time, so the whole subset is never held in
+// memory. If f is an Indexed, the original bytes of the FASTA file, including
+// case, are emitted; otherwise the result of f.Get.
+func SubsetFASTAReader(f Fasta, names []string, lineWidth int) (io.Reader, error) {
+	r := &subsetReader{f: f, names: append([]string(nil), names...)}
+	var err error
+	if r.w, err = NewWriter(&r.buf, lineWidth); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate sequence name: %s", name)
+		}
+		seen[name] = true
+		if _, err := f.Len(name); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// subsetReader implements SubsetFASTAReader.
+type subsetReader struct {
+	f     Fasta
+	names []string // sequences left to start.
+	w     *Writer  // writes to buf.
+	buf   bytes.Buffer
+	err   error // returned once buf is drained.
+
+	inSeq       bool // true while writing the record of seqName.
+	seqName     string
+	pos, seqLen uint64
+}
+
+// Read implements io.Reader.
+func (r *subsetReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.fill()
+	}
+	return r.buf.Read(p)
+}
+
+// fill writes the next piece of the output, i.e., a header line, a chunk of
+// bases or the end of a record, to r.buf. It returns io.EOF once all the
+// records are written.
+func (r *subsetReader) fill() error {
+	switch {
+	case !r.inSeq:
+		if len(r.names) == 0 {
+			return io.EOF
+		}
+		r.seqName, r.names = r.names[0], r.names[1:]
+		var err error
+		if r.seqLen, err = r.f.Len(r.seqName); err != nil {
+			return err
+		}
+		if err := r.w.startSeq(r.seqName); err != nil {
+			return err
+		}
+		r.inSeq, r.pos = true, 0
+	case r.pos < r.seqLen:
+		end := r.pos + streamChunkSize
+		if end > r.seqLen {
+			end = r.seqLen
+		}
+		seq, err := getRaw(r.f, r.seqName, r.pos, end)
+		if err != nil {
+			return err
+		}
+		if err := r.w.writeBases(seq); err != nil {
+			return err
+		}
+		r.pos = end
+	default:
+		if err := r.w.endSeq(); err != nil {
+			return err
+		}
+		r.inSeq = false
+	}
+	return r.w.Flush()
+}
@@ -0,0 +1,61 @@
+package fasta_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestExtract(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptClean)
+	assert.NoError(t, err)
+	var out, index bytes.Buffer
+	err = fasta.Extract(fa, []fasta.Region{
+		{SeqName: "seq1", Start: 1, End: 10},
+		{SeqName: "seq2", Start: 0, End: 2},
+	}, &out, &index, 4)
+	assert.NoError(t, err)
+	assert.EQ(t, out.String(), ">seq1:1-10\ncGTA\nCGTA\nC\n>seq2:0-2\nAC\n")
+	assert.EQ(t, index.String(), "seq1:1-10\t9\t11\t4\t5\nseq2:0-2\t2\t33\t4\t5\n")
+
+	sub, err := fasta.NewIndexed(bytes.NewReader(out.Bytes()), bytes.NewReader(index.Bytes()))
+	assert.NoError(t, err)
+	seq, err := sub.Get("seq1:1-10", 0, 9)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "cGTACGTAC")
+
+	err = fasta.Extract(fa, []fasta.Region{{SeqName: "seq1", Start: 10, End: 13}}, &out, &index, 4)
+	assert.Regexp(t, err, "seq1:10-13")
+	err = fasta.Extract(fa, []fasta.Region{{SeqName: "seq1", Start: 3, End: 3}}, &out, &index, 4)
+	assert.Regexp(t, err, "start must be less than end")
+}
+
+func TestSubsetFASTAReader(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	r, err := fasta.SubsetFASTAReader(fa, []string{"seq2", "seq1"}, 4)
+	assert.NoError(t, err)
+	// Read a byte at a time, to exercise the refills.
+	var out bytes.Buffer
+	_, err = io.Copy(&out, iotest.OneByteReader(r))
+	assert.NoError(t, err)
+	assert.EQ(t, out.String(), ">seq2\nACGT\nACGT\n>seq1\nAcGT\nACGT\nACGT\n")
+
+	r, err = fasta.SubsetFASTAReader(fa, nil, 4)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.EQ(t, len(data), 0)
+
+	_, err = fasta.SubsetFASTAReader(fa, []string{"seq1", "seq3"}, 4)
+	assert.Regexp(t, err, "sequence not found in index: seq3")
+	_, err = fasta.SubsetFASTAReader(fa, []string{"seq1", "seq1"}, 4)
+	assert.Regexp(t, err, "duplicate sequence name: seq1")
+	_, err = fasta.SubsetFASTAReader(fa, []string{"seq1"}, 0)
+	assert.Regexp(t, err, "line width must be positive")
+}
@@ -17,10 +17,10 @@ package fasta
 import (
 	"bufio"
 	"bytes"
-	"fmt"
 	"io"
+	"net/http"
+	"strings"
 
-	"github.com/Schaudge/grailbio/biosimd"
 	"github.com/pkg/errors"
 )
 
@@ -45,6 +45,39 @@ type Fasta interface {
 	SeqNames() []string
 }
 
+var (
+	// ErrEmptyRange is returned, possibly wrapped, by Get and other range
+	// queries when start == end.
+	ErrEmptyRange = errors.New("empty range: start must be less than end")
+	// ErrReversedRange is returned, possibly wrapped, by Get and other range
+	// queries when start > end.
+	ErrReversedRange = errors.New("reversed range: start must be less than end")
+	// ErrQuotaExceeded is returned, wrapped, by Get when serving the request
+	// would exceed the quota set with OptByteQuota.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrLooksLikeFASTQ is returned by New and ScanRecords when the input
+	// starts with a FASTQ record: an '@' header, a sequence line and a '+'
+	// separator line.
+	ErrLooksLikeFASTQ = errors.New("this looks like FASTQ, not FASTA")
+)
+
+// isFASTQStart reports whether first and third, the first and third non-blank
+// lines of a file, look like the header and separator of a FASTQ record.
+func isFASTQStart(first, third []byte) bool {
+	return len(first) > 0 && first[0] == '@' && len(third) > 0 && third[0] == '+'
+}
+
+// checkRange returns ErrEmptyRange or ErrReversedRange unless start < end.
+func checkRange(start, end uint64) error {
+	if start == end {
+		return ErrEmptyRange
+	}
+	if start > end {
+		return ErrReversedRange
+	}
+	return nil
+}
+
 type Encoding byte
 
 const (
@@ -56,14 +89,51 @@ const (
 	// Seq8 encoding is 'A'/'a' = 1, 'C'/'c' = 2, 'G'/'g' = 4, 'T'/'t' = 8,
 	// anything else = 15.  This plays well with BAM/PAM files.
 	Seq8
+	// TwoBit encoding packs four bases per byte, with 'A'/'a' = 0, 'C'/'c' = 1,
+	// 'G'/'g' = 2, 'T'/'t' = 3 and the first base in the two most significant
+	// bits, like the UCSC .2bit format. Anything else, including 'N', is packed
+	// as 0, so it is meant for regions known to consist of ACGT. A result for n
+	// bases is (n+3)/4 bytes long, with the unused bits of the last byte zero.
+	// Helpers that inspect the bases returned by Get do not support it; those
+	// that slice them by base offsets, such as GetBatch, return an error.
+	TwoBit
+	// Seq8IUPAC encoding extends Seq8 to all IUPAC nucleotide codes, either
+	// case, using the 4-bit codes of BAM records: '-' = 0, 'A' = 1, 'C' = 2,
+	// 'M' = 3, 'G' = 4, 'R' = 5, 'S' = 6, 'V' = 7, 'T'/'U' = 8, 'W' = 9,
+	// 'Y' = 10, 'H' = 11, 'K' = 12, 'D' = 13, 'B' = 14, 'N' = 15, anything
+	// else = 15. Each code is the bitwise or of the codes of the bases it
+	// stands for, so A/C/G/T/N are encoded as by Seq8.
+	Seq8IUPAC
 	// TODO(cchang): Add 'Base5' encoding, where 'A'/'a' = 0, 'C'/'c' = 1,
 	// 'G'/'g' = 2, 'T'/'t' = 3, anything else = 4.
 	EncodingLimit
 )
 
 type opts struct {
-	Enc   Encoding
-	Index []byte
+	Enc               Encoding
+	Index             []byte
+	InitialSeek       string
+	MaxResultBuf      int
+	FullLineName      bool
+	NoTrailingNewline bool
+	SharedBufferPool  bool
+	NoBuffer          bool
+	GZIWriter         io.Writer
+	NormalizeName     func(string) string
+	PadN              bool
+	VerifyM5          map[string]string
+	SeqEncoding       map[string]Encoding
+	ByteQuota         uint64
+	UnknownChars      *[256]bool
+	DecodeParallel    int
+	DiskCacheDir      string
+	DiskCacheMaxBytes int64
+	NameMapFile       io.Reader         // see OptNameMapFile.
+	NameMap           map[string]string // parsed from NameMapFile by readNameMap.
+	BufferGrowth      float64
+	WarnReadBytes     int
+	WarnRead          func(seqName string, bytes int)
+	HTTPClient        *http.Client
 }
 
 // Opt is an optional argument to New, NewIndexed.
@@ -102,6 +172,272 @@ func OptIndex(index []byte) Opt {
 	}
 }
 
+// OptInitialSeek makes NewIndexed position the FASTA reader at the start of the
+// given sequence during construction, so that the first Get on that sequence
+// need not seek. NewIndexed fails if the sequence is not in the index. It is
+// ignored by New.
+func OptInitialSeek(seqName string) Opt {
+	return func(o *opts) {
+		o.InitialSeek = seqName
+	}
+}
+
+// OptMaxResultBuf bounds the memory NewIndexed retains between Get calls for
+// assembling results. After a Get whose result exceeds n bytes, the internal
+// result buffer is released and replaced with one of capacity n. This trades
+// a reallocation on every large query for not holding on to memory sized for
+// the largest query ever made (e.g., a whole chromosome). The default, 0,
+// retains the buffer regardless of size. It is ignored by New.
+func OptMaxResultBuf(n int) Opt {
+	return func(o *opts) {
+		o.MaxResultBuf = n
+	}
+}
+
+// OptFullLineName makes index parsing accept sequence names that contain
+// whitespace other than tabs, using the entire first tab-separated field of
+// each index line as the name. By default such names are rejected, since
+// "samtools faidx" never produces them and they usually indicate a malformed
+// index.
+func OptFullLineName(o *opts) {
+	o.FullLineName = true
+}
+
+// OptNoTrailingNewline makes NewIndexed tolerate FASTA files whose last line
+// lacks a line terminator. Without it, a Get that reaches the end of such a
+// file may fail because fewer bytes than expected are available. With it,
+// Get uses the bytes that are available and fails only if they do not include
+// all of the requested bases. It is ignored by New.
+func OptNoTrailingNewline(o *opts) {
+	o.NoTrailingNewline = true
+}
+
+// OptSharedBufferPool makes NewIndexed borrow its read buffers from a
+// package-wide pool, and return them to it on Close. This reduces allocation
+// in programs that create many short-lived Fastas. It is ignored by New.
+func OptSharedBufferPool(o *opts) {
+	o.SharedBufferPool = true
+}
+
+// OptNoBuffer makes NewIndexed read exactly the bytes each Get needs into
+// freshly allocated buffers, instead of reading ahead into a retained read
+// buffer, and release its result buffer after each Get. This trades an
+// allocation per Get for retaining no memory between calls, which suits
+// Fastas used for a single Get. It is ignored by New.
+func OptNoBuffer(o *opts) {
+	o.NoBuffer = true
+}
+
+// OptGZIWriter makes NewIndexedBGZFAutoIndex write the BGZF index it builds to
+// w, in the *.gzi format accepted by NewIndexedBGZF. It is ignored by other
+// constructors.
+func OptGZIWriter(w io.Writer) Opt {
+	return func(o *opts) {
+		o.GZIWriter = w
+	}
+}
+
+// OptNormalizeName applies fn to every sequence name, both when the names are
+// read from the FASTA file or index, and when they are passed to Get or Len.
+// For example, it can strip version suffixes such as ".2" from accessions.
+// SeqNames returns the normalized names. Construction fails if two distinct
+// sequence names normalize to the same name.
+func OptNormalizeName(fn func(string) string) Opt {
+	return func(o *opts) {
+		o.NormalizeName = fn
+	}
+}
+
+// OptPadN makes Get accept ranges that extend past the end of a sequence. The
+// part of the range within the sequence is returned as usual, followed by
+// enough 'N's (15 in the Seq8 encoding) to make the result end-start bytes
+// long. This is useful for extracting fixed-width windows near sequence ends.
+func OptPadN(o *opts) {
+	o.PadN = true
+}
+
+// OptVerifyM5 provides the expected MD5 digests of sequences, keyed by
+// sequence name, in the format of the M5 tag of SAM @SQ lines. GetAll and
+// SeqMD5 check the digest of each sequence listed in m5 and return an error on
+// a mismatch, which catches on-disk corruption and mismatched references.
+// Other reads are not checked.
+func OptVerifyM5(m5 map[string]string) Opt {
+	return func(o *opts) {
+		o.VerifyM5 = m5
+	}
+}
+
+// OptSeqEncoding overrides, for the sequences named in enc, the encoding in
+// which Get returns them, e.g., to read the protein sequences of a mixed
+// reference in RawASCII. Other sequences use the encoding given by
+// OptEncoding. Construction fails if enc names an unknown sequence or holds an
+// invalid encoding. For New, the sequences are held in RawASCII and encoded by
+// each Get.
+func OptSeqEncoding(enc map[string]Encoding) Opt {
+	return func(o *opts) {
+		o.SeqEncoding = enc
+	}
+}
+
+// OptByteQuota caps the total number of bases that Get and GetEnc of
+// NewIndexed return over the lifetime of the Fasta at n. A request that would
+// take the total past n fails with ErrQuotaExceeded, without counting towards
+// it. The total is reported by Indexed.BytesServed. The default, 0, sets no
+// cap. It is ignored by New.
+func OptByteQuota(n uint64) Opt {
+	return func(o *opts) {
+		o.ByteQuota = n
+	}
+}
+
+// OptUnknownChars sets the characters that the gap helpers, LongestUngapped,
+// GetWithGapInfo, IsAllN and GetIfCleanEnough, treat as unknown bases,
+// replacing the default 'N' and 'n'. For example, protein references may use
+// "X". Matching is case-sensitive. The Seq8 code of unknown bases, 15, is
+// always unknown.
+func OptUnknownChars(set string) Opt {
+	return func(o *opts) {
+		t := [256]bool{15: true}
+		for i := 0; i < len(set); i++ {
+			t[set[i]] = true
+		}
+		o.UnknownChars = &t
+	}
+}
+
+// OptDecodeParallel makes NewIndexed split the decoding of large reads, of a
+// megabyte or more, across the given number of goroutines: each strips the
+// line terminators from, and encodes, a run of whole lines into its part of
+// the result. This speeds up the retrieval of whole chromosomes on multicore
+// machines. The default, 0 or 1, decodes in the calling goroutine. It is
+// ignored by New.
+func OptDecodeParallel(workers int) Opt {
+	return func(o *opts) {
+		o.DecodeParallel = workers
+	}
+}
+
+// OptBufferGrowth makes NewIndexed allocate factor times the needed capacity
+// whenever a read outgrows its buffer, e.g., 2 to double it, so that streams of
+// mixed-size queries reallocate the buffer a logarithmic number of times
+// instead of on every larger read. The buffer is never shrunk. The default, a
+// factor of 1 or less, allocates exactly what each read needs. It is ignored
+// by New and with OptNoBuffer.
+func OptBufferGrowth(factor float64) Opt {
+	return func(o *opts) {
+		o.BufferGrowth = factor
+	}
+}
+
+// OptWarnLargeRead makes NewIndexed call fn before each Get, or other
+// retrieval, that spans more than threshold bytes of the FASTA file, with the
+// name of the sequence and the number of bytes. E.g., a GetAll of an unwrapped
+// chromosome allocates a read buffer and a result of about its whole length;
+// fn can log such reads before they cause an out-of-memory error. fn does not
+// change how the read is done, may be called concurrently, and must not call
+// back into the Fasta. It is ignored by New.
+func OptWarnLargeRead(threshold int, fn func(seqName string, bytes int)) Opt {
+	return func(o *opts) {
+		o.WarnReadBytes, o.WarnRead = threshold, fn
+	}
+}
+
+// OptDiskCache makes NewIndexed keep the decoded bases it reads in files in
+// dir, in chunks of 256Ki bases, so that later Gets, including those of other
+// processes sharing dir, read them from there instead of decoding the FASTA
+// file again. Once the chunk files take more than maxBytes, the least
+// recently used ones are removed; maxBytes <= 0 sets no limit.
+//
+// Chunks are keyed by a checksum of the whole index and the sequence name,
+// so a changed index, e.g. one reloaded by WatchIndex, does not reuse stale
+// chunks. The cache assumes that the FASTA data is determined by its index:
+// dir must not be shared by FASTA files that differ but have identical
+// indexes. Errors writing the cache are ignored. The cache is not used by
+// NewIndexedConcurrent, NewIndexedFromReaderAt, NewIndexedHTTP or New.
+func OptDiskCache(dir string, maxBytes int64) Opt {
+	return func(o *opts) {
+		o.DiskCacheDir, o.DiskCacheMaxBytes = dir, maxBytes
+	}
+}
+
+// OptHTTPClient sets the client that NewIndexedHTTP makes its requests
+// with, e.g., to set a different timeout or transport. It is ignored by the
+// other constructors.
+func OptHTTPClient(client *http.Client) Opt {
+	return func(o *opts) {
+		o.HTTPClient = client
+	}
+}
+
+// OptNameMapFile reads a two-column, tab-separated file mapping sequence
+// names, e.g. accessions, to the names to use instead, e.g. "NC_000001.11	chr1".
+// The sequences are renamed as by OptNormalizeName, so SeqNames returns the
+// new names, and Get and Len accept both names; a normalization function set
+// with OptNormalizeName is applied after the mapping. Blank lines and lines
+// starting with '#' are ignored. r is read by the constructor, New or
+// NewIndexed, which fails if the file is malformed, maps a name twice, or maps
+// a name that is not a sequence of the FASTA file. Since r is consumed, the
+// Opt must not be passed to more than one constructor.
+func OptNameMapFile(r io.Reader) Opt {
+	return func(o *opts) {
+		o.NameMapFile = r
+	}
+}
+
+// readNameMap parses the input of OptNameMapFile, if any, into o.NameMap, and
+// makes o.NormalizeName apply the mapping before any OptNormalizeName
+// function.
+func readNameMap(o *opts) error {
+	if o.NameMapFile == nil {
+		return nil
+	}
+	nameMap := make(map[string]string)
+	scanner := bufio.NewScanner(o.NameMapFile)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+			return errors.Errorf("name map line %d: expected two tab-separated names, got %q", lineNum, line)
+		}
+		if _, ok := nameMap[fields[0]]; ok {
+			return errors.Errorf("name map line %d: %s is mapped twice", lineNum, fields[0])
+		}
+		nameMap[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	o.NameMapFile, o.NameMap = nil, nameMap
+	if len(nameMap) > 0 {
+		normalize := o.NormalizeName
+		o.NormalizeName = func(seqName string) string {
+			if name, ok := nameMap[seqName]; ok {
+				seqName = name
+			}
+			if normalize != nil {
+				seqName = normalize(seqName)
+			}
+			return seqName
+		}
+	}
+	return nil
+}
+
+// checkNameMap returns an error if the OptNameMapFile mapping parsed by
+// readNameMap maps a sequence name for which exists, given the name in the
+// FASTA file, returns false.
+func checkNameMap(o opts, exists func(seqName string) bool) error {
+	for seqName := range o.NameMap {
+		if !exists(seqName) {
+			return errors.Errorf("OptNameMapFile: sequence not found: %s", seqName)
+		}
+	}
+	return nil
+}
+
 func makeOpts(userOpts ...Opt) opts {
 	var parsedOpts opts
 	for _, userOpt := range userOpts {
@@ -111,25 +447,119 @@ func makeOpts(userOpts ...Opt) opts {
 }
 
 type fasta struct {
-	seqs     map[string]string
-	seqNames []string
+	seqs      map[string]string
+	seqNames  []string
+	normalize func(string) string // see OptNormalizeName, may be nil.
+	enc       Encoding
+	seqEnc    map[string]Encoding // see OptSeqEncoding, may be nil.
+	padN      bool
+	verifyM5  map[string]string // see OptVerifyM5, may be nil.
+	unknown   *[256]bool        // see OptUnknownChars, may be nil.
 }
 
 // New creates a new Fasta that holds all the FASTA data from the given reader
 // in memory. Pass OptIndex, if possible, to read much faster.
 func New(r io.Reader, opts ...Opt) (Fasta, error) {
 	parsedOpts := makeOpts(opts...)
+	if err := readNameMap(&parsedOpts); err != nil {
+		return nil, err
+	}
+	var (
+		f   *fasta
+		err error
+	)
+	// TwoBit sequences are stored in ASCII, and packed by Get. So are all
+	// sequences if their encodings differ.
+	loadOpts := parsedOpts
+	if loadOpts.Enc == TwoBit || len(parsedOpts.SeqEncoding) > 0 {
+		loadOpts.Enc = RawASCII
+	}
 	if len(parsedOpts.Index) == 0 {
-		return newEagerUnindexed(r, parsedOpts)
+		f, err = newEagerUnindexed(r, loadOpts)
+	} else {
+		var index []IndexEntry
+		if index, err = parseIndex(bytes.NewReader(parsedOpts.Index), parsedOpts); err != nil {
+			return nil, err
+		}
+		f, err = newEagerIndexed(r, index, loadOpts)
 	}
-	index, err := parseIndex(bytes.NewReader(parsedOpts.Index))
 	if err != nil {
 		return nil, err
 	}
-	return newEagerIndexed(r, index, parsedOpts)
+	if err := checkNameMap(parsedOpts, func(seqName string) bool {
+		_, ok := f.seqs[seqName]
+		return ok
+	}); err != nil {
+		return nil, err
+	}
+	if parsedOpts.NormalizeName != nil {
+		if err := f.normalizeNames(parsedOpts.NormalizeName); err != nil {
+			return nil, err
+		}
+	}
+	if len(parsedOpts.SeqEncoding) > 0 {
+		if f.seqEnc, err = makeSeqEncoding(parsedOpts, func(seqName string) bool {
+			_, ok := f.seqs[seqName]
+			return ok
+		}); err != nil {
+			return nil, err
+		}
+	}
+	f.enc, f.padN, f.verifyM5 = parsedOpts.Enc, parsedOpts.PadN, parsedOpts.VerifyM5
+	f.unknown = parsedOpts.UnknownChars
+	return f, nil
+}
+
+// makeSeqEncoding validates o.SeqEncoding, and returns it keyed by the
+// normalized sequence names. exists reports whether a normalized sequence name
+// is known.
+func makeSeqEncoding(o opts, exists func(seqName string) bool) (map[string]Encoding, error) {
+	seqEnc := make(map[string]Encoding, len(o.SeqEncoding))
+	for seqName, enc := range o.SeqEncoding {
+		if enc >= EncodingLimit {
+			return nil, errors.Errorf("invalid encoding value for sequence %s: %d", seqName, enc)
+		}
+		if o.NormalizeName != nil {
+			seqName = o.NormalizeName(seqName)
+		}
+		if !exists(seqName) {
+			return nil, errors.Errorf("OptSeqEncoding: sequence not found: %s", seqName)
+		}
+		seqEnc[seqName] = enc
+	}
+	return seqEnc, nil
+}
+
+// padN returns seq followed by as many unknown bases, in the given encoding,
+// as are needed to make it n bytes long.
+func padN(seq string, n uint64, enc Encoding) string {
+	pad := byte('N')
+	if enc == Seq8 || enc == Seq8IUPAC {
+		pad = 15
+	}
+	return seq + strings.Repeat(string(pad), int(n)-len(seq))
+}
+
+// normalizeNames applies fn to the names of all sequences in f, and makes
+// Get and Len apply it to their arguments.
+func (f *fasta) normalizeNames(fn func(string) string) error {
+	seqs := make(map[string]string, len(f.seqs))
+	origNames := make(map[string]string, len(f.seqs))
+	for i, seqName := range f.seqNames {
+		name := fn(seqName)
+		if orig, ok := origNames[name]; ok && orig != seqName {
+			return errors.Errorf("sequence names %s and %s both normalize to %s", orig, seqName, name)
+		}
+		origNames[name] = seqName
+		seqs[name] = f.seqs[seqName]
+		f.seqNames[i] = name
+	}
+	f.seqs = seqs
+	f.normalize = fn
+	return nil
 }
 
-func newEagerUnindexed(r io.Reader, parsedOpts opts) (Fasta, error) {
+func newEagerUnindexed(r io.Reader, parsedOpts opts) (*fasta, error) {
 	f := &fasta{seqs: make(map[string]string)}
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(nil, bufferInitSize)
@@ -137,21 +567,31 @@ func newEagerUnindexed(r io.Reader, parsedOpts opts) (Fasta, error) {
 	// We don't use strings.Builder here, since that would force us to perform
 	// unsafe string -> []byte -> string conversions at the end.
 	seqBuf := make([]byte, 0, bufferInitSize)
+	var (
+		firstLine []byte // first line, if it precedes any header.
+		nLines    int
+	)
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		if len(line) == 0 {
 			continue
 		}
+		if nLines++; seqName == "" {
+			switch nLines {
+			case 1:
+				firstLine = append([]byte(nil), line...)
+			case 3:
+				if isFASTQStart(firstLine, line) {
+					return nil, ErrLooksLikeFASTQ
+				}
+			}
+		}
 		if line[0] == '>' { // Start a new sequence.
 			if len(seqBuf) != 0 { // We need to store the previous sequence first.
 				if seqName == "" {
 					return nil, errors.Errorf("malformed FASTA file")
 				}
-				if parsedOpts.Enc == CleanASCII {
-					biosimd.CleanASCIISeqInplace(seqBuf)
-				} else if parsedOpts.Enc == Seq8 {
-					biosimd.ASCIIToSeq8Inplace(seqBuf)
-				}
+				encodeInplace(seqBuf, parsedOpts.Enc)
 				f.seqs[seqName] = string(seqBuf)
 				f.seqNames = append(f.seqNames, seqName)
 				seqBuf = seqBuf[:0]
@@ -164,11 +604,7 @@ func newEagerUnindexed(r io.Reader, parsedOpts opts) (Fasta, error) {
 	if scanner.Err() != nil {
 		return nil, errors.Wrap(scanner.Err(), "couldn't read FASTA data")
 	}
-	if parsedOpts.Enc == CleanASCII {
-		biosimd.CleanASCIISeqInplace(seqBuf)
-	} else if parsedOpts.Enc == Seq8 {
-		biosimd.ASCIIToSeq8Inplace(seqBuf)
-	}
+	encodeInplace(seqBuf, parsedOpts.Enc)
 	f.seqs[seqName] = string(seqBuf)
 	f.seqNames = append(f.seqNames, seqName)
 	return f, nil
@@ -176,22 +612,53 @@ func newEagerUnindexed(r io.Reader, parsedOpts opts) (Fasta, error) {
 
 // Get implements Fasta.Get().
 func (f *fasta) Get(seqName string, start, end uint64) (string, error) {
+	if f.normalize != nil {
+		seqName = f.normalize(seqName)
+	}
 	s, ok := f.seqs[seqName]
 	if !ok {
 		return "", errors.Errorf("sequence not found: %s", seqName)
 	}
-	if end <= start {
-		return "", fmt.Errorf("start must be less than end")
+	if err := checkRange(start, end); err != nil {
+		return "", err
+	}
+	enc := f.enc
+	if e, ok := f.seqEnc[seqName]; ok {
+		enc = e
+	}
+	if f.padN && end > uint64(len(s)) {
+		var seq string
+		if start < uint64(len(s)) {
+			seq = s[start:]
+		}
+		if f.seqEnc != nil && enc != TwoBit {
+			seq = encodeCached(seq, enc)
+		}
+		seq = padN(seq, end-start, enc)
+		if enc == TwoBit {
+			seq = packTwoBit(seq)
+		}
+		return seq, nil
 	}
 	if start < 0 || end > uint64(len(s)) {
 		return "", errors.Errorf("invalid query range %d - %d for sequence %s with length %d",
 			start, end, seqName, len(s))
 	}
+	if f.seqEnc != nil {
+		// Sequences are held in RawASCII.
+		return encodeCached(s[start:end], enc), nil
+	}
+	if enc == TwoBit {
+		return packTwoBit(s[start:end]), nil
+	}
 	return s[start:end], nil
 }
 
 // Len implements Fasta.Len().
 func (f *fasta) Len(seq string) (uint64, error) {
+	if f.normalize != nil {
+		seq = f.normalize(seq)
+	}
 	s, ok := f.seqs[seq]
 	if !ok {
 		return 0, errors.Errorf("sequence not found: %s", seq)
@@ -4,34 +4,59 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/Schaudge/grailbase/unsafe"
-	"github.com/Schaudge/grailbio/biosimd"
 )
 
-func newEagerIndexed(fastaR io.Reader, index []indexEntry, parsedOpts opts) (Fasta, error) {
+func newEagerIndexed(fastaR io.Reader, index []IndexEntry, parsedOpts opts) (*fasta, error) {
 	var entireLen uint64
 	entireSeqStarts := make([]uint64, len(index))
 	for e, entry := range index {
 		entireSeqStarts[e] = entireLen
-		entireLen += entry.length
+		entireLen += entry.Length
 	}
 	entire := make([]byte, entireLen)
 
-	var fileOffset uint64
+	// Read the sequences in file order, which may differ from the index order
+	// if the index was edited.
+	order := make([]int, len(index))
+	for e := range order {
+		order[e] = e
+	}
+	sort.SliceStable(order, func(i, j int) bool { return index[order[i]].Offset < index[order[j]].Offset })
+
+	var (
+		fileOffset uint64
+		prevName   string
+	)
 	bufR := bufio.NewReaderSize(fastaR, bufferInitSize)
-	for e, entry := range index {
-		n, err := bufR.Discard(int(entry.offset - fileOffset))
+	for _, e := range order {
+		entry := index[e]
+		if entry.Offset < fileOffset {
+			// The entry overlaps the previous one, so we need to go back.
+			seeker, ok := fastaR.(io.Seeker)
+			if !ok {
+				return nil, fmt.Errorf("fasta.NewIndexedAll: index entries for %s and %s overlap", prevName, entry.Name)
+			}
+			if _, err := seeker.Seek(int64(entry.Offset), io.SeekStart); err != nil {
+				return nil, fmt.Errorf("fasta.NewIndexedAll: seeking seq: %v", err)
+			}
+			bufR.Reset(fastaR)
+			fileOffset = entry.Offset
+		}
+		n, err := bufR.Discard(int(entry.Offset - fileOffset))
 		fileOffset += uint64(n)
 		if err != nil {
 			return nil, fmt.Errorf("fasta.NewIndexedAll: seeking seq: %v", err)
 		}
+		prevName = entry.Name
 		var basesRead uint64
-		for basesRead < entry.length {
+		for basesRead < entry.Length {
 			// Compute length of the next line (may be partial if it's the last).
-			nextBasesRead := basesRead + entry.lineBase
-			if nextBasesRead > entry.length {
-				nextBasesRead = entry.length
+			nextBasesRead := basesRead + entry.LineBase
+			if nextBasesRead > entry.Length {
+				nextBasesRead = entry.Length
 			}
 			lineBases := nextBasesRead - basesRead
 
@@ -46,8 +71,8 @@ func newEagerIndexed(fastaR io.Reader, index []indexEntry, parsedOpts opts) (Fas
 			basesRead += lineBases
 
 			// Skip line terminator(s) unless we're at the end of the sequence.
-			if basesRead < entry.length {
-				n, err := bufR.Discard(int(entry.lineWidth - entry.lineBase))
+			if basesRead < entry.Length {
+				n, err := bufR.Discard(int(entry.LineWidth - entry.LineBase))
 				fileOffset += uint64(n)
 				if err != nil {
 					return nil, fmt.Errorf("fasta.NewIndexedAll: seeking line: %v", err)
@@ -56,20 +81,16 @@ func newEagerIndexed(fastaR io.Reader, index []indexEntry, parsedOpts opts) (Fas
 		}
 	}
 
-	if parsedOpts.Enc == CleanASCII {
-		biosimd.CleanASCIISeqInplace(entire)
-	} else if parsedOpts.Enc == Seq8 {
-		biosimd.ASCIIToSeq8Inplace(entire)
-	}
+	encodeInplace(entire, parsedOpts.Enc)
 
 	fa := fasta{
 		seqs:     make(map[string]string, len(index)),
 		seqNames: make([]string, 0, len(index)),
 	}
 	for e, entry := range index {
-		seqBytes := entire[entireSeqStarts[e] : entireSeqStarts[e]+entry.length]
-		fa.seqs[entry.name] = unsafe.BytesToString(seqBytes)
-		fa.seqNames = append(fa.seqNames, entry.name)
+		seqBytes := entire[entireSeqStarts[e] : entireSeqStarts[e]+entry.Length]
+		fa.seqs[entry.Name] = unsafe.BytesToString(seqBytes)
+		fa.seqNames = append(fa.seqNames, entry.Name)
 	}
 	return &fa, nil
 }
@@ -2,99 +2,467 @@ package fasta
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 
-	"github.com/Schaudge/grailbase/must"
+	"github.com/Schaudge/grailbase/unsafe"
 	"github.com/Schaudge/grailbio/biosimd"
 )
 
-type indexEntry struct {
-	name      string
-	length    uint64
-	offset    uint64
-	lineBase  uint64
-	lineWidth uint64
+// IndexEntry describes the location and line geometry of one sequence in a
+// FASTA file, as recorded in one line of its index.
+type IndexEntry struct {
+	// Name is the sequence name.
+	Name string
+	// Length is the number of bases in the sequence.
+	Length uint64
+	// Offset is the byte offset of the first base of the sequence in the FASTA
+	// file.
+	Offset uint64
+	// LineBase is the number of bases per line.
+	LineBase uint64
+	// LineWidth is the number of bytes per line, including the line
+	// terminator.
+	LineWidth uint64
 }
 
 // Index files consist of one tab-separated line per sequence in the associated
 // FASTA file.  The format is: "<sequence name>\t<length>\t<byte
 // offset>\t<bases per line>\t<bytes per line>".
-// For example: "chr3\t12345\t9000\t80\t81".
-var indexRegExp = regexp.MustCompile(`(\S+)\t(\d+)\t(\d+)\t(\d+)\t(\d+)`)
+// For example: "chr3\t12345\t9000\t80\t81". Further columns, such as the
+// quality offset of FASTQ indexes, are allowed.
+var indexRegExp = regexp.MustCompile(`^([^\t]+)\t(\d+)\t(\d+)\t(\d+)\t(\d+)(?:\t.*)?$`)
 
 type indexedFasta struct {
-	seqs      map[string]indexEntry
-	seqNames  []string // returned by SeqNames()
+	// served is updated atomically, so it comes first to keep it 64-bit
+	// aligned.
+	served    uint64       // bases returned by GetEnc; see OptByteQuota.
+	index     atomic.Value // *seqIndex; replaced by WatchIndex.
 	opts      opts
 	reader    io.ReadSeeker
+	readerAt  io.ReaderAt         // if set, used by GetEnc without holding mutex.
+	seqEnc    map[string]Encoding // see OptSeqEncoding, may be nil.
+	readerOff int64               // current offset of reader, or -1 if unknown.
 	bufOff    int64
-	buf       []byte // caches file contents starting at bufOff.
-	resultBuf []byte // temp for concatenating multi-line sequences.
+	buf       []byte            // caches file contents starting at bufOff.
+	resultBuf []byte            // temp for concatenating multi-line sequences.
+	cache     map[string]string // see Prefetch; replaced, never modified.
+	diskCache *diskCache        // see OptDiskCache, may be nil.
+	release   func()            // if set, called by Close, e.g. to free a decoder.
+	closed    bool
 	mutex     sync.Mutex
 }
 
+// seqIndex holds the parsed index of an indexedFasta. It is never modified
+// once built.
+type seqIndex struct {
+	seqs      map[string]IndexEntry
+	seqNames  []string     // copied by SeqNames()
+	fileOrder []string     // copied by IndexFileOrder()
+	entries   []IndexEntry // entries in SeqNames() order.
+	checksum  string       // digest of the entries; keys OptDiskCache.
+	parsed    []IndexEntry // entries as parsed, in index file order.
+}
+
+// readBufs holds the buffers of an indexedFasta while they are in bufPool.
+type readBufs struct {
+	buf, resultBuf []byte
+}
+
+// bufPool holds the buffers of closed indexedFastas created with
+// OptSharedBufferPool, for reuse by new ones.
+var bufPool = sync.Pool{New: func() interface{} { return &readBufs{} }}
+
+// IndexSummary holds summary statistics of a FASTA index.
+type IndexSummary struct {
+	// NumSeqs is the number of sequences.
+	NumSeqs int
+	// TotalLength is the sum of the sequence lengths.
+	TotalLength uint64
+	// MinLen and MaxLen are the lengths of the shortest and longest sequence.
+	MinLen, MaxLen uint64
+	// LineWidths lists the distinct line widths (IndexEntry.LineWidth) of the
+	// sequences, in increasing order.
+	LineWidths []uint64
+}
+
+// Indexed is a Fasta that reads sequence data on demand from an indexed FASTA
+// file.
+type Indexed interface {
+	Fasta
+
+	// GetEnc is like Get, but returns the sequence in the given encoding
+	// instead of the one specified at construction. GetEnc is thread-safe.
+	GetEnc(seqName string, start, end uint64, enc Encoding) (string, error)
+
+	// ImpliedFileSize returns the size, in bytes, of the FASTA file implied by
+	// the index: the largest end offset, including the line terminator, of the
+	// last line of any sequence. It does not access the FASTA file. Comparing
+	// it to the actual file size detects mismatched indexes; note that a file
+	// lacking a final newline is shorter by the length of one terminator.
+	ImpliedFileSize() int64
+
+	// ForEachSeq calls fn with the name and length of each sequence, in
+	// SeqNames() order, until fn returns false.
+	ForEachSeq(fn func(name string, length uint64) bool)
+
+	// Summary returns summary statistics of the index. It does not access the
+	// FASTA file.
+	Summary() IndexSummary
+
+	// SeqRawReader returns a reader of the bytes of the given sequence as
+	// they appear in the FASTA file, including line terminators but not the
+	// header line. This allows copying sequences to a new FASTA file without
+	// decoding them. The reader shares the underlying FASTA reader with the
+	// Fasta, so concurrent Gets interleave with, but do not corrupt, its reads.
+	SeqRawReader(seqName string) (io.Reader, error)
+
+	// LineAlignedSpan returns the byte range [fileStart, fileEnd) of the FASTA
+	// file that Get reads for the bases [start, end) of the given sequence,
+	// and the position within its line of the byte at fileStart. Skipping the
+	// bytes at line positions at or past the sequence's line length
+	// (IndexEntry.LineBase) yields the bases. This lets callers do their own
+	// reads and decoding using the index geometry. It does not access the
+	// FASTA file.
+	LineAlignedSpan(seqName string, start, end uint64) (fileStart, fileEnd int64, firstLinePos uint64, err error)
+
+	// LineRange returns the 0-based indices, counted from the first line after
+	// the header, of the first and last lines of the given sequence that hold
+	// the bases [start, end), as implied by the index line length. This helps
+	// diagnose indexes with the wrong geometry. It does not access the FASTA
+	// file.
+	LineRange(seqName string, start, end uint64) (firstLine, lastLine uint64, err error)
+
+	// IndexFileOrder returns the sequence names in the order of their lines in
+	// the index file. Unlike SeqNames, which is sorted by offset in the FASTA
+	// file, it reflects how the index was written, so comparing the two
+	// reveals indexes whose lines are out of file order. A name listed more
+	// than once appears at its first position.
+	IndexFileOrder() []string
+
+	// GetLines is like Get, but returns the bases split at the line breaks of
+	// the FASTA file, as implied by the index line length: the first and last
+	// lines may be partial, and the others hold IndexEntry.LineBase bases
+	// each. This lets callers reproduce the formatting of a region. It
+	// returns an error if the sequence uses the TwoBit encoding.
+	GetLines(seqName string, start, end uint64) ([]string, error)
+
+	// EstimateBytes returns the number of bytes that Get allocates to serve
+	// the bases [start, end) of the given sequence: its read buffer, the
+	// buffer in which the bases are decoded, and the returned string. This
+	// is an upper bound, since buffers are reused between calls and cached
+	// sequences are not read. It lets callers reject or stream oversized
+	// queries. It does not access the FASTA file.
+	EstimateBytes(seqName string, start, end uint64) (int64, error)
+
+	// AppendTo is like Get, but appends the bases to dst, decoding them there,
+	// and returns the extended slice, which saves allocating and copying a
+	// result string. On error, dst is returned unchanged.
+	AppendTo(dst []byte, seqName string, start, end uint64) ([]byte, error)
+
+	// MarshalIndex returns the index in a compact binary form, from which
+	// NewIndexedFromMarshaled recreates the Fasta without parsing the text
+	// index. The entries are stored as parsed, before OptNormalizeName is
+	// applied, in index file order.
+	MarshalIndex() ([]byte, error)
+
+	// SameUnderlyingBytes reports whether the index entries of sequences a and
+	// b describe exactly the same bytes of the FASTA file, i.e., have the same
+	// offset, length and line geometry, as when a sequence is listed under two
+	// names. It does not access the FASTA file.
+	SameUnderlyingBytes(a, b string) (bool, error)
+
+	// Prefetch reads the given sequences into an in-memory cache, from which
+	// Get then serves them. The sequences are read in chunks, without blocking
+	// concurrent Gets for longer than a single chunk read; once all of them are
+	// read, a new cache holding them, along with the contents of the current
+	// one, replaces the current one atomically. Until then, Gets see the old
+	// cache. While Prefetch refreshes sequences that are already cached, both
+	// copies are held in memory, so peak usage can reach twice the size of the
+	// refreshed sequences.
+	Prefetch(seqNames ...string) error
+
+	// Reset drops the cache built by Prefetch.
+	Reset()
+
+	// WatchIndex checks the index file at path every interval and, when its
+	// modification time, size or identity changes, as when a symlink is
+	// repointed, reparses it and atomically replaces the index of the Fasta.
+	// The cache built by Prefetch is dropped, as by Reset. The FASTA reader
+	// is not reopened, so the new index must describe the same FASTA data.
+	// Errors while checking or reloading the index are passed to onError, if
+	// it is not nil, and leave the current index in place; so does a new
+	// index that lacks a sequence named by OptNameMapFile or
+	// OptSeqEncoding. Calling stop ends the watch. It returns an error if
+	// interval is not positive.
+	WatchIndex(path string, interval time.Duration, onError func(error)) (stop func(), err error)
+
+	// BytesServed returns the total number of bases returned by Get and
+	// GetEnc so far. See OptByteQuota.
+	BytesServed() uint64
+
+	// Close releases the buffers held by the Fasta. If it was created with
+	// OptSharedBufferPool, they are returned to the shared pool. Get must not
+	// be called after Close. Close does not close the underlying reader.
+	Close() error
+}
+
 // NewIndexed creates a new Fasta that can perform efficient random lookups
 // using the provided index, without reading the data into memory.
 //
 // Note: Callers that expect to read many or all of the FASTA file sequences
 // should use New(..., OptIndex(...)) instead.
-func NewIndexed(fasta io.ReadSeeker, index io.Reader, opts ...Opt) (Fasta, error) {
-	entries, err := parseIndex(index)
+func NewIndexed(fasta io.ReadSeeker, index io.Reader, opts ...Opt) (Indexed, error) {
+	parsedOpts := makeOpts(opts...)
+	entries, err := parseIndex(index, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	return newLazyIndexed(fasta, entries, parsedOpts)
+}
+
+// NewIndexedFromReaderAt creates a new Fasta that performs random lookups on
+// the size bytes of FASTA data accessible through r, such as an in-memory
+// blob. It scans the data once to build the index in memory, so no separate
+// index file is needed. Gets are served with r.ReadAt, and proceed in
+// parallel, as for NewIndexedConcurrent, so r must support concurrent ReadAt
+// calls.
+func NewIndexedFromReaderAt(r io.ReaderAt, size int64, opts ...Opt) (Indexed, error) {
+	data := io.NewSectionReader(r, 0, size)
+	var index bytes.Buffer
+	if err := GenerateIndex(&index, data); err != nil {
+		return nil, err
+	}
+	parsedOpts := makeOpts(opts...)
+	entries, err := parseIndex(&index, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	f, err := newLazyIndexed(io.NewSectionReader(r, 0, size), entries, parsedOpts)
 	if err != nil {
 		return nil, err
 	}
-	return newLazyIndexed(fasta, entries, makeOpts(opts...))
+	f.readerAt = data
+	return f, nil
+}
+
+// NewIndexedFromEntries is like NewIndexed, but takes the entries of the
+// index, e.g. as built by a program, instead of its *.fai text. The entries
+// need not be sorted by offset, but they must have distinct, non-empty names
+// and valid line geometry, and the sequences they describe must not overlap in
+// the file. Of the options, OptFullLineName is not relevant.
+func NewIndexedFromEntries(fasta io.ReadSeeker, entries []IndexEntry, opts ...Opt) (Indexed, error) {
+	if err := validateIndexEntries(entries); err != nil {
+		return nil, err
+	}
+	return newLazyIndexed(fasta, append([]IndexEntry(nil), entries...), makeOpts(opts...))
+}
+
+// validateIndexEntries checks each of entries with validateIndexEntry, and
+// checks that their names are distinct and non-empty, and that their
+// sequences do not overlap.
+func validateIndexEntries(entries []IndexEntry) error {
+	names := make(map[string]bool, len(entries))
+	for _, ent := range entries {
+		if ent.Name == "" {
+			return fmt.Errorf("invalid index entry at offset %d: empty name", ent.Offset)
+		}
+		if names[ent.Name] {
+			return fmt.Errorf("invalid index: sequence %s is listed twice", ent.Name)
+		}
+		names[ent.Name] = true
+		if err := validateIndexEntry(ent); err != nil {
+			return err
+		}
+	}
+	sorted := append([]IndexEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+	for i := 1; i < len(sorted); i++ {
+		if prev, ent := sorted[i-1], sorted[i]; prev.end() > ent.Offset {
+			return fmt.Errorf("invalid index: sequences %s and %s overlap", prev.Name, ent.Name)
+		}
+	}
+	return nil
 }
 
-func newLazyIndexed(fasta io.ReadSeeker, index []indexEntry, parsedOpts opts) (Fasta, error) {
+func newLazyIndexed(fasta io.ReadSeeker, index []IndexEntry, parsedOpts opts) (*indexedFasta, error) {
+	if err := readNameMap(&parsedOpts); err != nil {
+		return nil, err
+	}
 	f := indexedFasta{
-		seqs:   make(map[string]indexEntry),
-		reader: fasta,
-		opts:   parsedOpts,
+		reader:    fasta,
+		readerOff: -1,
+		opts:      parsedOpts,
 	}
-	for _, entry := range index {
-		f.seqs[entry.name] = entry
+	idx, seqEnc, err := newCheckedSeqIndex(index, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	f.index.Store(idx)
+	f.seqEnc = seqEnc
+	if parsedOpts.DiskCacheDir != "" {
+		if f.diskCache, err = newDiskCache(parsedOpts.DiskCacheDir, parsedOpts.DiskCacheMaxBytes); err != nil {
+			return nil, err
+		}
 	}
-	f.seqNames = make([]string, 0, len(f.seqs))
-	for seqName := range f.seqs {
-		f.seqNames = append(f.seqNames, seqName)
+	if parsedOpts.SharedBufferPool {
+		bufs := bufPool.Get().(*readBufs)
+		f.buf, f.resultBuf = bufs.buf[:0], bufs.resultBuf[:0]
+	}
+	if parsedOpts.InitialSeek != "" {
+		ent, ok := f.lookup(parsedOpts.InitialSeek)
+		if !ok {
+			return nil, fmt.Errorf("sequence not found in index: %s", parsedOpts.InitialSeek)
+		}
+		if err := f.seek(int64(ent.Offset)); err != nil {
+			return nil, err
+		}
 	}
-	sort.SliceStable(f.seqNames, func(i, j int) bool {
-		return f.seqs[f.seqNames[i]].offset < f.seqs[f.seqNames[j]].offset
-	})
 	return &f, nil
 }
 
-func parseIndex(r io.Reader) ([]indexEntry, error) {
+// newCheckedSeqIndex is like newSeqIndex, but also checks that the sequences
+// named by OptNameMapFile and OptSeqEncoding are in the index. It returns the
+// encodings set with OptSeqEncoding keyed by the normalized sequence names,
+// or nil if there are none.
+func newCheckedSeqIndex(index []IndexEntry, o opts) (*seqIndex, map[string]Encoding, error) {
+	names := make(map[string]bool, len(o.NameMap))
+	for _, ent := range index {
+		if _, ok := o.NameMap[ent.Name]; ok {
+			names[ent.Name] = true
+		}
+	}
+	if err := checkNameMap(o, func(seqName string) bool { return names[seqName] }); err != nil {
+		return nil, nil, err
+	}
+	idx, err := newSeqIndex(index, o.NormalizeName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(o.SeqEncoding) == 0 {
+		return idx, nil, nil
+	}
+	seqEnc, err := makeSeqEncoding(o, func(seqName string) bool {
+		_, ok := idx.seqs[seqName]
+		return ok
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return idx, seqEnc, nil
+}
+
+// newSeqIndex builds a seqIndex from the entries of a parsed index, renaming
+// the sequences with normalize if it is not nil.
+func newSeqIndex(index []IndexEntry, normalize func(string) string) (*seqIndex, error) {
+	idx := seqIndex{seqs: make(map[string]IndexEntry), checksum: indexChecksum(index), parsed: index}
+	origNames := make(map[string]string, len(index))
+	idx.seqNames = make([]string, 0, len(index))
+	for _, entry := range index {
+		if normalize != nil {
+			name := normalize(entry.Name)
+			if orig, ok := origNames[name]; ok && orig != entry.Name {
+				return nil, fmt.Errorf("sequence names %s and %s both normalize to %s", orig, entry.Name, name)
+			}
+			origNames[name] = entry.Name
+			entry.Name = name
+		}
+		if _, ok := idx.seqs[entry.Name]; !ok {
+			idx.seqNames = append(idx.seqNames, entry.Name)
+		}
+		idx.seqs[entry.Name] = entry
+	}
+	idx.fileOrder = append([]string(nil), idx.seqNames...)
+	// Sequences at the same offset keep their index order.
+	sort.SliceStable(idx.seqNames, func(i, j int) bool {
+		return idx.seqs[idx.seqNames[i]].Offset < idx.seqs[idx.seqNames[j]].Offset
+	})
+	idx.entries = make([]IndexEntry, len(idx.seqNames))
+	for i, seqName := range idx.seqNames {
+		idx.entries[i] = idx.seqs[seqName]
+	}
+	return &idx, nil
+}
+
+// ReadIndex parses a FASTA index (*.fai). It returns an error, rather than
+// panicking, for any malformed input, so it is safe to use on untrusted data.
+// Blank lines and lines starting with '#' are ignored. Of the options, only
+// OptFullLineName is relevant.
+func ReadIndex(r io.Reader, opts ...Opt) ([]IndexEntry, error) {
+	return parseIndex(r, makeOpts(opts...))
+}
+
+func parseIndex(r io.Reader, parsedOpts opts) ([]IndexEntry, error) {
 	scanner := bufio.NewScanner(r)
 	scanner.Split(bufio.ScanLines)
-	var entries []indexEntry
+	var entries []IndexEntry
 	for scanner.Scan() {
+		// Skip the blank and comment lines of some generated indexes.
+		if line := scanner.Text(); strings.TrimSpace(line) == "" || line[0] == '#' {
+			continue
+		}
 		matches := indexRegExp.FindStringSubmatch(scanner.Text())
 		if len(matches) != 6 {
 			return nil, fmt.Errorf("Invalid index line: %s", scanner.Text())
 		}
-		ent := indexEntry{}
-		ent.name = matches[1]
-		var err error
-		ent.length, err = strconv.ParseUint(matches[2], 10, 64)
-		must.Nil(err)
-		ent.offset, err = strconv.ParseUint(matches[3], 10, 64)
-		must.Nil(err)
-		ent.lineBase, err = strconv.ParseUint(matches[4], 10, 64)
-		must.Nil(err)
-		ent.lineWidth, err = strconv.ParseUint(matches[5], 10, 64)
-		must.Nil(err)
+		ent := IndexEntry{}
+		ent.Name = matches[1]
+		if !parsedOpts.FullLineName && strings.IndexFunc(ent.Name, unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("Invalid index line: %s: sequence name contains whitespace", scanner.Text())
+		}
+		fields := [...]*uint64{&ent.Length, &ent.Offset, &ent.LineBase, &ent.LineWidth}
+		for i, field := range fields {
+			var err error
+			if *field, err = strconv.ParseUint(matches[i+2], 10, 64); err != nil {
+				return nil, fmt.Errorf("Invalid index line: %s: %v", scanner.Text(), err)
+			}
+		}
+		if err := validateIndexEntry(ent); err != nil {
+			return nil, err
+		}
 		entries = append(entries, ent)
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read index: %v", err)
+	}
 	return entries, nil
 }
 
+// validateIndexEntry checks that the geometry of ent is usable by Get, i.e.,
+// that computing the file offsets of any of its bases cannot divide by zero or
+// overflow.
+func validateIndexEntry(ent IndexEntry) error {
+	if ent.Length > 0 && ent.LineBase == 0 {
+		return fmt.Errorf("invalid index entry for %s: zero bases per line", ent.Name)
+	}
+	if ent.LineWidth < ent.LineBase {
+		return fmt.Errorf("invalid index entry for %s: line width %d is less than bases per line %d",
+			ent.Name, ent.LineWidth, ent.LineBase)
+	}
+	var nLines uint64
+	if ent.LineBase > 0 {
+		nLines = ent.Length / ent.LineBase
+	}
+	// The sequence must end before math.MaxInt64, since file offsets are int64.
+	const maxOffset = uint64(math.MaxInt64)
+	if ent.Offset > maxOffset || ent.Length > maxOffset-ent.Offset ||
+		(nLines > 0 && ent.LineWidth-ent.LineBase > (maxOffset-ent.Offset-ent.Length)/nLines) {
+		return fmt.Errorf("invalid index entry for %s: offset %d and length %d are out of range",
+			ent.Name, ent.Offset, ent.Length)
+	}
+	return nil
+}
+
 // FaiToReferenceLengths reads in a fasta fai file and returns a map of
 // reference name to reference length. This doesn't require reading in the fasta
 // itself.
@@ -114,41 +482,315 @@ func FaiToReferenceLengths(index io.Reader) (map[string]uint64, error) {
 	return newMap, nil
 }
 
+// currentIndex returns the current index of f.
+func (f *indexedFasta) currentIndex() *seqIndex {
+	return f.index.Load().(*seqIndex)
+}
+
+// lookup returns the index entry of the given sequence, after applying
+// OptNormalizeName to the name.
+func (f *indexedFasta) lookup(seqName string) (IndexEntry, bool) {
+	if normalize := f.opts.NormalizeName; normalize != nil {
+		seqName = normalize(seqName)
+	}
+	ent, ok := f.currentIndex().seqs[seqName]
+	return ent, ok
+}
+
 // Len implements Fasta.Len().
 func (f *indexedFasta) Len(seqName string) (uint64, error) {
-	ent, ok := f.seqs[seqName]
+	ent, ok := f.lookup(seqName)
 	if !ok {
 		return 0, fmt.Errorf("sequence not found in index: %s", seqName)
 	}
-	return ent.length, nil
+	return ent.Length, nil
+}
+
+// ImpliedFileSize implements Indexed.ImpliedFileSize().
+func (f *indexedFasta) ImpliedFileSize() int64 {
+	var size uint64
+	for _, ent := range f.currentIndex().seqs {
+		if end := ent.end(); end > size {
+			size = end
+		}
+	}
+	return int64(size)
+}
+
+// span returns the range [offset, offset+capacity) of file bytes, including
+// line terminators, that holds the bases [start, end) of the sequence, and the
+// position within its line of the byte at offset.
+func (e IndexEntry) span(start, end uint64) (offset, capacity, linePos uint64) {
+	// Start the read at a byte offset allowing for the presence of newline
+	// characters.
+	charsPerNewline := e.LineWidth - e.LineBase
+	offset = e.Offset + start + charsPerNewline*(start/e.LineBase)
+
+	// Figure out how many characters (including newlines) we should read.
+	firstLineBases := e.LineBase - (start % e.LineBase)
+	newlinesToRead := uint64(0)
+	if end-start > firstLineBases {
+		newlinesToRead = 1 + (end-start-firstLineBases)/e.LineBase
+	}
+	capacity = end - start + newlinesToRead*charsPerNewline
+	linePos = (offset - e.Offset) % e.LineWidth
+	return
+}
+
+// end returns the file offset just past the line terminator of the last line
+// of the sequence.
+func (e IndexEntry) end() uint64 {
+	if e.Length == 0 {
+		return e.Offset
+	}
+	nLines := (e.Length + e.LineBase - 1) / e.LineBase
+	return e.Offset + e.Length + nLines*(e.LineWidth-e.LineBase)
+}
+
+// ForEachSeq implements Indexed.ForEachSeq().
+func (f *indexedFasta) ForEachSeq(fn func(name string, length uint64) bool) {
+	for _, ent := range f.currentIndex().entries {
+		if !fn(ent.Name, ent.Length) {
+			return
+		}
+	}
+}
+
+// Summary implements Indexed.Summary().
+func (f *indexedFasta) Summary() IndexSummary {
+	entries := f.currentIndex().entries
+	s := IndexSummary{NumSeqs: len(entries)}
+	lineWidths := make(map[uint64]bool)
+	for i, ent := range entries {
+		s.TotalLength += ent.Length
+		if i == 0 || ent.Length < s.MinLen {
+			s.MinLen = ent.Length
+		}
+		if ent.Length > s.MaxLen {
+			s.MaxLen = ent.Length
+		}
+		if !lineWidths[ent.LineWidth] {
+			lineWidths[ent.LineWidth] = true
+			s.LineWidths = append(s.LineWidths, ent.LineWidth)
+		}
+	}
+	sort.Slice(s.LineWidths, func(i, j int) bool { return s.LineWidths[i] < s.LineWidths[j] })
+	return s
+}
+
+// Close implements Indexed.Close().
+func (f *indexedFasta) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if f.opts.SharedBufferPool {
+		bufPool.Put(&readBufs{buf: f.buf, resultBuf: f.resultBuf})
+	}
+	f.buf, f.resultBuf, f.cache = nil, nil, nil
+	if f.release != nil {
+		f.release()
+	}
+	return nil
+}
+
+// LineAlignedSpan implements Indexed.LineAlignedSpan().
+func (f *indexedFasta) LineAlignedSpan(seqName string, start, end uint64) (fileStart, fileEnd int64, firstLinePos uint64, err error) {
+	ent, err := f.checkGet(seqName, start, end)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	offset, capacity, linePos := ent.span(start, end)
+	return int64(offset), int64(offset + capacity), linePos, nil
+}
+
+// LineRange implements Indexed.LineRange().
+func (f *indexedFasta) LineRange(seqName string, start, end uint64) (firstLine, lastLine uint64, err error) {
+	ent, err := f.checkGet(seqName, start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start / ent.LineBase, (end - 1) / ent.LineBase, nil
 }
 
+// GetLines implements Indexed.GetLines().
+func (f *indexedFasta) GetLines(seqName string, start, end uint64) ([]string, error) {
+	enc := f.seqEncoding(seqName)
+	if enc == TwoBit {
+		return nil, fmt.Errorf("GetLines does not support the TwoBit encoding")
+	}
+	ent, err := f.checkGet(seqName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := f.GetEnc(seqName, start, end, enc)
+	if err != nil {
+		return nil, err
+	}
+	firstLine, lastLine := start/ent.LineBase, (end-1)/ent.LineBase
+	lines := make([]string, 0, lastLine-firstLine+1)
+	for pos := start; pos < end; {
+		lineEnd := (pos/ent.LineBase + 1) * ent.LineBase
+		if lineEnd > end {
+			lineEnd = end
+		}
+		lines = append(lines, seq[pos-start:lineEnd-start])
+		pos = lineEnd
+	}
+	return lines, nil
+}
+
+// EstimateBytes implements Indexed.EstimateBytes().
+func (f *indexedFasta) EstimateBytes(seqName string, start, end uint64) (int64, error) {
+	ent, err := f.checkGet(seqName, start, end)
+	if err != nil {
+		return 0, err
+	}
+	_, capacity, _ := ent.span(start, end)
+	readBuf := capacity
+	if readBuf < minReadBufSize && !f.opts.NoBuffer {
+		readBuf = minReadBufSize
+	}
+	n := end - start
+	result := n
+	if f.seqEncoding(seqName) == TwoBit {
+		result = (n + 3) / 4
+	}
+	return int64(readBuf + n + result), nil
+}
+
+// SameUnderlyingBytes implements Indexed.SameUnderlyingBytes().
+func (f *indexedFasta) SameUnderlyingBytes(a, b string) (bool, error) {
+	entA, ok := f.lookup(a)
+	if !ok {
+		return false, fmt.Errorf("sequence not found in index: %s", a)
+	}
+	entB, ok := f.lookup(b)
+	if !ok {
+		return false, fmt.Errorf("sequence not found in index: %s", b)
+	}
+	return entA.Offset == entB.Offset && entA.Length == entB.Length &&
+		entA.LineBase == entB.LineBase && entA.LineWidth == entB.LineWidth, nil
+}
+
+// seqRawReader implements Indexed.SeqRawReader.
+type seqRawReader struct {
+	f        *indexedFasta
+	off, end int64 // remaining byte range in the FASTA file.
+}
+
+// rawReadChunk bounds the size of the reads made by seqRawReader.
+const rawReadChunk = 64 * 1024
+
+// SeqRawReader implements Indexed.SeqRawReader().
+func (f *indexedFasta) SeqRawReader(seqName string) (io.Reader, error) {
+	ent, ok := f.lookup(seqName)
+	if !ok {
+		return nil, fmt.Errorf("sequence not found in index: %s", seqName)
+	}
+	return &seqRawReader{f: f, off: int64(ent.Offset), end: int64(ent.end())}, nil
+}
+
+// Read implements io.Reader.
+func (r *seqRawReader) Read(p []byte) (int, error) {
+	if r.off >= r.end {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if n > rawReadChunk {
+		n = rawReadChunk
+	}
+	if int64(n) > r.end-r.off {
+		n = int(r.end - r.off)
+	}
+	r.f.mutex.Lock()
+	defer r.f.mutex.Unlock()
+	if r.f.closed {
+		return 0, fmt.Errorf("SeqRawReader used on a closed Fasta")
+	}
+	buf, err := r.f.read(r.off, n)
+	if err != nil {
+		return 0, err
+	}
+	n = copy(p, buf)
+	r.off += int64(n)
+	if n == 0 {
+		// Only possible with OptNoTrailingNewline, when the file ends before
+		// the final line terminator.
+		r.off = r.end
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// minReadBufSize is the smallest read that indexedFasta makes from its
+// reader, so that nearby Gets are served from the same buffer.
+const minReadBufSize = 8192
+
 // Read range [off, off+n) from the underlying fasta file.
 func (f *indexedFasta) read(off int64, n int) ([]byte, error) {
 	limit := off + int64(n)
 	if off < f.bufOff || limit > f.bufOff+int64(len(f.buf)) {
-		if newOffset, err := f.reader.Seek(off, io.SeekStart); err != nil || newOffset != off {
-			return nil, fmt.Errorf("failed to seek to offset %d: %d, %v", off, newOffset, err)
+		if err := f.seek(off); err != nil {
+			return nil, err
 		}
-		bufSize := 8192
-		if bufSize < n {
+		bufSize := minReadBufSize
+		if bufSize < n || f.opts.NoBuffer {
 			bufSize = n
 		}
+		// See OptBufferGrowth.
+		if g := f.opts.BufferGrowth; g > 1 && cap(f.buf) < bufSize && !f.opts.NoBuffer {
+			f.buf = make([]byte, 0, int(float64(bufSize)*g))
+		}
 		f.resizeBuf(&f.buf, bufSize)
-		bytesRead, err := f.reader.Read(f.buf)
-		if bytesRead < n {
-			return nil, fmt.Errorf("encountered unexpected end of file (bad index? file doesn't end in newline?)")
+		// Use ReadAtLeast, since a single Read may return fewer bytes than
+		// requested, possibly along with io.EOF.
+		bytesRead, err := io.ReadAtLeast(f.reader, f.buf, n)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			f.readerOff = -1
+			return nil, err
 		}
-		if err != nil && err != io.EOF {
+		if bytesRead > len(f.buf) {
+			// The reader violates the io.Reader contract; its position and
+			// the contents of f.buf cannot be trusted.
+			err := fmt.Errorf("reader returned %d bytes for a read of at most %d", bytesRead, len(f.buf))
+			f.readerOff = -1
+			f.buf = f.buf[:0]
 			return nil, err
 		}
+		f.readerOff += int64(bytesRead)
+		if bytesRead < n {
+			if !f.opts.NoTrailingNewline {
+				return nil, fmt.Errorf("encountered unexpected end of file (bad index? file doesn't end in newline?)")
+			}
+			// Return what is available; Get checks that it includes all the
+			// requested bases.
+			limit = off + int64(bytesRead)
+		}
 		f.bufOff = off
 		f.buf = f.buf[:bytesRead]
-		if off < f.bufOff || limit > f.bufOff+int64(len(f.buf)) {
-			panic(off)
-		}
 	}
-	return f.buf[off-f.bufOff : limit-f.bufOff], nil
+	data := f.buf[off-f.bufOff : limit-f.bufOff]
+	if f.opts.NoBuffer {
+		f.buf = nil
+	}
+	return data, nil
+}
+
+// seek positions the underlying reader at off, unless it is already there.
+func (f *indexedFasta) seek(off int64) error {
+	if f.readerOff == off {
+		return nil
+	}
+	newOffset, err := f.reader.Seek(off, io.SeekStart)
+	if err != nil || newOffset != off {
+		f.readerOff = -1
+		return fmt.Errorf("failed to seek to offset %d: %d, %v", off, newOffset, err)
+	}
+	f.readerOff = off
+	return nil
 }
 
 func (f *indexedFasta) resizeBuf(buf *[]byte, n int) {
@@ -159,67 +801,415 @@ func (f *indexedFasta) resizeBuf(buf *[]byte, n int) {
 	}
 }
 
+// parallelDecodeMinBytes is the smallest read that OptDecodeParallel splits
+// across goroutines.
+const parallelDecodeMinBytes = 1 << 20
+
+// stripLines copies the bases held in src, the FASTA file bytes of the
+// sequence described by ent starting at line position linePos, to dst, until
+// either is exhausted. It returns the number of bases copied.
+func stripLines(ent IndexEntry, dst, src []byte, linePos uint64) int {
+	n := 0
+	for i := 0; i < len(src) && n < len(dst); i++ {
+		if linePos < ent.LineBase {
+			dst[n] = src[i]
+			n++
+		}
+		linePos++
+		if linePos == ent.LineWidth {
+			linePos = 0
+		}
+	}
+	return n
+}
+
+// encodeInplace converts the RawASCII bases in seq to the given encoding,
+// except for TwoBit, which is packed separately.
+func encodeInplace(seq []byte, enc Encoding) {
+	switch enc {
+	case CleanASCII:
+		biosimd.CleanASCIISeqInplace(seq)
+	case Seq8:
+		biosimd.ASCIIToSeq8Inplace(seq)
+	case Seq8IUPAC:
+		biosimd.ASCIIToSeq8IUPACInplace(seq)
+	}
+}
+
+// decodeParallel is like stripLines followed by encodeInplace, but splits src
+// at line starts into as many chunks as there are workers, and decodes them
+// concurrently, each into its own part of dst.
+func decodeParallel(ent IndexEntry, dst, src []byte, linePos uint64, enc Encoding, workers int) int {
+	// The first full line starts at src[firstLine], and is preceded by
+	// firstBases bases.
+	var firstLine, firstBases uint64
+	if linePos > 0 {
+		firstLine = ent.LineWidth - linePos
+		if linePos < ent.LineBase {
+			firstBases = ent.LineBase - linePos
+		}
+	}
+	if firstLine >= uint64(len(src)) {
+		n := stripLines(ent, dst, src, linePos)
+		encodeInplace(dst[:n], enc)
+		return n
+	}
+	nLines := (uint64(len(src)) - firstLine + ent.LineWidth - 1) / ent.LineWidth
+	counts := make([]int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		srcStart, dstStart := uint64(0), uint64(0)
+		if w > 0 {
+			line := uint64(w) * nLines / uint64(workers)
+			srcStart, dstStart = firstLine+line*ent.LineWidth, firstBases+line*ent.LineBase
+		}
+		srcEnd, dstEnd := uint64(len(src)), uint64(len(dst))
+		if w < workers-1 {
+			line := uint64(w+1) * nLines / uint64(workers)
+			srcEnd, dstEnd = firstLine+line*ent.LineWidth, firstBases+line*ent.LineBase
+		}
+		if dstEnd > uint64(len(dst)) {
+			dstEnd = uint64(len(dst))
+		}
+		if srcStart >= srcEnd || dstStart >= dstEnd {
+			continue
+		}
+		startPos := uint64(0)
+		if w == 0 {
+			startPos = linePos
+		}
+		wg.Add(1)
+		go func(w int, dst, src []byte, linePos uint64) {
+			defer wg.Done()
+			counts[w] = stripLines(ent, dst, src, linePos)
+			encodeInplace(dst[:counts[w]], enc)
+		}(w, dst[dstStart:dstEnd], src[srcStart:srcEnd], startPos)
+	}
+	wg.Wait()
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+// seqEncoding returns the encoding that Get uses for the given sequence.
+func (f *indexedFasta) seqEncoding(seqName string) Encoding {
+	if f.seqEnc != nil {
+		if normalize := f.opts.NormalizeName; normalize != nil {
+			seqName = normalize(seqName)
+		}
+		if e, ok := f.seqEnc[seqName]; ok {
+			return e
+		}
+	}
+	return f.opts.Enc
+}
+
 // Get implements Fasta.Get().
 func (f *indexedFasta) Get(seqName string, start uint64, end uint64) (string, error) {
+	return f.GetEnc(seqName, start, end, f.seqEncoding(seqName))
+}
+
+// GetEnc implements Indexed.GetEnc().
+func (f *indexedFasta) GetEnc(seqName string, start uint64, end uint64, enc Encoding) (string, error) {
+	if end <= start {
+		return f.getEnc(seqName, start, end, enc)
+	}
+	n := end - start
+	if err := f.reserve(n); err != nil {
+		return "", err
+	}
+	f.warnLargeRead(seqName, start, end)
+	seq, err := f.getEnc(seqName, start, end, enc)
+	if err != nil {
+		atomic.AddUint64(&f.served, -n)
+	}
+	return seq, err
+}
+
+// reserve counts n more bases as served, or returns an error if that would
+// exceed the quota set with OptByteQuota. Callers that then fail to serve
+// them subtract n from f.served.
+func (f *indexedFasta) reserve(n uint64) error {
+	for {
+		served := atomic.LoadUint64(&f.served)
+		if quota := f.opts.ByteQuota; quota > 0 && (served+n > quota || served+n < served) {
+			return fmt.Errorf("%w: %d bases requested, %d of %d served", ErrQuotaExceeded, n, served, quota)
+		}
+		if atomic.CompareAndSwapUint64(&f.served, served, served+n) {
+			return nil
+		}
+	}
+}
+
+// AppendTo implements Indexed.AppendTo().
+func (f *indexedFasta) AppendTo(dst []byte, seqName string, start, end uint64) ([]byte, error) {
+	enc := f.seqEncoding(seqName)
+	if enc == TwoBit || f.opts.PadN || f.readerAt != nil || f.diskCache != nil || end <= start {
+		// These cases build the result as a string anyway.
+		seq, err := f.GetEnc(seqName, start, end, enc)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, seq...), nil
+	}
+	n := end - start
+	if err := f.reserve(n); err != nil {
+		return dst, err
+	}
+	f.warnLargeRead(seqName, start, end)
+	result, err := f.appendEnc(dst, seqName, start, end, enc)
+	if err != nil {
+		atomic.AddUint64(&f.served, -n)
+		return dst, err
+	}
+	return result, nil
+}
+
+// appendEnc appends the bases [start, end) of the given sequence, in the
+// given encoding, which is not TwoBit, to dst, decoding them in place.
+func (f *indexedFasta) appendEnc(dst []byte, seqName string, start, end uint64, enc Encoding) ([]byte, error) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
-	if end <= start {
-		return "", fmt.Errorf("start must be less than end")
+	if f.closed {
+		return nil, fmt.Errorf("Get called on a closed Fasta")
+	}
+	ent, err := f.checkGet(seqName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if seq, ok := f.cache[ent.Name]; ok {
+		return append(dst, encodeCached(seq[start:end], enc)...), nil
+	}
+	offset, capacity, linePos := ent.span(start, end)
+	buffer, err := f.read(int64(offset), int(capacity))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	pos := len(dst)
+	dst = append(dst, make([]byte, end-start)...)
+	if err := f.decodeInto(ent, dst[pos:], buffer, linePos, enc); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// warnLargeRead calls the function set by OptWarnLargeRead if the bases
+// [start, end) of the given sequence, clamped to its length, span more than
+// the threshold bytes of the FASTA file.
+func (f *indexedFasta) warnLargeRead(seqName string, start, end uint64) {
+	if f.opts.WarnRead == nil {
+		return
+	}
+	ent, ok := f.lookup(seqName)
+	if !ok || start >= ent.Length {
+		return
+	}
+	if end > ent.Length {
+		end = ent.Length
+	}
+	if _, capacity, _ := ent.span(start, end); capacity > uint64(f.opts.WarnReadBytes) {
+		f.opts.WarnRead(seqName, int(capacity))
+	}
+}
+
+// BytesServed implements Indexed.BytesServed().
+func (f *indexedFasta) BytesServed() uint64 {
+	return atomic.LoadUint64(&f.served)
+}
+
+// getEnc implements GetEnc, without accounting for the bases served.
+func (f *indexedFasta) getEnc(seqName string, start uint64, end uint64, enc Encoding) (string, error) {
+	if enc >= EncodingLimit {
+		return "", fmt.Errorf("invalid encoding value: %d", enc)
+	}
+	if f.opts.PadN && end > start {
+		if ent, ok := f.lookup(seqName); ok && end > ent.Length {
+			// TwoBit results are packed after padding.
+			innerEnc := enc
+			if enc == TwoBit {
+				innerEnc = RawASCII
+			}
+			var seq string
+			if start < ent.Length {
+				var err error
+				if seq, err = f.getEnc(seqName, start, ent.Length, innerEnc); err != nil {
+					return "", err
+				}
+			}
+			seq = padN(seq, end-start, enc)
+			if enc == TwoBit {
+				seq = packTwoBit(seq)
+			}
+			return seq, nil
+		}
+	}
+	if f.readerAt != nil {
+		return f.getEncAt(seqName, start, end, enc)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return "", fmt.Errorf("Get called on a closed Fasta")
+	}
+	ent, err := f.checkGet(seqName, start, end)
+	if err != nil {
+		return "", err
+	}
+	if seq, ok := f.cache[ent.Name]; ok {
+		return encodeCached(seq[start:end], enc), nil
+	}
+	if f.diskCache != nil {
+		return f.getDiskCached(ent, start, end, enc)
+	}
+	return f.getEntry(ent, start, end, enc)
+}
+
+// checkGet returns the index entry of the given sequence, or an error if
+// [start, end) is not a valid range of it.
+func (f *indexedFasta) checkGet(seqName string, start, end uint64) (IndexEntry, error) {
+	if err := checkRange(start, end); err != nil {
+		return IndexEntry{}, err
 	}
-	ent, ok := f.seqs[seqName]
+	ent, ok := f.lookup(seqName)
 	if !ok {
-		return "", fmt.Errorf("sequence not found in index: %s", seqName)
+		return IndexEntry{}, fmt.Errorf("sequence not found in index: %s", seqName)
 	}
-	if end > ent.length {
-		return "", fmt.Errorf("end is past end of sequence %s: %d", seqName, ent.length)
+	if end > ent.Length {
+		return IndexEntry{}, fmt.Errorf("end is past end of sequence %s: %d", seqName, ent.Length)
 	}
+	return ent, nil
+}
 
-	// Start the read at a byte offset allowing for the presence of newline
-	// characters.
-	charsPerNewline := ent.lineWidth - ent.lineBase
-	offset := ent.offset + start + charsPerNewline*(start/ent.lineBase)
+// getBase returns the base at pos of the given sequence, in its encoding. Only
+// the byte holding the base is read from the FASTA file, at the offset given
+// by the line geometry, unless it is already buffered. The read buffer is left
+// alone, so that it keeps serving nearby Gets.
+func (f *indexedFasta) getBase(seqName string, pos uint64) (byte, error) {
+	if err := f.reserve(1); err != nil {
+		return 0, err
+	}
+	b, err := f.readBase(seqName, pos)
+	if err != nil {
+		atomic.AddUint64(&f.served, ^uint64(0))
+	}
+	return b, err
+}
 
-	// Figure out how many characters (including newlines) we should read,
-	// and read them.
-	firstLineBases := ent.lineBase - (start % ent.lineBase)
-	newlinesToRead := uint64(0)
-	if end-start > firstLineBases {
-		newlinesToRead = 1 + (end-start-firstLineBases)/ent.lineBase
+// readBase implements getBase, without accounting for the bases served.
+func (f *indexedFasta) readBase(seqName string, pos uint64) (byte, error) {
+	enc := f.seqEncoding(seqName)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return 0, fmt.Errorf("Get called on a closed Fasta")
+	}
+	ent, err := f.checkGet(seqName, pos, pos+1)
+	if err != nil {
+		return 0, err
+	}
+	if seq, ok := f.cache[ent.Name]; ok {
+		return encodeCached(seq[pos:pos+1], enc)[0], nil
 	}
-	capacity := end - start + newlinesToRead*charsPerNewline
+	if f.diskCache != nil {
+		seq, err := f.getDiskCached(ent, pos, pos+1, enc)
+		if err != nil {
+			return 0, err
+		}
+		return seq[0], nil
+	}
+	offset, _, _ := ent.span(pos, pos+1)
+	off := int64(offset)
+	var b [1]byte
+	switch {
+	case off >= f.bufOff && off < f.bufOff+int64(len(f.buf)):
+		b[0] = f.buf[off-f.bufOff]
+	case f.readerAt != nil:
+		if n, err := f.readerAt.ReadAt(b[:], off); n != 1 {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	default:
+		if err := f.seek(off); err != nil {
+			return 0, err
+		}
+		if _, err := io.ReadFull(f.reader, b[:]); err != nil {
+			f.readerOff = -1
+			return 0, err
+		}
+		f.readerOff++
+	}
+	return encodeCached(string(b[:]), enc)[0], nil
+}
 
+// getEntry reads the bases [start, end) of the sequence described by ent,
+// which the caller has checked to be a valid range. It must be called with
+// f.mutex held.
+func (f *indexedFasta) getEntry(ent IndexEntry, start, end uint64, enc Encoding) (string, error) {
+	offset, capacity, linePos := ent.span(start, end)
 	buffer, err := f.read(int64(offset), int(capacity))
 	if err != nil && err != io.EOF {
 		return "", err
 	}
+	return f.decode(ent, buffer, linePos, end-start, enc, &f.resultBuf)
+}
+
+// decodeInto fills dst with the bases held in buffer, the FASTA file bytes of
+// the sequence described by ent starting at line position linePos, in the
+// given encoding, except for TwoBit, which the caller packs.
+func (f *indexedFasta) decodeInto(ent IndexEntry, dst, buffer []byte, linePos uint64, enc Encoding) error {
+	var n int
+	if workers := f.opts.DecodeParallel; workers > 1 && len(buffer) >= parallelDecodeMinBytes {
+		n = decodeParallel(ent, dst, buffer, linePos, enc, workers)
+	} else {
+		n = stripLines(ent, dst, buffer, linePos)
+		encodeInplace(dst, enc)
+	}
+	if n < len(dst) {
+		return fmt.Errorf("encountered unexpected end of file (bad index?)")
+	}
+	return nil
+}
 
+// decode returns the n bases held in buffer, the FASTA file bytes of the
+// sequence described by ent starting at line position linePos, in the given
+// encoding. It assembles them in *resultBuf.
+func (f *indexedFasta) decode(ent IndexEntry, buffer []byte, linePos, n uint64, enc Encoding, resultBuf *[]byte) (string, error) {
 	// Traverse the bytes we just read and copy the non-newline characters
 	// to the result.
-	f.resizeBuf(&f.resultBuf, int(end-start))
-	linePos := (offset - ent.offset) % ent.lineWidth
-	resultPos := 0
-	for i := range buffer {
-		if linePos < ent.lineBase {
-			f.resultBuf[resultPos] = buffer[i]
-			resultPos++
-		}
-		linePos++
-		if linePos == ent.lineWidth {
-			linePos = 0
-		}
+	f.resizeBuf(resultBuf, int(n))
+	if err := f.decodeInto(ent, *resultBuf, buffer, linePos, enc); err != nil {
+		return "", err
 	}
 
-	if f.opts.Enc == CleanASCII {
-		biosimd.CleanASCIISeqInplace(f.resultBuf)
-	} else if f.opts.Enc == Seq8 {
-		biosimd.ASCIIToSeq8Inplace(f.resultBuf)
+	var result string
+	if enc == TwoBit {
+		result = packTwoBit(unsafe.BytesToString(*resultBuf))
+	} else {
+		result = string(*resultBuf)
 	}
-
-	return string(f.resultBuf), nil
+	if f.opts.NoBuffer {
+		*resultBuf = nil
+	} else if limit := f.opts.MaxResultBuf; limit > 0 && cap(*resultBuf) > limit {
+		*resultBuf = make([]byte, 0, limit)
+	}
+	return result, nil
 }
 
-// SeqNames implements Fasta.SeqNames().
+// SeqNames implements Fasta.SeqNames(). It returns a copy, since the index
+// may be shared with concurrent readers, and is replaced when WatchIndex
+// reloads it.
 func (f *indexedFasta) SeqNames() []string {
-	return f.seqNames
+	return append([]string(nil), f.currentIndex().seqNames...)
+}
+
+// IndexFileOrder implements Indexed. Like SeqNames, it returns a copy.
+func (f *indexedFasta) IndexFileOrder() []string {
+	return append([]string(nil), f.currentIndex().fileOrder...)
 }
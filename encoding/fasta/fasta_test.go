@@ -2,6 +2,7 @@ package fasta_test
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -9,7 +10,11 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/Schaudge/grailbase/file"
 	"github.com/Schaudge/grailbase/vcontext"
@@ -55,6 +60,11 @@ func TestOps(t *testing.T) {
 			assert.NoError(t, err)
 			return fa
 		}},
+		{"readerat", func() fasta.Fasta {
+			fa, err := fasta.NewIndexedFromReaderAt(strings.NewReader(fastaData), int64(len(fastaData)), fasta.OptClean)
+			assert.NoError(t, err)
+			return fa
+		}},
 	}
 	for _, impl := range impls {
 		fa := impl.fa()
@@ -75,7 +85,8 @@ func TestOps(t *testing.T) {
 					{"seq2", 2, 5, "GTA", nil},
 					{"seq0", 0, 1, "", fmt.Errorf("sequence not found in index: seq0")},
 					{"seq1", 10, 13, "", fmt.Errorf("end is past end of sequence seq1: 12")},
-					{"seq1", 4, 3, "", fmt.Errorf("start must be less than end")},
+					{"seq1", 4, 3, "", fasta.ErrReversedRange},
+					{"seq1", 3, 3, "", fasta.ErrEmptyRange},
 				}
 				for _, tt := range tests {
 					got, err := fa.Get(tt.seq, tt.start, tt.end)
@@ -120,6 +131,1113 @@ func TestOps(t *testing.T) {
 	}
 }
 
+func TestReadIndex(t *testing.T) {
+	entries, err := fasta.ReadIndex(strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	assert.EQ(t, entries, []fasta.IndexEntry{
+		{Name: "seq1", Length: 12, Offset: 6, LineBase: 5, LineWidth: 6},
+		{Name: "seq2", Length: 8, Offset: 44, LineBase: 4, LineWidth: 5},
+	})
+
+	for _, index := range []string{
+		"seq1\t12\t6\t5\n",
+		"seq1\t99999999999999999999\t6\t5\t6\n",
+		"seq1\t12\t6\t0\t1\n",
+		"seq1\t12\t6\t5\t4\n",
+		"seq1\t12\t9223372036854775807\t5\t6\n",
+		"seq1\t9223372036854775800\t0\t1\t2\n",
+		"seq 1\t12\t6\t5\t6\n",
+		"\t12\t6\t5\t6\n",
+		"seq1\t12\t6\t5\t6junk\n",
+		"seq1\t12\t6\t5\t6 \n",
+	} {
+		_, err := fasta.ReadIndex(strings.NewReader(index))
+		assert.NotNil(t, err, "index %q", index)
+	}
+
+	entries, err = fasta.ReadIndex(strings.NewReader("seq 1\t12\t6\t5\t6\n"), fasta.OptFullLineName)
+	assert.NoError(t, err)
+	assert.EQ(t, entries[0].Name, "seq 1")
+
+	// The quality offset column of FASTQ indexes is allowed.
+	entries, err = fasta.ReadIndex(strings.NewReader("read1\t4\t7\t4\t5\t14\n"))
+	assert.NoError(t, err)
+	assert.EQ(t, entries, []fasta.IndexEntry{{Name: "read1", Length: 4, Offset: 7, LineBase: 4, LineWidth: 5}})
+}
+
+func FuzzReadIndex(f *testing.F) {
+	f.Add([]byte(fastaIndex))
+	f.Add([]byte("seq1\t12\t6\t0\t0\n"))
+	f.Add([]byte("seq1\t12\t6\t5\t6junk\n"))
+	f.Add([]byte("read1\t4\t7\t4\t5\t14\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		entries, err := fasta.ReadIndex(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		fa, err := fasta.NewIndexed(strings.NewReader(fastaData), bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("NewIndexed failed on an index accepted by ReadIndex: %v", err)
+		}
+		for _, ent := range entries {
+			if ent.Length > 0 {
+				_, _ = fa.Get(ent.Name, ent.Length-1, ent.Length)
+			}
+		}
+	})
+}
+
+func TestGetEnc(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	got, err := fa.GetEnc("seq1", 0, 5, fasta.Seq8)
+	assert.NoError(t, err)
+	assert.EQ(t, got, "\x01\x02\x04\x08\x01")
+	got, err = fa.GetEnc("seq1", 0, 5, fasta.CleanASCII)
+	assert.NoError(t, err)
+	assert.EQ(t, got, "ACGTA")
+	// The encoding specified at construction is unaffected.
+	got, err = fa.Get("seq1", 0, 5)
+	assert.NoError(t, err)
+	assert.EQ(t, got, "AcGTA")
+	_, err = fa.GetEnc("seq1", 0, 5, fasta.EncodingLimit)
+	assert.NotNil(t, err)
+}
+
+// seekCounter is an io.ReadSeeker that counts calls to Seek.
+type seekCounter struct {
+	io.ReadSeeker
+	seeks int
+}
+
+func (r *seekCounter) Seek(offset int64, whence int) (int64, error) {
+	r.seeks++
+	return r.ReadSeeker.Seek(offset, whence)
+}
+
+func TestInitialSeek(t *testing.T) {
+	r := &seekCounter{ReadSeeker: strings.NewReader(fastaData)}
+	fa, err := fasta.NewIndexed(r, strings.NewReader(fastaIndex), fasta.OptInitialSeek("seq2"))
+	assert.NoError(t, err)
+	assert.EQ(t, r.seeks, 1)
+	seq, err := fa.Get("seq2", 0, 4)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGT")
+	assert.EQ(t, r.seeks, 1)
+
+	_, err = fasta.NewIndexed(r, strings.NewReader(fastaIndex), fasta.OptInitialSeek("seq0"))
+	assert.NotNil(t, err)
+}
+
+func TestImpliedFileSize(t *testing.T) {
+	fa, err := fasta.NewIndexed(nil, strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	assert.EQ(t, fa.ImpliedFileSize(), int64(len(fastaData)))
+}
+
+func TestMaxResultBuf(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptMaxResultBuf(4))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		start, end uint64
+		want       string
+	}{{0, 12, "AcGTACGTACGT"}, {1, 3, "cG"}, {2, 9, "GTACGTA"}, {10, 12, "GT"}} {
+		seq, err := fa.Get("seq1", tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, tt.want)
+	}
+}
+
+func TestForEachSeq(t *testing.T) {
+	fa, err := fasta.NewIndexed(nil, strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	var got []string
+	fa.ForEachSeq(func(name string, length uint64) bool {
+		got = append(got, fmt.Sprintf("%s:%d", name, length))
+		return true
+	})
+	assert.EQ(t, got, []string{"seq1:12", "seq2:8"})
+
+	got = nil
+	fa.ForEachSeq(func(name string, length uint64) bool {
+		got = append(got, name)
+		return false
+	})
+	assert.EQ(t, got, []string{"seq1"})
+}
+
+func TestNoTrailingNewline(t *testing.T) {
+	data := ">seq1\nACGTA\nCGTAC"
+	index := "seq1\t10\t6\t5\t6\n"
+	fa, err := fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index))
+	assert.NoError(t, err)
+	_, err = fa.Get("seq1", 0, 10)
+	assert.Regexp(t, err, "unexpected end of file")
+
+	fa, err = fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index), fasta.OptNoTrailingNewline)
+	assert.NoError(t, err)
+	seq, err := fa.Get("seq1", 0, 10)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGTAC")
+	seq, err = fa.Get("seq1", 3, 10)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "TACGTAC")
+
+	// Truncated files are still detected.
+	fa, err = fasta.NewIndexed(strings.NewReader(data[:len(data)-2]), strings.NewReader(index), fasta.OptNoTrailingNewline)
+	assert.NoError(t, err)
+	_, err = fa.Get("seq1", 0, 10)
+	assert.Regexp(t, err, "unexpected end of file")
+}
+
+func TestSharedBufferPool(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptSharedBufferPool)
+		assert.NoError(t, err)
+		seq, err := fa.Get("seq1", uint64(i), 12)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, "AcGTACGTACGT"[i:])
+		assert.NoError(t, fa.Close())
+		_, err = fa.Get("seq1", 0, 1)
+		assert.Regexp(t, err, "closed")
+		assert.NoError(t, fa.Close())
+	}
+}
+
+// eofReadSeeker is an io.ReadSeeker whose Read returns at most 3 bytes at a
+// time, and returns io.EOF along with the final bytes.
+type eofReadSeeker struct {
+	*strings.Reader
+}
+
+func (r eofReadSeeker) Read(p []byte) (int, error) {
+	if len(p) > 3 {
+		p = p[:3]
+	}
+	n, err := r.Reader.Read(p)
+	if err == nil && r.Reader.Len() == 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestReadWithEOF(t *testing.T) {
+	fa, err := fasta.NewIndexed(eofReadSeeker{strings.NewReader(fastaData)}, strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seq        string
+		start, end uint64
+		want       string
+	}{
+		{"seq1", 0, 12, "AcGTACGTACGT"},
+		{"seq2", 0, 8, "ACGTACGT"},
+		{"seq2", 6, 8, "GT"},
+	} {
+		got, err := fa.Get(tt.seq, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, got, tt.want)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	stripVersion := func(name string) string {
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			return name[:i]
+		}
+		return name
+	}
+	data := ">NC_1.2\nACGT\n>NC_2.1\nGG\n"
+	index := "NC_1.2\t4\t8\t4\t5\nNC_2.1\t2\t21\t2\t3\n"
+	newEager := func(opts ...fasta.Opt) (fasta.Fasta, error) {
+		return fasta.New(strings.NewReader(data), opts...)
+	}
+	newEagerIndexed := func(opts ...fasta.Opt) (fasta.Fasta, error) {
+		return fasta.New(strings.NewReader(data), append(opts, fasta.OptIndex([]byte(index)))...)
+	}
+	newIndexed := func(opts ...fasta.Opt) (fasta.Fasta, error) {
+		return fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index), opts...)
+	}
+	for _, newFasta := range []func(...fasta.Opt) (fasta.Fasta, error){newEager, newEagerIndexed, newIndexed} {
+		fa, err := newFasta(fasta.OptNormalizeName(stripVersion))
+		assert.NoError(t, err)
+		assert.EQ(t, fa.SeqNames(), []string{"NC_1", "NC_2"})
+		for _, name := range []string{"NC_1", "NC_1.2", "NC_1.3"} {
+			seq, err := fa.Get(name, 1, 3)
+			assert.NoError(t, err)
+			assert.EQ(t, seq, "CG")
+		}
+		n, err := fa.Len("NC_2.7")
+		assert.NoError(t, err)
+		assert.EQ(t, n, uint64(2))
+
+		_, err = newFasta(fasta.OptNormalizeName(func(string) string { return "chr" }))
+		assert.Regexp(t, err, "both normalize to chr")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	fa, err := fasta.NewIndexed(nil, strings.NewReader(fastaIndex+"seq3\t20\t100\t5\t6\n"))
+	assert.NoError(t, err)
+	assert.EQ(t, fa.Summary(), fasta.IndexSummary{
+		NumSeqs:     3,
+		TotalLength: 40,
+		MinLen:      8,
+		MaxLen:      20,
+		LineWidths:  []uint64{5, 6},
+	})
+}
+
+func TestPadN(t *testing.T) {
+	for _, fa := range []fasta.Fasta{
+		func() fasta.Fasta {
+			fa, err := fasta.New(strings.NewReader(fastaData), fasta.OptPadN)
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptPadN)
+			assert.NoError(t, err)
+			return fa
+		}(),
+	} {
+		for _, tt := range []struct {
+			start, end uint64
+			want       string
+		}{
+			{8, 12, "ACGT"},
+			{8, 15, "ACGTNNN"},
+			{12, 14, "NN"},
+			{20, 21, "N"},
+		} {
+			seq, err := fa.Get("seq1", tt.start, tt.end)
+			assert.NoError(t, err)
+			assert.EQ(t, seq, tt.want)
+		}
+		_, err := fa.Get("seq1", 14, 12)
+		assert.NotNil(t, err)
+	}
+
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptPadN,
+		fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	seq, err := fa.Get("seq2", 7, 9)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "\x08\x0f")
+}
+
+func TestSeqRawReader(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seqName, want string
+	}{
+		{"seq1", "AcGTA\nCGTAC\nGT\n"},
+		{"seq2", "ACGT\nACGT\n"},
+	} {
+		r, err := fa.SeqRawReader(tt.seqName)
+		assert.NoError(t, err)
+		got, err := io.ReadAll(iotest.OneByteReader(r))
+		assert.NoError(t, err)
+		assert.EQ(t, string(got), tt.want)
+	}
+	_, err = fa.SeqRawReader("seq3")
+	assert.NotNil(t, err)
+
+	fa, err = fasta.NewIndexed(strings.NewReader(strings.TrimSuffix(fastaData, "\n")),
+		strings.NewReader(fastaIndex), fasta.OptNoTrailingNewline)
+	assert.NoError(t, err)
+	r, err := fa.SeqRawReader("seq2")
+	assert.NoError(t, err)
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.EQ(t, string(got), "ACGT\nACGT")
+}
+
+func TestShuffledIndex(t *testing.T) {
+	data := ">a\nACGTA\nCG\n>b\nGGGG\nTT\n>c\nTTTA\n"
+	// Index lines are not in file order, and "d" aliases part of "a".
+	index := "c\t4\t26\t4\t5\nb\t6\t15\t4\t5\na\t7\t3\t5\t6\nd\t5\t3\t5\t6\n"
+	want := map[string]string{"a": "ACGTACG", "b": "GGGGTT", "c": "TTTA", "d": "ACGTA"}
+	wantRaw := map[string]string{"a": "ACGTA\nCG\n", "b": "GGGG\nTT\n", "c": "TTTA\n", "d": "ACGTA\n"}
+
+	eager, err := fasta.New(strings.NewReader(data), fasta.OptIndex([]byte(index)))
+	assert.NoError(t, err)
+	lazy, err := fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index))
+	assert.NoError(t, err)
+	assert.EQ(t, eager.SeqNames(), []string{"c", "b", "a", "d"})
+	assert.EQ(t, lazy.SeqNames(), []string{"a", "d", "b", "c"})
+	for _, fa := range []fasta.Fasta{eager, lazy} {
+		for name, seq := range want {
+			got, err := fa.Get(name, 0, uint64(len(seq)))
+			assert.NoError(t, err)
+			assert.EQ(t, got, seq)
+		}
+		// ValidateAlphabet streams every sequence.
+		var allowed [256]bool
+		allowed['A'], allowed['C'], allowed['G'] = true, true, true
+		invalid, err := fasta.ValidateAlphabet(fa, allowed)
+		assert.NoError(t, err)
+		assert.EQ(t, invalid, map[string][]uint64{"a": {3}, "b": {4, 5}, "c": {0, 1, 2}, "d": {3}})
+	}
+	// Iterate in the reverse of file order.
+	for _, name := range []string{"c", "b", "d", "a"} {
+		r, err := lazy.SeqRawReader(name)
+		assert.NoError(t, err)
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.EQ(t, string(got), wantRaw[name])
+	}
+
+	// Overlapping entries require seeking back.
+	_, err = fasta.New(iotest.HalfReader(strings.NewReader(data)), fasta.OptIndex([]byte(index)))
+	assert.Regexp(t, err, "index entries for a and d overlap")
+}
+
+// failingReadSeeker fails all reads and seeks once fail is set.
+type failingReadSeeker struct {
+	io.ReadSeeker
+	fail bool
+}
+
+func (r *failingReadSeeker) Read(p []byte) (int, error) {
+	if r.fail {
+		return 0, fmt.Errorf("read failed")
+	}
+	return r.ReadSeeker.Read(p)
+}
+
+func (r *failingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if r.fail {
+		return 0, fmt.Errorf("seek failed")
+	}
+	return r.ReadSeeker.Seek(offset, whence)
+}
+
+// overreadingReadSeeker claims to read more bytes than the size of the buffer
+// passed to Read once overread is set, violating the io.Reader contract.
+type overreadingReadSeeker struct {
+	io.ReadSeeker
+	overread bool
+}
+
+func (r *overreadingReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	if r.overread {
+		n = len(p) + 10
+	}
+	return n, err
+}
+
+func TestOverreadingReader(t *testing.T) {
+	r := &overreadingReadSeeker{ReadSeeker: strings.NewReader(fastaData)}
+	fa, err := fasta.NewIndexed(r, strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	r.overread = true
+	_, err = fa.Get("seq1", 0, 12)
+	assert.Regexp(t, err, "reader returned [0-9]+ bytes for a read of at most 8192")
+	// The Fasta recovers once the reader behaves.
+	r.overread = false
+	seq, err := fa.Get("seq1", 0, 12)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "AcGTACGTACGT")
+}
+
+func TestPrefetch(t *testing.T) {
+	// Use sequences larger than the read buffer, so that Gets need to read.
+	seq1 := strings.Repeat("ACGTa", 10000)
+	seq2 := strings.Repeat("TTGCA", 10000)
+	data := ">seq1\n" + seq1 + "\n>seq2\n" + seq2 + "\n"
+	var index bytes.Buffer
+	assert.NoError(t, fasta.GenerateIndex(&index, strings.NewReader(data)))
+	r := &failingReadSeeker{ReadSeeker: strings.NewReader(data)}
+	fa, err := fasta.NewIndexed(r, &index, fasta.OptClean)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			seq, err := fa.Get("seq2", uint64(i*100), uint64(i*100+10))
+			assert.NoError(t, err)
+			assert.EQ(t, seq, seq2[i*100:i*100+10])
+		}
+	}()
+	assert.NoError(t, fa.Prefetch("seq1"))
+	wg.Wait()
+	// Leave only the start of seq2 in the read buffer.
+	_, err = fa.Get("seq2", 0, 10)
+	assert.NoError(t, err)
+
+	r.fail = true
+	seq, err := fa.Get("seq1", 49990, 50000)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, strings.ToUpper(seq1[49990:50000]))
+	seq, err = fa.GetEnc("seq1", 0, 5, fasta.RawASCII)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTa")
+	_, err = fa.Get("seq2", 49990, 50000)
+	assert.NotNil(t, err)
+	assert.NotNil(t, fa.Prefetch("seq2"))
+	// A failed Prefetch leaves the cache intact.
+	_, err = fa.Get("seq1", 0, 10)
+	assert.NoError(t, err)
+
+	fa.Reset()
+	_, err = fa.Get("seq1", 0, 10)
+	assert.NotNil(t, err)
+	assert.NotNil(t, fa.Prefetch("seq3"))
+}
+
+func TestSameUnderlyingBytes(t *testing.T) {
+	index := fastaIndex + "alias1\t12\t6\t5\t6\nprefix1\t5\t6\t5\t6\n"
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(index))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"seq1", "seq1", true},
+		{"seq1", "alias1", true},
+		{"seq1", "prefix1", false},
+		{"seq1", "seq2", false},
+	} {
+		same, err := fa.SameUnderlyingBytes(tt.a, tt.b)
+		assert.NoError(t, err)
+		assert.EQ(t, same, tt.want, "%+v", tt)
+	}
+	_, err = fa.SameUnderlyingBytes("seq1", "seq3")
+	assert.NotNil(t, err)
+}
+
+func TestLineAlignedSpan(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	entries, err := fasta.ReadIndex(strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seq                int
+		start, end         uint64
+		fileStart, fileEnd int64
+		firstLinePos       uint64
+	}{
+		{0, 0, 1, 6, 7, 0},
+		{0, 3, 7, 9, 14, 3},
+		{0, 5, 10, 12, 17, 0},
+		{0, 0, 12, 6, 20, 0},
+		{1, 1, 8, 45, 54, 1},
+	} {
+		ent := entries[tt.seq]
+		fileStart, fileEnd, linePos, err := fa.LineAlignedSpan(ent.Name, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, fileStart, tt.fileStart, "%+v", tt)
+		assert.EQ(t, fileEnd, tt.fileEnd, "%+v", tt)
+		assert.EQ(t, linePos, tt.firstLinePos, "%+v", tt)
+
+		// Decoding the span yields the bases.
+		var bases []byte
+		for i, c := range []byte(fastaData[fileStart:fileEnd]) {
+			if (linePos+uint64(i))%ent.LineWidth < ent.LineBase {
+				bases = append(bases, c)
+			}
+		}
+		want, err := fa.Get(ent.Name, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, string(bases), want)
+	}
+	_, _, _, err = fa.LineAlignedSpan("seq1", 0, 13)
+	assert.NotNil(t, err)
+	_, _, _, err = fa.LineAlignedSpan("seq3", 0, 1)
+	assert.NotNil(t, err)
+}
+
+func TestRangeErrors(t *testing.T) {
+	chain, err := fasta.ReadChainFile(strings.NewReader("chain 1 seq1 12 + 0 12 seq1 12 + 0 12 1\n12\n"))
+	assert.NoError(t, err)
+	for _, fa := range []fasta.Fasta{
+		func() fasta.Fasta {
+			fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptPadN)
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.New(strings.NewReader(fastaData), fasta.OptPadN)
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.New(strings.NewReader(fastaData))
+			assert.NoError(t, err)
+			return fasta.NewLiftoverView(fa, chain)
+		}(),
+	} {
+		_, err := fa.Get("seq1", 3, 3)
+		assert.True(t, errors.Is(err, fasta.ErrEmptyRange), "%v", err)
+		_, err = fa.Get("seq1", 4, 3)
+		assert.True(t, errors.Is(err, fasta.ErrReversedRange), "%v", err)
+		_, err = fasta.GetBatch(fa, "seq1", [][2]uint64{{0, 1}, {2, 2}})
+		assert.True(t, errors.Is(err, fasta.ErrEmptyRange), "%v", err)
+	}
+}
+
+func TestLineRange(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seq                 string
+		start, end          uint64
+		firstLine, lastLine uint64
+	}{
+		{"seq1", 0, 12, 0, 2},
+		{"seq1", 0, 5, 0, 0},
+		{"seq1", 5, 10, 1, 1},
+		{"seq1", 4, 6, 0, 1},
+		{"seq1", 11, 12, 2, 2},
+		{"seq2", 3, 5, 0, 1},
+	} {
+		firstLine, lastLine, err := fa.LineRange(tt.seq, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, firstLine, tt.firstLine, "%+v", tt)
+		assert.EQ(t, lastLine, tt.lastLine, "%+v", tt)
+	}
+	_, _, err = fa.LineRange("seq1", 3, 3)
+	assert.True(t, errors.Is(err, fasta.ErrEmptyRange))
+	_, _, err = fa.LineRange("seq1", 0, 13)
+	assert.Regexp(t, err, "end is past end of sequence seq1")
+	_, _, err = fa.LineRange("seq3", 0, 1)
+	assert.Regexp(t, err, "sequence not found in index: seq3")
+}
+
+// fastaIndexWithComments is fastaIndex with interspersed comment and blank
+// lines.
+const fastaIndexWithComments = `# Generated by a custom indexer.
+
+seq1	12	6	5	6
+  
+# seq2 is short.
+seq2	8	44	4	5
+
+`
+
+func TestIndexComments(t *testing.T) {
+	entries, err := fasta.ReadIndex(strings.NewReader(fastaIndexWithComments))
+	assert.NoError(t, err)
+	want, err := fasta.ReadIndex(strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	assert.EQ(t, entries, want)
+
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndexWithComments))
+	assert.NoError(t, err)
+	assert.EQ(t, fa.SeqNames(), []string{"seq1", "seq2"})
+	seq, err := fa.Get("seq2", 2, 6)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "GTAC")
+
+	// Malformed data lines are still rejected.
+	_, err = fasta.ReadIndex(strings.NewReader("# comment\nseq1\t12\t6\n"))
+	assert.Regexp(t, err, "Invalid index line: seq1")
+	_, err = fasta.ReadIndex(strings.NewReader(" seq1\t12\t6\t5\t6\n"))
+	assert.Regexp(t, err, "Invalid index line")
+}
+
+// countingReadSeeker counts the bytes read through it.
+type countingReadSeeker struct {
+	io.ReadSeeker
+	n int
+}
+
+func (r *countingReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestNoBuffer(t *testing.T) {
+	r := &countingReadSeeker{ReadSeeker: strings.NewReader(fastaData)}
+	fa, err := fasta.NewIndexed(r, strings.NewReader(fastaIndex), fasta.OptNoBuffer)
+	assert.NoError(t, err)
+	seq, err := fa.Get("seq1", 3, 7)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "TACG")
+	// Only the bytes spanned by the bases, as reported by LineAlignedSpan,
+	// are read.
+	assert.EQ(t, r.n, 5)
+	seq, err = fa.Get("seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+	assert.EQ(t, r.n, 5+10)
+
+	fa, err = fasta.NewIndexed(strings.NewReader(strings.TrimSuffix(fastaData, "\n")), strings.NewReader(fastaIndex),
+		fasta.OptNoBuffer, fasta.OptNoTrailingNewline, fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	seq, err = fa.Get("seq2", 6, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "\x04\x08")
+}
+
+func TestSeqEncoding(t *testing.T) {
+	seqEnc := map[string]fasta.Encoding{"seq2": fasta.Seq8, "SEQ1": fasta.RawASCII}
+	for _, tt := range []struct {
+		name string
+		fa   func(opts ...fasta.Opt) (fasta.Fasta, error)
+	}{
+		{"eager", func(opts ...fasta.Opt) (fasta.Fasta, error) {
+			return fasta.New(strings.NewReader(fastaData), opts...)
+		}},
+		{"eager.idx", func(opts ...fasta.Opt) (fasta.Fasta, error) {
+			return fasta.New(strings.NewReader(fastaData), append(opts, fasta.OptIndex([]byte(fastaIndex)))...)
+		}},
+		{"indexed", func(opts ...fasta.Opt) (fasta.Fasta, error) {
+			return fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), opts...)
+		}},
+	} {
+		fa, err := tt.fa(fasta.OptClean, fasta.OptSeqEncoding(seqEnc), fasta.OptNormalizeName(strings.ToLower), fasta.OptPadN)
+		assert.NoError(t, err, tt.name)
+		seq, err := fa.Get("seq1", 0, 4)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, "AcGT", tt.name)
+		seq, err = fa.Get("SEQ2", 2, 10)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, "\x04\x08\x01\x02\x04\x08\x0f\x0f", tt.name)
+
+		fa, err = tt.fa(fasta.OptEncoding(fasta.TwoBit), fasta.OptSeqEncoding(map[string]fasta.Encoding{"seq1": fasta.CleanASCII}))
+		assert.NoError(t, err, tt.name)
+		seq, err = fa.Get("seq1", 0, 4)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, "ACGT", tt.name)
+		seq, err = fa.Get("seq2", 0, 4)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, "\x1b", tt.name)
+
+		_, err = tt.fa(fasta.OptSeqEncoding(map[string]fasta.Encoding{"seq3": fasta.Seq8}))
+		assert.Regexp(t, err, "OptSeqEncoding: sequence not found: seq3")
+		_, err = tt.fa(fasta.OptSeqEncoding(map[string]fasta.Encoding{"seq1": fasta.EncodingLimit}))
+		assert.Regexp(t, err, "invalid encoding value for sequence seq1")
+	}
+}
+
+func TestByteQuota(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptByteQuota(20))
+	assert.NoError(t, err)
+	_, err = fa.Get("seq1", 0, 12)
+	assert.NoError(t, err)
+	_, err = fa.Get("seq3", 0, 1)
+	assert.NotNil(t, err)
+	_, err = fa.Get("seq1", 0, 13)
+	assert.NotNil(t, err)
+	assert.EQ(t, fa.BytesServed(), uint64(12))
+	_, err = fa.Get("seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, fa.BytesServed(), uint64(20))
+	_, err = fa.GetEnc("seq2", 0, 1, fasta.RawASCII)
+	assert.True(t, errors.Is(err, fasta.ErrQuotaExceeded))
+	assert.Regexp(t, err, "quota exceeded: 1 bases requested, 20 of 20 served")
+	assert.EQ(t, fa.BytesServed(), uint64(20))
+
+	// Concurrent requests never exceed the quota.
+	r := rand.New(rand.NewSource(0))
+	data, index, _ := randomFasta(r, 1, 1000)
+	fa, err = fasta.NewIndexedConcurrent(bytes.NewReader(data), bytes.NewReader(index), fasta.OptByteQuota(95))
+	assert.NoError(t, err)
+	var (
+		wg sync.WaitGroup
+		ok int32
+	)
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				if _, err := fa.Get("seq0", 0, 1); err == nil {
+					atomic.AddInt32(&ok, 1)
+				} else {
+					assert.True(t, errors.Is(err, fasta.ErrQuotaExceeded))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EQ(t, ok, int32(50))
+	for i := 0; i < 45; i++ {
+		_, err := fa.Get("seq0", 0, 1)
+		assert.NoError(t, err)
+	}
+	_, err = fa.Get("seq0", 0, 1)
+	assert.True(t, errors.Is(err, fasta.ErrQuotaExceeded))
+	assert.EQ(t, fa.BytesServed(), uint64(95))
+
+	// Without a quota, bases are counted all the same.
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptPadN)
+	assert.NoError(t, err)
+	_, err = fa.Get("seq2", 4, 20)
+	assert.NoError(t, err)
+	assert.EQ(t, fa.BytesServed(), uint64(16))
+}
+
+func TestIndexFileOrder(t *testing.T) {
+	index := "seq2\t8\t44\t4\t5\nseq1\t12\t6\t5\t6\n"
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(index))
+	assert.NoError(t, err)
+	assert.EQ(t, fa.SeqNames(), []string{"seq1", "seq2"})
+	assert.EQ(t, fa.IndexFileOrder(), []string{"seq2", "seq1"})
+
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	assert.EQ(t, fa.IndexFileOrder(), fa.SeqNames())
+}
+
+func TestGetLines(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seqName    string
+		start, end uint64
+		want       []string
+	}{
+		{"seq1", 0, 12, []string{"AcGTA", "CGTAC", "GT"}},
+		{"seq1", 3, 11, []string{"TA", "CGTAC", "G"}},
+		{"seq1", 5, 10, []string{"CGTAC"}},
+		{"seq1", 6, 8, []string{"GT"}},
+		{"seq2", 0, 8, []string{"ACGT", "ACGT"}},
+	} {
+		lines, err := fa.GetLines(tt.seqName, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, lines, tt.want, "%s:%d-%d", tt.seqName, tt.start, tt.end)
+	}
+	_, err = fa.GetLines("seq2", 0, 9)
+	assert.NotNil(t, err)
+	_, err = fa.GetLines("seq2", 2, 2)
+	assert.True(t, errors.Is(err, fasta.ErrEmptyRange))
+
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	lines, err := fa.GetLines("seq2", 2, 6)
+	assert.NoError(t, err)
+	assert.EQ(t, lines, []string{"\x04\x08", "\x01\x02"})
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptEncoding(fasta.TwoBit))
+	assert.NoError(t, err)
+	_, err = fa.GetLines("seq2", 2, 6)
+	assert.Regexp(t, err, "TwoBit")
+}
+
+func TestDecodeParallel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data, index, seqs := randomFasta(r, 3, 3<<20)
+	for _, enc := range []fasta.Encoding{fasta.RawASCII, fasta.CleanASCII, fasta.Seq8} {
+		want, err := fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index), fasta.OptEncoding(enc))
+		assert.NoError(t, err)
+		for _, workers := range []int{2, 3, 7} {
+			fa, err := fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index),
+				fasta.OptEncoding(enc), fasta.OptDecodeParallel(workers))
+			assert.NoError(t, err)
+			for name, seq := range seqs {
+				n := uint64(len(seq))
+				for _, rng := range [][2]uint64{{0, n}, {1, n}, {59, n - 1}, {60, n / 2}, {n / 3, n / 3 * 2}, {n - 100, n}} {
+					if rng[0] >= rng[1] {
+						continue
+					}
+					got, err := fa.Get(name, rng[0], rng[1])
+					assert.NoError(t, err)
+					exp, err := want.Get(name, rng[0], rng[1])
+					assert.NoError(t, err)
+					assert.True(t, got == exp, "%v %d %s:%v", enc, workers, name, rng)
+				}
+			}
+		}
+	}
+
+	// Truncated files are detected.
+	fa, err := fasta.NewIndexed(bytes.NewReader(data[:len(data)/2]), bytes.NewReader(index), fasta.OptDecodeParallel(4))
+	assert.NoError(t, err)
+	names := fa.SeqNames()
+	last := names[len(names)-1]
+	_, err = fa.Get(last, 0, uint64(len(seqs[last])))
+	assert.Regexp(t, err, "unexpected end of file")
+}
+
+func TestEstimateBytes(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	n, err := fa.EstimateBytes("seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, n, int64(8192+8+8))
+	_, err = fa.EstimateBytes("seq2", 0, 9)
+	assert.NotNil(t, err)
+	_, err = fa.EstimateBytes("seq3", 0, 1)
+	assert.NotNil(t, err)
+
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+		fasta.OptNoBuffer, fasta.OptEncoding(fasta.TwoBit))
+	assert.NoError(t, err)
+	n, err = fa.EstimateBytes("seq1", 0, 12)
+	assert.NoError(t, err)
+	assert.EQ(t, n, int64(14+12+3))
+
+	// The FASTA file is not accessed.
+	fa, err = fasta.NewIndexed(strings.NewReader(""), strings.NewReader("big\t100000\t5\t60\t61\n"))
+	assert.NoError(t, err)
+	n, err = fa.EstimateBytes("big", 0, 100000)
+	assert.NoError(t, err)
+	assert.EQ(t, n, int64(101666+100000+100000))
+}
+
+func TestNameMapFile(t *testing.T) {
+	const nameMap = "# accession\tname\nseq1\tchr1\n\nseq2\tchr2\r\n"
+	eager, err := fasta.New(strings.NewReader(fastaData), fasta.OptNameMapFile(strings.NewReader(nameMap)))
+	assert.NoError(t, err)
+	indexed, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+		fasta.OptNameMapFile(strings.NewReader(nameMap)), fasta.OptNormalizeName(strings.ToUpper))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		fa    fasta.Fasta
+		names []string
+	}{
+		{eager, []string{"chr1", "chr2"}},
+		{indexed, []string{"CHR1", "CHR2"}},
+	} {
+		assert.EQ(t, tt.fa.SeqNames(), tt.names)
+		for _, name := range []string{"seq2", tt.names[1]} {
+			seq, err := tt.fa.Get(name, 0, 4)
+			assert.NoError(t, err)
+			assert.EQ(t, seq, "ACGT")
+		}
+		n, err := tt.fa.Len("seq1")
+		assert.NoError(t, err)
+		assert.EQ(t, n, uint64(12))
+	}
+
+	for _, tt := range []struct {
+		nameMap, err string
+	}{
+		{"seq1\tchr1\nseq3\tchr3\n", "sequence not found: seq3"},
+		{"seq1\tchr1\nseq1\tchr2\n", "line 2: seq1 is mapped twice"},
+		{"seq1 chr1\n", "line 1: expected two tab-separated names"},
+		{"seq1\tchr1\tx\n", "line 1: expected two tab-separated names"},
+		{"seq1\tx\nseq2\tx\n", "normalize to x"},
+	} {
+		_, err := fasta.New(strings.NewReader(fastaData), fasta.OptNameMapFile(strings.NewReader(tt.nameMap)))
+		assert.Regexp(t, err, tt.err)
+		_, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+			fasta.OptNameMapFile(strings.NewReader(tt.nameMap)))
+		assert.Regexp(t, err, tt.err)
+	}
+
+	// The file is read by the constructor, not by OptNameMapFile, and read
+	// errors are returned by the constructor.
+	r := &countingReadSeeker{ReadSeeker: strings.NewReader(nameMap)}
+	opt := fasta.OptNameMapFile(r)
+	assert.EQ(t, r.n, 0)
+	indexed, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), opt)
+	assert.NoError(t, err)
+	assert.EQ(t, r.n, len(nameMap))
+	assert.EQ(t, indexed.SeqNames(), []string{"chr1", "chr2"})
+	_, err = fasta.New(strings.NewReader(fastaData), fasta.OptNameMapFile(iotest.ErrReader(errors.New("name map unavailable"))))
+	assert.Regexp(t, err, "name map unavailable")
+}
+
+func TestAppendTo(t *testing.T) {
+	for _, enc := range []fasta.Encoding{fasta.RawASCII, fasta.CleanASCII, fasta.Seq8, fasta.TwoBit} {
+		fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptEncoding(enc))
+		assert.NoError(t, err)
+		for _, prefetch := range []bool{false, true} {
+			if prefetch {
+				assert.NoError(t, fa.Prefetch("seq1"))
+			}
+			dst := []byte("xy")
+			dst, err = fa.AppendTo(dst, "seq1", 1, 9)
+			assert.NoError(t, err)
+			dst, err = fa.AppendTo(dst, "seq2", 0, 8)
+			assert.NoError(t, err)
+			want1, err := fa.Get("seq1", 1, 9)
+			assert.NoError(t, err)
+			want2, err := fa.Get("seq2", 0, 8)
+			assert.NoError(t, err)
+			assert.EQ(t, string(dst), "xy"+want1+want2)
+
+			got, err := fa.AppendTo(dst, "seq2", 0, 9)
+			assert.NotNil(t, err)
+			assert.EQ(t, string(got), string(dst))
+		}
+	}
+
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptByteQuota(10))
+	assert.NoError(t, err)
+	dst, err := fa.AppendTo(nil, "seq1", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, string(dst), "AcGTACGT")
+	_, err = fa.AppendTo(dst, "seq1", 0, 3)
+	assert.True(t, errors.Is(err, fasta.ErrQuotaExceeded))
+	assert.EQ(t, fa.BytesServed(), uint64(8))
+
+	// Appending to a slice with enough capacity does not allocate.
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(10, func() {
+		var err error
+		if buf, err = fa.AppendTo(buf[:0], "seq1", 2, 12); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.EQ(t, allocs, 0.0)
+	assert.EQ(t, string(buf), "GTACGTACGT")
+}
+
+func TestSeq8IUPAC(t *testing.T) {
+	const (
+		seq   = "ACMGRSVTWYHKDBN-acmgrsvtwyhkdbnUuX"
+		codes = "-ACMGRSVTWYHKDBN"
+	)
+	data := ">iupac\n" + seq[:20] + "\n" + seq[20:] + "\n"
+	index := fmt.Sprintf("iupac\t%d\t7\t20\t21\n", len(seq))
+	want := strings.ToUpper(strings.NewReplacer("U", "T", "u", "T", "X", "N").Replace(seq))
+	for _, fa := range []func(opts ...fasta.Opt) (fasta.Fasta, error){
+		func(opts ...fasta.Opt) (fasta.Fasta, error) { return fasta.New(strings.NewReader(data), opts...) },
+		func(opts ...fasta.Opt) (fasta.Fasta, error) {
+			return fasta.New(strings.NewReader(data), append(opts, fasta.OptIndex([]byte(index)))...)
+		},
+		func(opts ...fasta.Opt) (fasta.Fasta, error) {
+			return fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index), opts...)
+		},
+	} {
+		f, err := fa(fasta.OptEncoding(fasta.Seq8IUPAC), fasta.OptPadN)
+		assert.NoError(t, err)
+		got, err := f.Get("iupac", 0, uint64(len(seq))+2)
+		assert.NoError(t, err)
+		decoded := make([]byte, len(got))
+		for i := range got {
+			decoded[i] = codes[got[i]]
+		}
+		assert.EQ(t, string(decoded), want+"NN")
+	}
+}
+
+func TestBufferGrowth(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	data, index, seqs := randomFasta(r, 2, 200000)
+	for _, opts := range [][]fasta.Opt{
+		{fasta.OptBufferGrowth(2)},
+		{fasta.OptBufferGrowth(1.5), fasta.OptEncoding(fasta.Seq8)},
+		{fasta.OptBufferGrowth(2), fasta.OptNoBuffer},
+	} {
+		want, err := fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index), opts[1:]...)
+		assert.NoError(t, err)
+		fa, err := fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index), opts...)
+		assert.NoError(t, err)
+		for i := 0; i < 200; i++ {
+			name := fmt.Sprintf("seq%d", r.Intn(len(seqs)))
+			n := uint64(len(seqs[name]))
+			start := uint64(r.Int63n(int64(n)))
+			end := start + 1 + uint64(r.Int63n(int64(n-start)))
+			got, err := fa.Get(name, start, end)
+			assert.NoError(t, err)
+			exp, err := want.Get(name, start, end)
+			assert.NoError(t, err)
+			assert.True(t, got == exp, "%s:%d-%d", name, start, end)
+		}
+	}
+}
+
+func TestNewIndexedFromEntries(t *testing.T) {
+	seq1 := fasta.IndexEntry{Name: "seq1", Length: 12, Offset: 6, LineBase: 5, LineWidth: 6}
+	seq2 := fasta.IndexEntry{Name: "seq2", Length: 8, Offset: 44, LineBase: 4, LineWidth: 5}
+	entries := []fasta.IndexEntry{seq2, seq1}
+	fa, err := fasta.NewIndexedFromEntries(strings.NewReader(fastaData), entries, fasta.OptClean)
+	assert.NoError(t, err)
+	assert.EQ(t, fa.SeqNames(), []string{"seq1", "seq2"})
+	assert.EQ(t, fa.IndexFileOrder(), []string{"seq2", "seq1"})
+	seq, err := fa.Get("seq1", 1, 12)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "CGTACGTACGT")
+	seq, err = fa.Get("seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+	// The entries are not retained.
+	entries[1].Offset = 0
+	seq, err = fa.Get("seq1", 0, 1)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "A")
+
+	for _, tt := range []struct {
+		entries []fasta.IndexEntry
+		want    string
+	}{
+		{[]fasta.IndexEntry{seq1, {Name: "", Length: 1, Offset: 30, LineBase: 1, LineWidth: 2}}, "empty name"},
+		{[]fasta.IndexEntry{seq1, seq2, seq1}, "seq1 is listed twice"},
+		{[]fasta.IndexEntry{seq1, {Name: "x", Length: 1, Offset: 30, LineBase: 0, LineWidth: 1}}, "zero bases per line"},
+		{[]fasta.IndexEntry{seq1, {Name: "x", Length: 1, Offset: 30, LineBase: 2, LineWidth: 1}}, "line width"},
+		{[]fasta.IndexEntry{seq2, {Name: "x", Length: 4, Offset: 18, LineBase: 4, LineWidth: 5}, seq1}, "seq1 and x overlap"},
+	} {
+		_, err := fasta.NewIndexedFromEntries(strings.NewReader(fastaData), tt.entries)
+		assert.Regexp(t, err, tt.want)
+	}
+	// Adjacent sequences are fine.
+	_, err = fasta.NewIndexedFromEntries(strings.NewReader(fastaData), []fasta.IndexEntry{
+		seq1, {Name: "x", Length: 4, Offset: 21, LineBase: 4, LineWidth: 5}})
+	assert.NoError(t, err)
+}
+
+func TestWarnLargeRead(t *testing.T) {
+	type warning struct {
+		seqName string
+		bytes   int
+	}
+	var warnings []warning
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+		fasta.OptWarnLargeRead(6, func(seqName string, bytes int) {
+			warnings = append(warnings, warning{seqName, bytes})
+		}), fasta.OptPadN)
+	assert.NoError(t, err)
+	for _, rng := range [][2]uint64{{0, 5}, {0, 6}, {1, 7}, {0, 12}, {10, 20}} {
+		_, err := fa.Get("seq1", rng[0], rng[1])
+		assert.NoError(t, err)
+	}
+	_, err = fa.AppendTo(nil, "seq2", 0, 8)
+	assert.NoError(t, err)
+	_, err = fa.Get("seq3", 0, 8)
+	assert.NotNil(t, err)
+	// The bytes include the line terminators that the ranges span.
+	assert.EQ(t, warnings, []warning{{"seq1", 7}, {"seq1", 7}, {"seq1", 14}, {"seq2", 10}})
+}
+
+// rendezvousReaderAt makes each ReadAt, once armed, wait until another one is
+// in progress, so it only succeeds for concurrent calls.
+type rendezvousReaderAt struct {
+	io.ReaderAt
+	armed int32
+	ch    chan struct{}
+}
+
+func (r *rendezvousReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if atomic.LoadInt32(&r.armed) == 1 {
+		select {
+		case r.ch <- struct{}{}:
+		case <-r.ch:
+		case <-time.After(5 * time.Second):
+			return 0, fmt.Errorf("ReadAt calls are not concurrent")
+		}
+	}
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestNewIndexedFromReaderAtConcurrent(t *testing.T) {
+	r := &rendezvousReaderAt{ReaderAt: strings.NewReader(fastaData), ch: make(chan struct{})}
+	fa, err := fasta.NewIndexedFromReaderAt(r, int64(len(fastaData)))
+	assert.NoError(t, err)
+	atomic.StoreInt32(&r.armed, 1)
+	var wg sync.WaitGroup
+	for _, seqName := range []string{"seq1", "seq2"} {
+		wg.Add(1)
+		go func(seqName string) {
+			defer wg.Done()
+			seq, err := fa.Get(seqName, 0, 8)
+			assert.NoError(t, err)
+			assert.EQ(t, seq, map[string]string{"seq1": "AcGTACGT", "seq2": "ACGTACGT"}[seqName])
+		}(seqName)
+	}
+	wg.Wait()
+}
+
 func TestFastaFaiToReferenceLengths(t *testing.T) {
 	type ref struct {
 		chrom  string
@@ -325,3 +1443,60 @@ func BenchmarkRead(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkDecodeParallel measures the retrieval of a whole 64Mb chromosome
+// with OptDecodeParallel.
+func BenchmarkDecodeParallel(b *testing.B) {
+	const seqLen = 64 << 20
+	r := rand.New(rand.NewSource(0))
+	line := make([]byte, 60)
+	var data bytes.Buffer
+	data.WriteString(">chr\n")
+	for i := 0; i < seqLen/len(line); i++ {
+		for j := range line {
+			line[j] = "ACGTacgtN"[r.Intn(9)]
+		}
+		data.Write(line)
+		data.WriteByte('\n')
+	}
+	index := fmt.Sprintf("chr\t%d\t5\t60\t61\n", seqLen/len(line)*len(line))
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			fa, err := fasta.NewIndexed(bytes.NewReader(data.Bytes()), strings.NewReader(index),
+				fasta.OptClean, fasta.OptDecodeParallel(workers))
+			assert.NoError(b, err)
+			b.SetBytes(seqLen)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := fasta.GetAll(fa, "chr"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkBufferGrowth measures a stream of queries whose sizes alternate
+// between small and growing large ones, with and without OptBufferGrowth.
+func BenchmarkBufferGrowth(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	data, index, seqs := randomFasta(r, 1, 8<<20)
+	n := uint64(len(seqs["seq0"]))
+	for _, factor := range []float64{1, 1.5, 2} {
+		b.Run(fmt.Sprintf("factor=%g", factor), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fa, err := fasta.NewIndexed(bytes.NewReader(data), bytes.NewReader(index),
+					fasta.OptBufferGrowth(factor))
+				assert.NoError(b, err)
+				for size := uint64(1 << 14); size < n; size += size / 8 {
+					for _, rng := range [][2]uint64{{0, 100}, {n - size, n}, {n / 2, n/2 + 10}} {
+						if _, err := fa.Get("seq0", rng[0], rng[1]); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// IndexedFASTQ reads sequence and quality data on demand from an indexed FASTQ
+// file.
+type IndexedFASTQ interface {
+	// GetSeq returns the bases [start, end) of the given record, in the
+	// encoding specified at construction. GetSeq is thread-safe.
+	GetSeq(seqName string, start, end uint64) (string, error)
+
+	// GetQual returns the quality scores, as the original ASCII characters,
+	// of the bases [start, end) of the given record. GetQual is thread-safe.
+	GetQual(seqName string, start, end uint64) (string, error)
+
+	// Len returns the length of the given record.
+	Len(seqName string) (uint64, error)
+
+	// SeqNames returns the names of all records, in the order of appearance
+	// in the FASTQ file.
+	SeqNames() []string
+
+	// Close is like Indexed.Close.
+	Close() error
+}
+
+type indexedFASTQ struct {
+	*indexedFasta
+	qual map[string]IndexEntry // quality geometry, keyed by normalized name.
+}
+
+// NewIndexedFASTQ creates an IndexedFASTQ that performs random lookups in
+// fastq using its index fai, as produced by "samtools fqidx". The index has a
+// sixth column holding the offset of each record's quality string, which has
+// the same line geometry as the sequence.
+func NewIndexedFASTQ(fastq io.ReadSeeker, fai io.Reader, opts ...Opt) (IndexedFASTQ, error) {
+	parsedOpts := makeOpts(opts...)
+	data, err := io.ReadAll(fai)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %v", err)
+	}
+	entries, err := parseIndex(bytes.NewReader(data), parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	qualOffsets, err := parseQualOffsets(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	f, err := newLazyIndexed(fastq, entries, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	qual := make(map[string]IndexEntry, len(entries))
+	for i, ent := range entries {
+		ent.Offset = qualOffsets[i]
+		if err := validateIndexEntry(ent); err != nil {
+			return nil, err
+		}
+		// f.opts holds the mapping of OptNameMapFile, if any.
+		if normalize := f.opts.NormalizeName; normalize != nil {
+			ent.Name = normalize(ent.Name)
+		}
+		qual[ent.Name] = ent
+	}
+	return &indexedFASTQ{indexedFasta: f, qual: qual}, nil
+}
+
+// parseQualOffsets returns the sixth column of each line of a FASTQ index.
+func parseQualOffsets(r io.Reader) ([]uint64, error) {
+	scanner := bufio.NewScanner(r)
+	var offsets []uint64
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("Invalid FASTQ index line: %s: missing quality offset", scanner.Text())
+		}
+		off, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid FASTQ index line: %s: %v", scanner.Text(), err)
+		}
+		offsets = append(offsets, off)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read index: %v", err)
+	}
+	return offsets, nil
+}
+
+// GetSeq implements IndexedFASTQ.GetSeq().
+func (f *indexedFASTQ) GetSeq(seqName string, start, end uint64) (string, error) {
+	return f.Get(seqName, start, end)
+}
+
+// GetQual implements IndexedFASTQ.GetQual().
+func (f *indexedFASTQ) GetQual(seqName string, start, end uint64) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return "", fmt.Errorf("GetQual called on a closed FASTQ")
+	}
+	if err := checkRange(start, end); err != nil {
+		return "", err
+	}
+	ent, ok := f.lookup(seqName)
+	if !ok {
+		return "", fmt.Errorf("sequence not found in index: %s", seqName)
+	}
+	if end > ent.Length {
+		return "", fmt.Errorf("end is past end of sequence %s: %d", seqName, ent.Length)
+	}
+	return f.getEntry(f.qual[ent.Name], start, end, RawASCII)
+}
@@ -0,0 +1,42 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestIndexedFASTQ(t *testing.T) {
+	const (
+		data  = "@r1\nACGTA\nCG\n+\nIIIII\n#!\n@r2\nGGTT\n+\n!!#$\n"
+		index = "r1\t7\t4\t5\t6\t15\nr2\t4\t28\t4\t5\t35\n"
+	)
+	fq, err := fasta.NewIndexedFASTQ(strings.NewReader(data), strings.NewReader(index))
+	assert.NoError(t, err)
+	assert.EQ(t, fq.SeqNames(), []string{"r1", "r2"})
+	for _, tt := range []struct {
+		name       string
+		start, end uint64
+		seq, qual  string
+	}{
+		{"r1", 0, 7, "ACGTACG", "IIIII#!"},
+		{"r1", 4, 6, "AC", "I#"},
+		{"r2", 1, 4, "GTT", "!#$"},
+	} {
+		seq, err := fq.GetSeq(tt.name, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, tt.seq)
+		qual, err := fq.GetQual(tt.name, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, qual, tt.qual)
+	}
+	_, err = fq.GetQual("r2", 0, 5)
+	assert.NotNil(t, err)
+	_, err = fq.GetQual("r3", 0, 1)
+	assert.NotNil(t, err)
+
+	_, err = fasta.NewIndexedFASTQ(strings.NewReader(data), strings.NewReader("r1\t7\t4\t5\t6\n"))
+	assert.Regexp(t, err, "missing quality offset")
+}
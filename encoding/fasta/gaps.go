@@ -0,0 +1,238 @@
+package fasta
+
+import (
+	"fmt"
+
+	"github.com/Schaudge/grailbase/simd"
+	"github.com/Schaudge/grailbase/unsafe"
+	"github.com/Schaudge/grailbio/biosimd"
+)
+
+// isUnknown is true for the bytes representing an unknown base: 'N'/'n' in
+// the ASCII encodings and 15 in Seq8.
+var isUnknown = func() (t [256]bool) {
+	for _, b := range []byte{'N', 'n', 15} {
+		t[b] = true
+	}
+	return
+}()
+
+// unknownCharsSetter is implemented by the Fastas that accept OptUnknownChars.
+type unknownCharsSetter interface {
+	// unknownChars returns the table set by OptUnknownChars, or nil.
+	unknownChars() *[256]bool
+}
+
+func (f *fasta) unknownChars() *[256]bool {
+	return f.unknown
+}
+
+func (f *indexedFasta) unknownChars() *[256]bool {
+	return f.opts.UnknownChars
+}
+
+// unknownTable returns the table of the bytes that count as unknown bases in
+// the sequences of f.
+func unknownTable(f Fasta) *[256]bool {
+	if s, ok := f.(unknownCharsSetter); ok {
+		if t := s.unknownChars(); t != nil {
+			return t
+		}
+	}
+	return &isUnknown
+}
+
+// scanNRuns streams the given sequence and calls fn with the range [start,
+// end) of each maximal run of unknown bases, in increasing order. It returns
+// the sequence length.
+func scanNRuns(f Fasta, seqName string, fn func(start, end uint64)) (uint64, error) {
+	var (
+		unknown  = unknownTable(f)
+		inRun    bool
+		runStart uint64
+		seqLen   uint64
+	)
+	err := streamSeq(f, seqName, true, func(start uint64, chunk string) bool {
+		for i := 0; i < len(chunk); i++ {
+			pos := start + uint64(i)
+			if n := unknown[chunk[i]]; n && !inRun {
+				inRun, runStart = true, pos
+			} else if !n && inRun {
+				inRun = false
+				fn(runStart, pos)
+			}
+		}
+		seqLen = start + uint64(len(chunk))
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if inRun {
+		fn(runStart, seqLen)
+	}
+	return seqLen, nil
+}
+
+// LongestUngapped returns the longest region of the given sequence that
+// contains no unknown ('N', or see OptUnknownChars) bases. If there are
+// several, the first one is returned. If the sequence consists entirely of
+// unknown bases, the result is empty (Start == End).
+func LongestUngapped(f Fasta, seqName string) (Region, error) {
+	var (
+		best    = Region{SeqName: seqName}
+		prevEnd uint64 // end of the previous N run.
+	)
+	consider := func(start, end uint64) {
+		if end-start > best.End-best.Start {
+			best.Start, best.End = start, end
+		}
+	}
+	seqLen, err := scanNRuns(f, seqName, func(start, end uint64) {
+		consider(prevEnd, start)
+		prevEnd = end
+	})
+	if err != nil {
+		return Region{}, err
+	}
+	consider(prevEnd, seqLen)
+	return best, nil
+}
+
+// GetWithGapInfo is like f.Get, but also reports whether any unknown ('N', or
+// see OptUnknownChars) base lies within the range or within gapPad bases of
+// it, e.g., to filter variant calls near assembly gaps. The range and its
+// padding, clamped to the sequence, are read with a single Get call.
+func GetWithGapInfo(f Fasta, seqName string, start, end, gapPad uint64) (seq string, nearGap bool, err error) {
+	if err := checkRange(start, end); err != nil {
+		return "", false, err
+	}
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return "", false, err
+	}
+	if err := checkUnpacked(f, seqName, "GetWithGapInfo"); err != nil {
+		return "", false, err
+	}
+	if end > seqLen {
+		return "", false, fmt.Errorf("end is past end of sequence %s: %d", seqName, seqLen)
+	}
+	padStart, padEnd := uint64(0), seqLen
+	if start > gapPad {
+		padStart = start - gapPad
+	}
+	if seqLen-end > gapPad {
+		padEnd = end + gapPad
+	}
+	window, err := f.Get(seqName, padStart, padEnd)
+	if err != nil {
+		return "", false, err
+	}
+	unknown := unknownTable(f)
+	for i := 0; i < len(window); i++ {
+		if unknown[window[i]] {
+			nearGap = true
+			break
+		}
+	}
+	return window[start-padStart : end-padStart], nearGap, nil
+}
+
+// IsAllN returns whether every base in the range [start, end) of the given
+// sequence is unknown ('N'/'n', or see OptUnknownChars), e.g., to skip
+// assembly gaps. The range is streamed, and the scan stops at the first known
+// base.
+func IsAllN(f Fasta, seqName string, start, end uint64) (bool, error) {
+	if err := checkRange(start, end); err != nil {
+		return false, err
+	}
+	var (
+		unknown = unknownTable(f)
+		allN    = true
+	)
+	err := streamRange(f, seqName, start, end, true, func(_ uint64, chunk string) bool {
+		allN = isAllUnknown(unsafe.StringToBytes(chunk), unknown)
+		return allN
+	})
+	if err != nil {
+		return false, err
+	}
+	return allN, nil
+}
+
+// isAllUnknown returns whether all bytes of seq are in the unknown table. Runs
+// of a single byte, the common case for gaps, are skipped with SIMD
+// comparisons of seq against itself shifted by one.
+func isAllUnknown(seq []byte, unknown *[256]bool) bool {
+	if len(seq) == 0 {
+		return true
+	}
+	if !unknown[seq[0]] {
+		return false
+	}
+	for i := 0; i < len(seq)-1; {
+		// seq[i] is unknown; find the end of its run.
+		i += simd.FirstUnequal8(seq[i+1:], seq[i:len(seq)-1], 0) + 1
+		if i < len(seq) && !unknown[seq[i]] {
+			return false
+		}
+	}
+	return true
+}
+
+type cleanOpts struct {
+	dropUnclean bool
+}
+
+// CleanOpt is an optional argument to GetIfCleanEnough.
+type CleanOpt func(*cleanOpts)
+
+// OptDropUnclean makes GetIfCleanEnough return an empty sequence for regions
+// over the threshold, so that callers that discard them do not keep the
+// bases alive.
+func OptDropUnclean(o *cleanOpts) {
+	o.dropUnclean = true
+}
+
+// GetIfCleanEnough is like f.Get, but also reports whether the fraction of
+// unknown ('N', or see OptUnknownChars) bases in the range is at most
+// maxNFrac, e.g., to only accept windows with limited ambiguity. With
+// OptDropUnclean, the returned sequence is empty if it is not. If f is an
+// Indexed, the bases are counted before its encoding is applied; otherwise f
+// must not use TwoBit.
+func GetIfCleanEnough(f Fasta, seqName string, start, end uint64, maxNFrac float64, opts ...CleanOpt) (seq string, clean bool, err error) {
+	var o cleanOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	_, indexed := f.(Indexed)
+	if !indexed {
+		if err := checkUnpacked(f, seqName, "GetIfCleanEnough"); err != nil {
+			return "", false, err
+		}
+	}
+	raw, err := getRaw(f, seqName, start, end)
+	if err != nil {
+		return "", false, err
+	}
+	// Windows of capital ACGT only, the common case, are checked with a
+	// single SIMD scan.
+	nUnknown := 0
+	if biosimd.IsNonACGTPresent(unsafe.StringToBytes(raw)) {
+		unknown := unknownTable(f)
+		for i := 0; i < len(raw); i++ {
+			if unknown[raw[i]] {
+				nUnknown++
+			}
+		}
+	}
+	clean = float64(nUnknown) <= maxNFrac*float64(len(raw))
+	seq = raw
+	if indexed {
+		seq = encodeCached(raw, encodingOf(f, seqName))
+	}
+	if !clean && o.dropUnclean {
+		seq = ""
+	}
+	return seq, clean, nil
+}
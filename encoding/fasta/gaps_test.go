@@ -0,0 +1,210 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestLongestUngapped(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(`>a
+ACGTNNACGTAC
+nnGT
+>b
+NNNN
+>c
+ACGT
+>d
+ACNNACNNA
+`))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seq  string
+		want fasta.Region
+	}{
+		{"a", fasta.Region{SeqName: "a", Start: 6, End: 12}},
+		{"b", fasta.Region{SeqName: "b"}},
+		{"c", fasta.Region{SeqName: "c", Start: 0, End: 4}},
+		{"d", fasta.Region{SeqName: "d", Start: 0, End: 2}},
+	} {
+		got, err := fasta.LongestUngapped(fa, tt.seq)
+		assert.NoError(t, err)
+		assert.EQ(t, got, tt.want)
+	}
+	_, err = fasta.LongestUngapped(fa, "e")
+	assert.NotNil(t, err)
+}
+
+func TestGetWithGapInfo(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(">s\nACGTNNAC\nGTACGTAC\n"), strings.NewReader("s\t16\t3\t8\t9\n"))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		start, end, pad uint64
+		seq             string
+		nearGap         bool
+	}{
+		{0, 4, 0, "ACGT", false},
+		{0, 4, 1, "ACGT", true},
+		{3, 5, 0, "TN", true},
+		{8, 16, 1, "GTACGTAC", false},
+		{8, 16, 2, "GTACGTAC", false},
+		{8, 16, 3, "GTACGTAC", true},
+		{10, 12, 100, "AC", true},
+		{12, 16, 100, "GTAC", true},
+		{12, 16, 6, "GTAC", false},
+	} {
+		seq, nearGap, err := fasta.GetWithGapInfo(fa, "s", tt.start, tt.end, tt.pad)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, tt.seq, "%+v", tt)
+		assert.EQ(t, nearGap, tt.nearGap, "%+v", tt)
+	}
+	_, _, err = fasta.GetWithGapInfo(fa, "s", 10, 17, 0)
+	assert.NotNil(t, err)
+	_, _, err = fasta.GetWithGapInfo(fa, "s", 10, 10, 0)
+	assert.NotNil(t, err)
+}
+
+func TestUnknownChars(t *testing.T) {
+	const data = ">p\nMKXXLVNNAX*\n"
+	eager, err := fasta.New(strings.NewReader(data), fasta.OptUnknownChars("X*"))
+	assert.NoError(t, err)
+	indexed, err := fasta.NewIndexed(strings.NewReader(data), strings.NewReader("p\t11\t3\t11\t12\n"), fasta.OptUnknownChars("X*"))
+	assert.NoError(t, err)
+	for _, fa := range []fasta.Fasta{eager, indexed} {
+		got, err := fasta.LongestUngapped(fa, "p")
+		assert.NoError(t, err)
+		assert.EQ(t, got, fasta.Region{SeqName: "p", Start: 4, End: 9})
+		_, nearGap, err := fasta.GetWithGapInfo(fa, "p", 5, 8, 0)
+		assert.NoError(t, err)
+		assert.False(t, nearGap)
+		_, nearGap, err = fasta.GetWithGapInfo(fa, "p", 5, 8, 2)
+		assert.NoError(t, err)
+		assert.True(t, nearGap)
+	}
+
+	// By default, only N is unknown.
+	fa, err := fasta.New(strings.NewReader(data))
+	assert.NoError(t, err)
+	got, err := fasta.LongestUngapped(fa, "p")
+	assert.NoError(t, err)
+	assert.EQ(t, got, fasta.Region{SeqName: "p", Start: 0, End: 6})
+}
+
+func TestIsAllN(t *testing.T) {
+	const data = ">a\nACNNNNnnNNGT\n>b\nNNNNnNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNX\n"
+	fa, err := fasta.New(strings.NewReader(data))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seq        string
+		start, end uint64
+		want       bool
+	}{
+		{"a", 2, 10, true},
+		{"a", 3, 4, true},
+		{"a", 1, 10, false},
+		{"a", 2, 11, false},
+		{"a", 0, 12, false},
+		{"b", 0, 44, true},
+		{"b", 0, 45, false},
+		{"b", 44, 45, false},
+	} {
+		got, err := fasta.IsAllN(fa, tt.seq, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, got, tt.want, "%s:%d-%d", tt.seq, tt.start, tt.end)
+	}
+	_, err = fasta.IsAllN(fa, "a", 2, 2)
+	assert.NotNil(t, err)
+	_, err = fasta.IsAllN(fa, "a", 2, 13)
+	assert.NotNil(t, err)
+	_, err = fasta.IsAllN(fa, "c", 0, 1)
+	assert.NotNil(t, err)
+
+	fa, err = fasta.New(strings.NewReader(data), fasta.OptUnknownChars("NnX"))
+	assert.NoError(t, err)
+	got, err := fasta.IsAllN(fa, "b", 0, 45)
+	assert.NoError(t, err)
+	assert.True(t, got)
+}
+
+func TestGetIfCleanEnough(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(">a\nACGTNNACGTnN\nRYAC\n"))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		start, end uint64
+		maxNFrac   float64
+		want       bool
+	}{
+		{0, 4, 0, true},
+		{0, 5, 0, false},
+		{0, 5, 0.2, true},
+		{0, 8, 0.2, false},
+		{0, 8, 0.25, true},
+		{8, 16, 0.25, true},
+		{8, 16, 0.24, false},
+		{12, 16, 0, true},
+		{4, 6, 1, true},
+	} {
+		seq, clean, err := fasta.GetIfCleanEnough(fa, "a", tt.start, tt.end, tt.maxNFrac)
+		assert.NoError(t, err)
+		want, err := fa.Get("a", tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, want)
+		assert.EQ(t, clean, tt.want, "%d-%d %g", tt.start, tt.end, tt.maxNFrac)
+
+		seq, clean, err = fasta.GetIfCleanEnough(fa, "a", tt.start, tt.end, tt.maxNFrac, fasta.OptDropUnclean)
+		assert.NoError(t, err)
+		assert.EQ(t, clean, tt.want)
+		if !clean {
+			want = ""
+		}
+		assert.EQ(t, seq, want)
+	}
+	_, _, err = fasta.GetIfCleanEnough(fa, "a", 0, 17, 1)
+	assert.NotNil(t, err)
+
+	fa, err = fasta.New(strings.NewReader(">a\nACGTNNACGTnN\nRYAC\n"), fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	_, clean, err := fasta.GetIfCleanEnough(fa, "a", 0, 12, 0.33)
+	assert.NoError(t, err)
+	assert.False(t, clean)
+	_, clean, err = fasta.GetIfCleanEnough(fa, "a", 0, 12, 0.34)
+	assert.NoError(t, err)
+	assert.True(t, clean)
+
+	fa, err = fasta.New(strings.NewReader(">a\nACGTNNACGTnN\nRYAC\n"), fasta.OptEncoding(fasta.TwoBit))
+	assert.NoError(t, err)
+	_, _, err = fasta.GetIfCleanEnough(fa, "a", 0, 12, 1)
+	assert.NotNil(t, err)
+}
+
+func TestGetIfCleanEnoughIndexed(t *testing.T) {
+	const (
+		data  = ">s\nAATTNNNN\nXXACGTAC\n"
+		index = "s\t16\t3\t8\t9\n"
+	)
+	for _, tt := range []struct {
+		opts       []fasta.Opt
+		start, end uint64
+		maxNFrac   float64
+		want       bool
+	}{
+		// Packed bytes, such as 0x0f for "AATT", are not counted.
+		{[]fasta.Opt{fasta.OptEncoding(fasta.TwoBit)}, 0, 4, 0, true},
+		{[]fasta.Opt{fasta.OptEncoding(fasta.TwoBit)}, 0, 8, 0.5, true},
+		{[]fasta.Opt{fasta.OptEncoding(fasta.TwoBit)}, 0, 8, 0.49, false},
+		// The unknown chars are counted before CleanASCII turns them into 'N'.
+		{[]fasta.Opt{fasta.OptClean, fasta.OptUnknownChars("X")}, 8, 16, 0.25, true},
+		{[]fasta.Opt{fasta.OptClean, fasta.OptUnknownChars("X")}, 8, 16, 0.24, false},
+	} {
+		fa, err := fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index), tt.opts...)
+		assert.NoError(t, err)
+		seq, clean, err := fasta.GetIfCleanEnough(fa, "s", tt.start, tt.end, tt.maxNFrac)
+		assert.NoError(t, err)
+		assert.EQ(t, clean, tt.want, "%d-%d %g", tt.start, tt.end, tt.maxNFrac)
+		want, err := fa.Get("s", tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, want)
+	}
+}
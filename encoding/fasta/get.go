@@ -0,0 +1,214 @@
+package fasta
+
+import (
+	"fmt"
+
+	"github.com/Schaudge/grailbase/unsafe"
+)
+
+// upperTable and lowerTable map the ASCII letters to uppercase and lowercase,
+// respectively, and all other bytes to themselves.
+var upperTable, lowerTable = func() (upper, lower [256]byte) {
+	for i := range upper {
+		upper[i], lower[i] = byte(i), byte(i)
+	}
+	for c := 'a'; c <= 'z'; c++ {
+		upper[c], lower[c-'a'+'A'] = byte(c-'a'+'A'), byte(c)
+	}
+	return
+}()
+
+// GetUpper is like f.Get, but returns the original bases converted to
+// uppercase. If f is an Indexed, its configured encoding is ignored. Only
+// ASCII letters are converted; other bytes are returned as is.
+func GetUpper(f Fasta, seqName string, start, end uint64) (string, error) {
+	return getMapped(f, seqName, start, end, &upperTable)
+}
+
+// GetLower is like f.Get, but returns the original bases converted to
+// lowercase. If f is an Indexed, its configured encoding is ignored. Only
+// ASCII letters are converted; other bytes are returned as is.
+func GetLower(f Fasta, seqName string, start, end uint64) (string, error) {
+	return getMapped(f, seqName, start, end, &lowerTable)
+}
+
+// getMapped returns a copy of the original bases [start, end) of the given
+// sequence, with each byte b replaced by table[b].
+func getMapped(f Fasta, seqName string, start, end uint64, table *[256]byte) (string, error) {
+	seq, err := getRaw(f, seqName, start, end)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, len(seq))
+	for i := 0; i < len(seq); i++ {
+		buf[i] = table[seq[i]]
+	}
+	return unsafe.BytesToString(buf), nil
+}
+
+// GetSigned is like f.Get, but takes signed coordinates, as often produced by
+// coordinate arithmetic. It returns an error if either coordinate is negative,
+// instead of letting it wrap around to a huge unsigned value.
+func GetSigned(f Fasta, seqName string, start, end int64) (string, error) {
+	if start < 0 || end < 0 {
+		return "", fmt.Errorf("negative coordinates for %s: [%d, %d)", seqName, start, end)
+	}
+	return f.Get(seqName, uint64(start), uint64(end))
+}
+
+// GetLen is like f.Get, but takes the length of the range instead of its end,
+// i.e., it returns the bases [start, start+length). It returns an error if
+// start+length overflows or exceeds the length of the sequence.
+func GetLen(f Fasta, seqName string, start, length uint64) (string, error) {
+	end := start + length
+	if end < start {
+		return "", fmt.Errorf("range of %d bases at %s:%d overflows", length, seqName, start)
+	}
+	return f.Get(seqName, start, end)
+}
+
+// GetRunes is like f.Get, but returns the bases as runes, one per byte of the
+// result, for display code that works with runes. This avoids the UTF-8
+// decoding pass of a []rune(string) conversion.
+func GetRunes(f Fasta, seqName string, start, end uint64) ([]rune, error) {
+	seq, err := f.Get(seqName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	runes := make([]rune, len(seq))
+	for i := 0; i < len(seq); i++ {
+		runes[i] = rune(seq[i])
+	}
+	return runes, nil
+}
+
+type flankOpts struct {
+	clamp bool
+}
+
+// FlankOpt is an optional argument to GetFlank.
+type FlankOpt func(*flankOpts)
+
+// OptClampFlank makes GetFlank truncate flanks at the ends of the sequence
+// instead of returning an error.
+func OptClampFlank(o *flankOpts) {
+	o.clamp = true
+}
+
+// GetFlank returns the base at position pos of the given sequence, along with
+// up to flank bases on each side of it, i.e., the range [pos-flank,
+// pos+flank+1) split into its three parts. By default, GetFlank returns an
+// error if that range extends beyond the ends of the sequence; with
+// OptClampFlank, the flanks are truncated instead.
+func GetFlank(f Fasta, seqName string, pos, flank uint64, opts ...FlankOpt) (left string, center byte, right string, err error) {
+	var o flankOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return "", 0, "", err
+	}
+	if err := checkUnpacked(f, seqName, "GetFlank"); err != nil {
+		return "", 0, "", err
+	}
+	if pos >= seqLen {
+		return "", 0, "", fmt.Errorf("position %d is past end of sequence %s: %d", pos, seqName, seqLen)
+	}
+	start, end := pos-flank, pos+flank+1
+	if flank > pos || end > seqLen || end <= pos {
+		if !o.clamp {
+			return "", 0, "", fmt.Errorf("flank of %d around %s:%d extends past the sequence ends", flank, seqName, pos)
+		}
+		if flank > pos {
+			start = 0
+		}
+		if end > seqLen || end <= pos {
+			end = seqLen
+		}
+	}
+	seq, err := f.Get(seqName, start, end)
+	if err != nil {
+		return "", 0, "", err
+	}
+	mid := pos - start
+	return seq[:mid], seq[mid], seq[mid+1:], nil
+}
+
+// baseGetter is implemented by the Fastas that can read a single base without
+// reading the bytes around it.
+type baseGetter interface {
+	getBase(seqName string, pos uint64) (byte, error)
+}
+
+// getBase returns the base at pos of the given sequence, which the caller has
+// checked to exist.
+func getBase(f Fasta, seqName string, pos uint64) (byte, error) {
+	if g, ok := f.(baseGetter); ok {
+		return g.getBase(seqName, pos)
+	}
+	seq, err := f.Get(seqName, pos, pos+1)
+	if err != nil {
+		return 0, err
+	}
+	return seq[0], nil
+}
+
+// FirstBase returns the first base of the given sequence, in the encoding of f.
+// For an Indexed, only the byte holding the base is read from the file.
+func FirstBase(f Fasta, seqName string) (byte, error) {
+	n, err := f.Len(seqName)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("sequence %s is empty", seqName)
+	}
+	return getBase(f, seqName, 0)
+}
+
+// LastBase returns the last base of the given sequence, in the encoding of f.
+// For an Indexed, only the byte holding the base is read from the file; its
+// offset accounts for the line terminators preceding it.
+func LastBase(f Fasta, seqName string) (byte, error) {
+	n, err := f.Len(seqName)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("sequence %s is empty", seqName)
+	}
+	return getBase(f, seqName, n-1)
+}
+
+// GetHead returns the first n bases of the given sequence, or all of it if it
+// is shorter than n.
+func GetHead(f Fasta, seqName string, n uint64) (string, error) {
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return "", err
+	}
+	if n > seqLen {
+		n = seqLen
+	}
+	if n == 0 {
+		return "", nil
+	}
+	return f.Get(seqName, 0, n)
+}
+
+// GetTail returns the last n bases of the given sequence, or all of it if it
+// is shorter than n.
+func GetTail(f Fasta, seqName string, n uint64) (string, error) {
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return "", err
+	}
+	if n > seqLen {
+		n = seqLen
+	}
+	if n == 0 {
+		return "", nil
+	}
+	return f.Get(seqName, seqLen-n, seqLen)
+}
@@ -0,0 +1,198 @@
+package fasta_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestGetUpperLower(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	seq, err := fasta.GetUpper(fa, "seq1", 0, 7)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACG")
+	seq, err = fasta.GetLower(fa, "seq1", 0, 7)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "acgtacg")
+	_, err = fasta.GetUpper(fa, "seq0", 0, 1)
+	assert.NotNil(t, err)
+
+	// Bytes other than ASCII letters, even if not valid UTF-8, are kept.
+	fa2, err := fasta.New(strings.NewReader(">s\naC-*\xe9\xc3\x89z\n"))
+	assert.NoError(t, err)
+	seq, err = fasta.GetUpper(fa2, "s", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "AC-*\xe9\xc3\x89Z")
+	seq, err = fasta.GetLower(fa2, "s", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ac-*\xe9\xc3\x89z")
+}
+
+func TestGetFlank(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	tests := []struct {
+		pos, flank uint64
+		clamp      bool
+		left       string
+		center     byte
+		right      string
+		err        bool
+	}{
+		{5, 2, false, "TA", 'C', "GT", false},
+		{0, 0, false, "", 'A', "", false},
+		{1, 2, false, "", 0, "", true},
+		{1, 2, true, "A", 'c', "GT", false},
+		{10, 3, true, "TAC", 'G', "T", false},
+		{11, 1, false, "", 0, "", true},
+		{12, 0, true, "", 0, "", true},
+		{6, ^uint64(0), true, "AcGTAC", 'G', "TACGT", false},
+	}
+	for _, tt := range tests {
+		var opts []fasta.FlankOpt
+		if tt.clamp {
+			opts = append(opts, fasta.OptClampFlank)
+		}
+		left, center, right, err := fasta.GetFlank(fa, "seq1", tt.pos, tt.flank, opts...)
+		assert.EQ(t, err != nil, tt.err, "%+v: %v", tt, err)
+		assert.EQ(t, left, tt.left, "%+v", tt)
+		assert.EQ(t, center, tt.center, "%+v", tt)
+		assert.EQ(t, right, tt.right, "%+v", tt)
+	}
+}
+
+func TestFirstLastBase(t *testing.T) {
+	crlfData := strings.ReplaceAll(fastaData, "\n", "\r\n")
+	crlfIndex := "seq1\t12\t7\t5\t7\nseq2\t8\t49\t4\t6\n"
+	for _, fa := range []fasta.Fasta{
+		func() fasta.Fasta {
+			fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.NewIndexed(strings.NewReader(crlfData), strings.NewReader(crlfIndex))
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.New(strings.NewReader(fastaData))
+			assert.NoError(t, err)
+			return fa
+		}(),
+	} {
+		for _, tt := range []struct {
+			seqName     string
+			first, last byte
+		}{
+			{"seq1", 'A', 'T'},
+			{"seq2", 'A', 'T'},
+		} {
+			first, err := fasta.FirstBase(fa, tt.seqName)
+			assert.NoError(t, err)
+			assert.EQ(t, first, tt.first)
+			last, err := fasta.LastBase(fa, tt.seqName)
+			assert.NoError(t, err)
+			assert.EQ(t, last, tt.last)
+		}
+		_, err := fasta.FirstBase(fa, "seq3")
+		assert.NotNil(t, err)
+		_, err = fasta.LastBase(fa, "seq3")
+		assert.NotNil(t, err)
+	}
+
+	// An Indexed reads just the bytes holding the bases, and encodes them.
+	r := &countingReadSeeker{ReadSeeker: strings.NewReader(fastaData)}
+	fa, err := fasta.NewIndexed(r, strings.NewReader(fastaIndex), fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	first, err := fasta.FirstBase(fa, "seq1")
+	assert.NoError(t, err)
+	assert.EQ(t, first, byte(1))
+	last, err := fasta.LastBase(fa, "seq1")
+	assert.NoError(t, err)
+	assert.EQ(t, last, byte(8))
+	assert.EQ(t, r.n, 2)
+	// Bytes already buffered by a Get are not read again.
+	_, err = fa.Get("seq2", 0, 1)
+	assert.NoError(t, err)
+	n := r.n
+	last, err = fasta.LastBase(fa, "seq2")
+	assert.NoError(t, err)
+	assert.EQ(t, last, byte(8))
+	assert.EQ(t, r.n, n)
+	assert.EQ(t, fa.BytesServed(), uint64(4))
+}
+
+func TestGetSigned(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	seq, err := fasta.GetSigned(fa, "seq1", 2, 6)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "GTAC")
+	_, err = fasta.GetSigned(fa, "seq1", -1, 6)
+	assert.Regexp(t, err, "negative coordinates")
+	_, err = fasta.GetSigned(fa, "seq1", 2, -6)
+	assert.Regexp(t, err, "negative coordinates")
+	_, err = fasta.GetSigned(fa, "seq1", 2, 13)
+	assert.NotNil(t, err)
+}
+
+func TestGetRunes(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	runes, err := fasta.GetRunes(fa, "seq1", 0, 7)
+	assert.NoError(t, err)
+	assert.EQ(t, runes, []rune("AcGTACG"))
+	_, err = fasta.GetRunes(fa, "seq1", 2, 13)
+	assert.NotNil(t, err)
+}
+
+func TestGetLen(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	seq, err := fasta.GetLen(fa, "seq1", 2, 4)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "GTAC")
+	seq, err = fasta.GetLen(fa, "seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+	_, err = fasta.GetLen(fa, "seq1", 2, math.MaxUint64)
+	assert.Regexp(t, err, "range of 18446744073709551615 bases at seq1:2 overflows")
+	_, err = fasta.GetLen(fa, "seq1", 2, 11)
+	assert.Regexp(t, err, "invalid query range 2 - 13")
+	_, err = fasta.GetLen(fa, "seq1", 2, 0)
+	assert.True(t, errors.Is(err, fasta.ErrEmptyRange))
+}
+
+func TestGetHeadTail(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(">e\n"+fastaData), strings.NewReader("e\t0\t3\t0\t0\nseq1\t12\t9\t5\t6\nseq2\t8\t47\t4\t5\n"))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seqName    string
+		n          uint64
+		head, tail string
+	}{
+		{"seq1", 3, "AcG", "CGT"},
+		{"seq1", 12, "AcGTACGTACGT", "AcGTACGTACGT"},
+		{"seq1", math.MaxUint64, "AcGTACGTACGT", "AcGTACGTACGT"},
+		{"seq2", 5, "ACGTA", "TACGT"},
+		{"seq2", 0, "", ""},
+		{"e", 10, "", ""},
+	} {
+		head, err := fasta.GetHead(fa, tt.seqName, tt.n)
+		assert.NoError(t, err)
+		assert.EQ(t, head, tt.head)
+		tail, err := fasta.GetTail(fa, tt.seqName, tt.n)
+		assert.NoError(t, err)
+		assert.EQ(t, tail, tt.tail)
+	}
+	_, err = fasta.GetHead(fa, "seq3", 1)
+	assert.NotNil(t, err)
+	_, err = fasta.GetTail(fa, "seq3", 1)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,195 @@
+package fasta
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// headerChunkSize is the number of bytes read at a time by
+// headerScanner.lineAt.
+const headerChunkSize = 4096
+
+// NewIndexedFromHeaders creates a new Fasta like NewIndexed, building the
+// index in memory from the FASTA file itself. Unlike GenerateIndex, it does
+// not read the whole file: the first body line of each record determines the
+// record's geometry, and the end of the body is then found by seeking to, and
+// reading, O(log n) of its n lines. The lines in between are assumed to
+// follow the geometry, i.e., to be exactly as long as the first, except for a
+// shorter last line, so a malformed file may yield a wrong index; use
+// GenerateIndex to check the whole file. The lines that are read must follow
+// the geometry, and it returns an error otherwise, e.g., for blank lines
+// other than a final one.
+func NewIndexedFromHeaders(fasta io.ReadSeeker, opts ...Opt) (Indexed, error) {
+	size, err := fasta.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	s := &headerScanner{r: fasta, size: size}
+	var entries []IndexEntry
+	for off := int64(0); off < size; {
+		header, err := s.lineAt(off)
+		if err != nil {
+			return nil, err
+		}
+		if header[0] != '>' {
+			if s.isFinalBlank(off, header) {
+				break
+			}
+			return nil, fmt.Errorf("malformed FASTA file: expected a header at offset %d", off)
+		}
+		name := strings.Split(strings.TrimRight(string(header[1:]), "\r\n"), " ")[0]
+		ent := IndexEntry{Name: name, Offset: uint64(off) + uint64(len(header))}
+		if off, err = s.scanBody(&ent); err != nil {
+			return nil, fmt.Errorf("record %s: %v", name, err)
+		}
+		entries = append(entries, ent)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("empty FASTA file")
+	}
+	return newLazyIndexed(fasta, entries, makeOpts(opts...))
+}
+
+// headerScanner reads a FASTA file for NewIndexedFromHeaders.
+type headerScanner struct {
+	r    io.ReadSeeker
+	size int64 // size of the file.
+}
+
+// readAt reads the at most n bytes at offset off, fewer at the end of the
+// file.
+func (s *headerScanner) readAt(off int64, n int) ([]byte, error) {
+	if off >= s.size {
+		return nil, nil
+	}
+	if rem := s.size - off; int64(n) > rem {
+		n = int(rem)
+	}
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// lineAt reads the line starting at offset off, including its terminator. It
+// returns a nil line at the end of the file.
+func (s *headerScanner) lineAt(off int64) ([]byte, error) {
+	var line []byte
+	for {
+		b, err := s.readAt(off+int64(len(line)), headerChunkSize)
+		if err != nil {
+			return nil, err
+		}
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			return append(line, b[:i+1]...), nil
+		}
+		line = append(line, b...)
+		if len(b) < headerChunkSize {
+			return line, nil
+		}
+	}
+}
+
+// isFinalBlank returns whether line, read at offset off, is a blank last line
+// of the file, which is tolerated like in GenerateIndex.
+func (s *headerScanner) isFinalBlank(off int64, line []byte) bool {
+	return len(bytes.TrimRight(line, "\r\n")) == 0 && off+int64(len(line)) == s.size
+}
+
+// scanBody finds the body of the record starting at offset ent.Offset,
+// filling in the length and geometry of ent. It returns the offset of the
+// next header, or the file size at the end of the file.
+func (s *headerScanner) scanBody(ent *IndexEntry) (int64, error) {
+	body := int64(ent.Offset)
+	line, err := s.lineAt(body)
+	if err != nil || line == nil {
+		return s.size, err
+	}
+	if line[0] == '>' {
+		return body, nil // Empty sequence.
+	}
+	trimmed := bytes.TrimRight(line, "\r\n")
+	if len(trimmed) == 0 {
+		if s.isFinalBlank(body, line) {
+			return s.size, nil
+		}
+		return 0, fmt.Errorf("blank line")
+	}
+	ent.LineBase, ent.LineWidth = uint64(len(trimmed)), uint64(len(line))
+	terminator := line[len(trimmed):]
+	if len(terminator) == 0 {
+		ent.Length = ent.LineBase
+		return s.size, nil // Unterminated last line.
+	}
+	width, bases := int64(ent.LineWidth), int(ent.LineBase)
+	// isFull returns whether the i'th line of the body has the geometry of
+	// the first.
+	isFull := func(i int64) (bool, error) {
+		b, err := s.readAt(body+i*width, int(width))
+		if err != nil || int64(len(b)) < width {
+			return false, err
+		}
+		return b[0] != '>' && bytes.IndexByte(b[:bases], '\n') < 0 && bytes.Equal(b[bases:], terminator), nil
+	}
+	// Find the first line that is not full, by doubling the line number, and
+	// then bisecting between the last full line found and it.
+	full, notFull := int64(0), int64(1)
+	for {
+		ok, err := isFull(notFull)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		full, notFull = notFull, 2*notFull
+	}
+	for notFull-full > 1 {
+		mid := full + (notFull-full)/2
+		ok, err := isFull(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			full = mid
+		} else {
+			notFull = mid
+		}
+	}
+	ent.Length = uint64(notFull) * ent.LineBase
+	// What follows must be the next header, or a shorter last line.
+	off := body + notFull*width
+	if line, err = s.lineAt(off); err != nil || line == nil {
+		return s.size, err
+	}
+	if line[0] == '>' {
+		return off, nil
+	}
+	if s.isFinalBlank(off, line) {
+		return s.size, nil
+	}
+	trimmed = bytes.TrimRight(line, "\r\n")
+	if len(trimmed) == 0 || len(trimmed) > bases || bytes.IndexByte(trimmed, '\r') >= 0 ||
+		(len(trimmed) < len(line) && !bytes.Equal(line[len(trimmed):], terminator)) {
+		return 0, fmt.Errorf("line widths are not uniform at base %d", ent.Length)
+	}
+	ent.Length += uint64(len(trimmed))
+	off += int64(len(line))
+	next, err := s.lineAt(off)
+	if err != nil || next == nil {
+		return s.size, err
+	}
+	if next[0] == '>' {
+		return off, nil
+	}
+	if s.isFinalBlank(off, next) {
+		return s.size, nil
+	}
+	return 0, fmt.Errorf("line widths are not uniform at base %d", ent.Length)
+}
@@ -0,0 +1,93 @@
+package fasta_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestNewIndexedFromHeaders(t *testing.T) {
+	for _, data := range []string{
+		fastaData,
+		strings.ReplaceAll(fastaData, "\n", "\r\n"),
+		">a\nACGTA\nCGTAC\n>b\nACGT\n",
+		">a\nACGTA\nCGTAC\nG",
+		">a x\nAC\nGT\nA\n>b\nAC\n",
+		">a\n" + strings.Repeat("ACGTACGTAC\n", 100000) + "ACG\n",
+		// A final blank line is tolerated, like in GenerateIndex.
+		">a\nACGTA\nCGTAC\n\n",
+		">a\nACGTA\nCG\n\n",
+		">a\nAC\n>b\nACGT\r\n\r\n",
+	} {
+		var index bytes.Buffer
+		assert.NoError(t, fasta.GenerateIndex(&index, strings.NewReader(data)))
+		want, err := fasta.NewIndexed(strings.NewReader(data), &index)
+		assert.NoError(t, err)
+		got, err := fasta.NewIndexedFromHeaders(strings.NewReader(data))
+		assert.NoError(t, err, data)
+		assert.EQ(t, got.SeqNames(), want.SeqNames())
+		for _, name := range want.SeqNames() {
+			wantLen, err := want.Len(name)
+			assert.NoError(t, err)
+			gotLen, err := got.Len(name)
+			assert.NoError(t, err)
+			assert.EQ(t, gotLen, wantLen, "%s", name)
+			if wantLen > 0 {
+				wantSeq, err := want.Get(name, 0, wantLen)
+				assert.NoError(t, err)
+				gotSeq, err := got.Get(name, 0, gotLen)
+				assert.NoError(t, err)
+				assert.EQ(t, gotSeq, wantSeq)
+			}
+		}
+	}
+
+	// Unlike GenerateIndex, empty records are kept.
+	fa, err := fasta.NewIndexedFromHeaders(strings.NewReader(">a\nAC\n>empty\n>b\nAC\n"))
+	assert.NoError(t, err)
+	assert.EQ(t, fa.SeqNames(), []string{"a", "empty", "b"})
+	n, err := fa.Len("empty")
+	assert.NoError(t, err)
+	assert.EQ(t, n, uint64(0))
+	seq, err := fa.Get("b", 0, 2)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "AC")
+
+	for _, data := range []string{
+		">a\nACGTA\nCG\nTAC\n",
+		">a\nACGTA\nCGTA\nCGTAC\n",
+		">a\nACGTA\n\nCGTAC\n",
+		">a\nACGTA\nCGTACG\n",
+		">a\nACGTA\r\nCGTAC\n",
+	} {
+		_, err := fasta.NewIndexedFromHeaders(strings.NewReader(data))
+		assert.Regexp(t, err, "not uniform|blank line", data)
+	}
+	// Only a few lines of each record body are read.
+	data := ">a\n" + strings.Repeat("ACGTACGTAC\n", 100000) + "ACG\n>b\n" + strings.Repeat("ACGTACGTAC\n", 100000)
+	r := &countingReadSeeker{ReadSeeker: strings.NewReader(data)}
+	fa, err = fasta.NewIndexedFromHeaders(r)
+	assert.NoError(t, err)
+	assert.LT(t, r.n, 64<<10)
+	n, err = fa.Len("a")
+	assert.NoError(t, err)
+	assert.EQ(t, n, uint64(1000003))
+	n, err = fa.Len("b")
+	assert.NoError(t, err)
+	assert.EQ(t, n, uint64(1000000))
+
+	for _, data := range []string{
+		"\n>a\nAC\n",
+		">a\nAC\n\n>b\nAC\n",
+	} {
+		_, err = fasta.NewIndexedFromHeaders(strings.NewReader(data))
+		assert.NotNil(t, err, data)
+	}
+	_, err = fasta.NewIndexedFromHeaders(strings.NewReader("ACGT\n"))
+	assert.Regexp(t, err, "expected a header")
+	_, err = fasta.NewIndexedFromHeaders(strings.NewReader(""))
+	assert.Regexp(t, err, "empty FASTA file")
+}
@@ -0,0 +1,197 @@
+package fasta
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// httpBlockSize is the size of the blocks fetched and cached by
+	// httpReaderAt.
+	httpBlockSize = 64 * 1024
+	// httpCacheBlocks is the number of blocks cached by httpReaderAt.
+	httpCacheBlocks = 64
+	// httpTimeout is the time limit of the requests made by NewIndexedHTTP
+	// without OptHTTPClient.
+	httpTimeout = time.Minute
+)
+
+// defaultHTTPClient is the client used by NewIndexedHTTP without
+// OptHTTPClient.
+var defaultHTTPClient = &http.Client{Timeout: httpTimeout}
+
+// httpReaderAt is an io.ReaderAt over a URL, served by HTTP range requests of
+// whole blocks. It keeps the most recently used blocks in memory. It is safe
+// for concurrent use, and requests for different blocks proceed in parallel.
+type httpReaderAt struct {
+	client *http.Client
+	url    string
+	size   int64
+
+	mu      sync.Mutex
+	blocks  map[int64]*list.Element // keyed by block index.
+	lru     *list.List              // of *httpBlock, most recently used first.
+	pending map[int64]*httpFetch    // fetches in progress, keyed by block index.
+}
+
+type httpBlock struct {
+	index int64
+	data  []byte
+}
+
+// httpFetch is a fetch of a block, which concurrent readers of the block wait
+// for.
+type httpFetch struct {
+	done chan struct{} // closed once data and err are set.
+	data []byte
+	err  error
+}
+
+func newHTTPReaderAt(client *http.Client, url string) (*httpReaderAt, error) {
+	resp, err := client.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HEAD %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("HEAD %s: unknown content length", url)
+	}
+	return &httpReaderAt{
+		client:  client,
+		url:     url,
+		size:    resp.ContentLength,
+		blocks:  make(map[int64]*list.Element),
+		lru:     list.New(),
+		pending: make(map[int64]*httpFetch),
+	}, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *httpReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		data, err := r.block(off / httpBlockSize)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], data[off%httpBlockSize:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// block returns the contents of the given block, fetching it if it is not
+// cached. r.mu is not held during the fetch; concurrent calls for the same
+// block wait for a single fetch.
+func (r *httpReaderAt) block(index int64) ([]byte, error) {
+	r.mu.Lock()
+	if e, ok := r.blocks[index]; ok {
+		r.lru.MoveToFront(e)
+		r.mu.Unlock()
+		return e.Value.(*httpBlock).data, nil
+	}
+	if p, ok := r.pending[index]; ok {
+		r.mu.Unlock()
+		<-p.done
+		return p.data, p.err
+	}
+	p := &httpFetch{done: make(chan struct{})}
+	r.pending[index] = p
+	r.mu.Unlock()
+
+	start := index * httpBlockSize
+	end := start + httpBlockSize
+	if end > r.size {
+		end = r.size
+	}
+	p.data, p.err = r.fetch(start, end)
+
+	r.mu.Lock()
+	delete(r.pending, index)
+	if p.err == nil {
+		r.blocks[index] = r.lru.PushFront(&httpBlock{index: index, data: p.data})
+		if r.lru.Len() > httpCacheBlocks {
+			e := r.lru.Back()
+			r.lru.Remove(e)
+			delete(r.blocks, e.Value.(*httpBlock).index)
+		}
+	}
+	r.mu.Unlock()
+	close(p.done)
+	return p.data, p.err
+}
+
+// fetch reads the bytes [start, end) of the URL with a range request.
+func (r *httpReaderAt) fetch(start, end int64) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return nil, fmt.Errorf("GET %s: server does not support range requests", r.url)
+	default:
+		return nil, fmt.Errorf("GET %s: %s", r.url, resp.Status)
+	}
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("GET %s: %v", r.url, err)
+	}
+	return data, nil
+}
+
+// NewIndexedHTTP creates a new Fasta like NewIndexed, for a FASTA file and its
+// index served over HTTP. The FASTA file is read with HTTP range requests in
+// blocks of 64KiB, the most recently used of which are cached; the server must
+// support range requests. The index is downloaded during construction. Gets
+// proceed in parallel, as for NewIndexedConcurrent. Requests are made with
+// the client set by OptHTTPClient, or else with a client that times out
+// after a minute.
+func NewIndexedHTTP(url string, faiURL string, opts ...Opt) (Indexed, error) {
+	parsedOpts := makeOpts(opts...)
+	client := parsedOpts.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Get(faiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", faiURL, resp.Status)
+	}
+	entries, err := parseIndex(resp.Body, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newHTTPReaderAt(client, url)
+	if err != nil {
+		return nil, err
+	}
+	data := io.NewSectionReader(r, 0, r.size)
+	f, err := newLazyIndexed(data, entries, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	f.readerAt = data
+	return f, nil
+}
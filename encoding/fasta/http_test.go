@@ -0,0 +1,102 @@
+package fasta_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestIndexedHTTP(t *testing.T) {
+	seq := strings.Repeat("ACGTTGCAAC", 20000)
+	data := ">s\n" + seq + "\n"
+	var rangeRequests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ref.fa", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&rangeRequests, 1)
+		}
+		http.ServeContent(w, r, "ref.fa", time.Time{}, strings.NewReader(data))
+	})
+	mux.HandleFunc("/ref.fa.fai", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("s\t200000\t3\t200000\t200001\n"))
+	})
+	mux.HandleFunc("/norange.fa", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "200004")
+		if r.Method != http.MethodHead {
+			w.Write([]byte(data))
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	fa, err := fasta.NewIndexedHTTP(srv.URL+"/ref.fa", srv.URL+"/ref.fa.fai")
+	assert.NoError(t, err)
+	for _, r := range [][2]uint64{{0, 10}, {199990, 200000}, {65530, 65540}, {1000, 150000}, {5, 15}} {
+		got, err := fa.Get("s", r[0], r[1])
+		assert.NoError(t, err)
+		assert.EQ(t, got, seq[r[0]:r[1]])
+	}
+	// Each 64KiB block is fetched once.
+	assert.EQ(t, atomic.LoadInt32(&rangeRequests), int32(4))
+
+	fa, err = fasta.NewIndexedHTTP(srv.URL+"/norange.fa", srv.URL+"/ref.fa.fai")
+	assert.NoError(t, err)
+	_, err = fa.Get("s", 0, 10)
+	assert.Regexp(t, err, "does not support range requests")
+
+	_, err = fasta.NewIndexedHTTP(srv.URL+"/ref.fa", srv.URL+"/missing.fai")
+	assert.Regexp(t, err, "404")
+}
+
+func TestIndexedHTTPStalled(t *testing.T) {
+	seq := strings.Repeat("ACGTTGCAAC", 20000)
+	data := ">s\n" + seq + "\n"
+	var (
+		started = make(chan struct{}, 1)
+		release = make(chan struct{})
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ref.fa", func(w http.ResponseWriter, r *http.Request) {
+		// Requests for the first block stall.
+		if strings.HasPrefix(r.Header.Get("Range"), "bytes=0-") {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		http.ServeContent(w, r, "ref.fa", time.Time{}, strings.NewReader(data))
+	})
+	mux.HandleFunc("/ref.fa.fai", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("s\t200000\t3\t200000\t200001\n"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	defer close(release)
+
+	fa, err := fasta.NewIndexedHTTP(srv.URL+"/ref.fa", srv.URL+"/ref.fa.fai",
+		fasta.OptHTTPClient(&http.Client{Timeout: time.Second}))
+	assert.NoError(t, err)
+	stalled := make(chan error)
+	go func() {
+		_, err := fa.Get("s", 0, 10)
+		stalled <- err
+	}()
+	<-started
+	// Gets of other blocks are not blocked by the stalled request.
+	got, err := fa.Get("s", 140000, 140010)
+	assert.NoError(t, err)
+	assert.EQ(t, got, seq[140000:140010])
+	// The stalled request times out.
+	assert.Regexp(t, <-stalled, "Timeout|deadline")
+}
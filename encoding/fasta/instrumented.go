@@ -0,0 +1,129 @@
+package fasta
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// numSizeBuckets is the number of buckets of the request size histogram.
+// Bucket i counts requests of [2^(i-1), 2^i) bases, bucket 0 counts empty
+// requests.
+const numSizeBuckets = 65
+
+// Stats records how a Fasta returned by NewInstrumented is accessed. It is
+// safe for concurrent use.
+type Stats struct {
+	// The atomically updated fields come first to keep them 64-bit aligned.
+	bases uint64                 // total bases returned by Get.
+	sizes [numSizeBuckets]uint64 // histogram of requested sizes.
+
+	mu    sync.Mutex
+	calls map[string]*int64 // Get calls per sequence name.
+}
+
+type instrumented struct {
+	Fasta
+	stats *Stats
+}
+
+// NewInstrumented returns a Fasta that behaves like base, but records each Get
+// call in the returned Stats.
+func NewInstrumented(base Fasta) (Fasta, *Stats) {
+	stats := &Stats{calls: make(map[string]*int64)}
+	return &instrumented{Fasta: base, stats: stats}, stats
+}
+
+// Get implements Fasta.Get().
+func (f *instrumented) Get(seqName string, start, end uint64) (string, error) {
+	seq, err := f.Fasta.Get(seqName, start, end)
+	var size uint64
+	if end > start {
+		size = end - start
+	}
+	f.stats.record(seqName, size, uint64(len(seq)))
+	return seq, err
+}
+
+func (s *Stats) record(seqName string, size, bases uint64) {
+	s.mu.Lock()
+	n, ok := s.calls[seqName]
+	if !ok {
+		n = new(int64)
+		s.calls[seqName] = n
+	}
+	s.mu.Unlock()
+	atomic.AddInt64(n, 1)
+	atomic.AddUint64(&s.bases, bases)
+	atomic.AddUint64(&s.sizes[bits.Len64(size)], 1)
+}
+
+// Calls returns the number of Get calls made for each sequence name.
+func (s *Stats) Calls() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := make(map[string]int64, len(s.calls))
+	for name, n := range s.calls {
+		calls[name] = atomic.LoadInt64(n)
+	}
+	return calls
+}
+
+// BasesRead returns the total number of bases returned by Get.
+func (s *Stats) BasesRead() uint64 {
+	return atomic.LoadUint64(&s.bases)
+}
+
+// SizeHistogram returns the histogram of requested range sizes (end-start).
+// Element i counts the requests of [2^(i-1), 2^i) bases; element 0 counts
+// empty ranges.
+func (s *Stats) SizeHistogram() []uint64 {
+	hist := make([]uint64, numSizeBuckets)
+	for i := range hist {
+		hist[i] = atomic.LoadUint64(&s.sizes[i])
+	}
+	return hist
+}
+
+// Report returns a human-readable summary of the statistics.
+func (s *Stats) Report() string {
+	var b strings.Builder
+	calls := s.Calls()
+	names := make([]string, 0, len(calls))
+	var total int64
+	for name, n := range calls {
+		names = append(names, name)
+		total += n
+	}
+	// Most-accessed sequences first.
+	sort.Slice(names, func(i, j int) bool {
+		if calls[names[i]] != calls[names[j]] {
+			return calls[names[i]] > calls[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintf(&b, "calls: %d, bases read: %d\n", total, s.BasesRead())
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %s: %d calls\n", name, calls[name])
+	}
+	b.WriteString("request sizes:\n")
+	for i, n := range s.SizeHistogram() {
+		if n == 0 {
+			continue
+		}
+		if i == 0 {
+			fmt.Fprintf(&b, "  0: %d\n", n)
+			continue
+		}
+		if i == numSizeBuckets-1 {
+			// 2^64 does not fit in a uint64.
+			fmt.Fprintf(&b, "  [%d, inf): %d\n", uint64(1)<<(i-1), n)
+			continue
+		}
+		fmt.Fprintf(&b, "  [%d, %d): %d\n", uint64(1)<<(i-1), uint64(1)<<i, n)
+	}
+	return b.String()
+}
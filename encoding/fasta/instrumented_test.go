@@ -0,0 +1,62 @@
+package fasta_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestInstrumented(t *testing.T) {
+	base, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	fa, stats := fasta.NewInstrumented(base)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq, err := fa.Get("seq1", 0, 4)
+			assert.NoError(t, err)
+			assert.EQ(t, seq, "AcGT")
+		}()
+	}
+	wg.Wait()
+	_, err = fa.Get("seq2", 0, 8)
+	assert.NoError(t, err)
+	_, err = fa.Get("seq3", 0, 1)
+	assert.NotNil(t, err)
+
+	assert.EQ(t, stats.Calls(), map[string]int64{"seq1": 10, "seq2": 1, "seq3": 1})
+	assert.EQ(t, stats.BasesRead(), uint64(48))
+	hist := stats.SizeHistogram()
+	assert.EQ(t, hist[1], uint64(1))  // size 1
+	assert.EQ(t, hist[3], uint64(10)) // size 4
+	assert.EQ(t, hist[4], uint64(1))  // size 8
+	assert.EQ(t, stats.Report(), `calls: 12, bases read: 48
+  seq1: 10 calls
+  seq2: 1 calls
+  seq3: 1 calls
+request sizes:
+  [1, 2): 1
+  [4, 8): 10
+  [8, 16): 1
+`)
+	l, err := fa.Len("seq2")
+	assert.NoError(t, err)
+	assert.EQ(t, l, uint64(8))
+
+	// The last bucket has no upper bound.
+	fa, stats = fasta.NewInstrumented(base)
+	_, err = fa.Get("seq1", 0, 1<<63)
+	assert.NotNil(t, err)
+	assert.EQ(t, stats.SizeHistogram()[64], uint64(1))
+	assert.EQ(t, stats.Report(), `calls: 1, bases read: 0
+  seq1: 1 calls
+request sizes:
+  [9223372036854775808, inf): 1
+`)
+}
@@ -0,0 +1,85 @@
+package fasta
+
+import (
+	"sort"
+)
+
+// placement is the range of the primary assembly that a sequence covers.
+type placement struct {
+	name       string
+	start, end uint64
+}
+
+// IntervalIndex finds the sequences of a Fasta, such as the alt loci of a
+// pan-genome reference, whose placements on a primary assembly overlap a
+// query. It is immutable, and safe for concurrent use.
+type IntervalIndex struct {
+	byName map[string]placement
+	// sorted holds the placements ordered by start, then name. maxEnd[i] is
+	// the largest end of sorted[:i+1], which bounds the search for
+	// placements that end after a query starts.
+	sorted []placement
+	maxEnd []uint64
+}
+
+// NewIntervalIndex returns an IntervalIndex over the sequences of f.
+// placements maps sequence names to the range [start, end) they cover on the
+// coordinate axis of the primary assembly, e.g., positions in the
+// concatenation of its chromosomes. Placements of sequences not in f, and
+// empty ones, are ignored.
+func NewIntervalIndex(f Fasta, placements map[string][2]uint64) *IntervalIndex {
+	x := &IntervalIndex{byName: make(map[string]placement, len(placements))}
+	for name, rng := range placements {
+		if rng[0] >= rng[1] {
+			continue
+		}
+		if _, err := f.Len(name); err != nil {
+			continue
+		}
+		p := placement{name: name, start: rng[0], end: rng[1]}
+		x.byName[name] = p
+		x.sorted = append(x.sorted, p)
+	}
+	sort.Slice(x.sorted, func(i, j int) bool {
+		if x.sorted[i].start != x.sorted[j].start {
+			return x.sorted[i].start < x.sorted[j].start
+		}
+		return x.sorted[i].name < x.sorted[j].name
+	})
+	x.maxEnd = make([]uint64, len(x.sorted))
+	for i, p := range x.sorted {
+		x.maxEnd[i] = p.end
+		if i > 0 && x.maxEnd[i-1] > p.end {
+			x.maxEnd[i] = x.maxEnd[i-1]
+		}
+	}
+	return x
+}
+
+// Overlapping returns the names of the sequences, other than name itself,
+// whose placements overlap the bases [start, end) of the sequence name, as
+// mapped onto the primary assembly through its placement. The names are
+// ordered by the start of their placements. It returns nil if name has no
+// placement or the range is empty.
+func (x *IntervalIndex) Overlapping(name string, start, end uint64) []string {
+	p, ok := x.byName[name]
+	if !ok || start >= end || start >= p.end-p.start {
+		return nil
+	}
+	qStart, qEnd := p.start+start, p.start+end
+	if qEnd > p.end {
+		qEnd = p.end
+	}
+	// Placements starting at or after qEnd cannot overlap.
+	n := sort.Search(len(x.sorted), func(i int) bool { return x.sorted[i].start >= qEnd })
+	var overlapping []string
+	for i := n - 1; i >= 0 && x.maxEnd[i] > qStart; i-- {
+		if o := x.sorted[i]; o.end > qStart && o.name != name {
+			overlapping = append(overlapping, o.name)
+		}
+	}
+	for i, j := 0, len(overlapping)-1; i < j; i, j = i+1, j-1 {
+		overlapping[i], overlapping[j] = overlapping[j], overlapping[i]
+	}
+	return overlapping
+}
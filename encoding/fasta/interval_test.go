@@ -0,0 +1,43 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestIntervalIndex(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(">chr1\nACGTACGTAC\n>alt1\nACG\n>alt2\nACGTA\n>alt3\nA\n>chr2\nACGTACGT\n"))
+	assert.NoError(t, err)
+	x := fasta.NewIntervalIndex(fa, map[string][2]uint64{
+		"chr1":    {0, 10},
+		"alt1":    {2, 5},
+		"alt2":    {4, 9},
+		"alt3":    {12, 13},
+		"chr2":    {10, 18},
+		"missing": {0, 18},
+		"empty":   {3, 3},
+	})
+	for _, tt := range []struct {
+		name       string
+		start, end uint64
+		want       []string
+	}{
+		{"chr1", 0, 10, []string{"alt1", "alt2"}},
+		{"chr1", 0, 2, nil},
+		{"chr1", 4, 5, []string{"alt1", "alt2"}},
+		{"chr1", 5, 6, []string{"alt2"}},
+		{"chr1", 9, 20, nil},
+		{"alt1", 0, 1, []string{"chr1"}},
+		{"alt1", 2, 3, []string{"chr1", "alt2"}},
+		{"chr2", 0, 8, []string{"alt3"}},
+		{"alt3", 0, 1, []string{"chr2"}},
+		{"chr2", 3, 3, nil},
+		{"missing", 0, 1, nil},
+		{"chr3", 0, 1, nil},
+	} {
+		assert.EQ(t, x.Overlapping(tt.name, tt.start, tt.end), tt.want, "%s:%d-%d", tt.name, tt.start, tt.end)
+	}
+}
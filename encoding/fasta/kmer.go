@@ -0,0 +1,275 @@
+package fasta
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Schaudge/grailbase/bitset"
+)
+
+// baseCode maps the bytes representing A, C, G and T in the ASCII and Seq8
+// encodings to 0, 1, 2 and 3 respectively, and all other bytes to 4.
+var baseCode = func() (t [256]byte) {
+	for i := range t {
+		t[i] = 4
+	}
+	for code, bases := range []string{"Aa\x01", "Cc\x02", "Gg\x04", "Tt\x08"} {
+		for i := 0; i < len(bases); i++ {
+			t[bases[i]] = byte(code)
+		}
+	}
+	return
+}()
+
+// maxPackedK is the largest k-mer size supported by KmerBloom and KmerFreqs,
+// limited by the 2-bit packing of k-mers into a uint64.
+const maxPackedK = 32
+
+// KmerBloom is a bloom filter of the canonical k-mers of a FASTA, built by
+// BuildKmerBloom. The canonical form of a k-mer is the lexicographically
+// smaller of the k-mer and its reverse complement. It is safe for concurrent
+// use.
+type KmerBloom struct {
+	k       int
+	nHashes int
+	nBits   uint64
+	bits    []uintptr
+}
+
+// kmerPacker computes the 2-bit packed forms of a k-mer and its reverse
+// complement as bases are added one at a time.
+type kmerPacker struct {
+	k        int
+	mask     uint64
+	fwd, rev uint64
+	n        int // number of valid bases at the end of fwd.
+}
+
+func newKmerPacker(k int) kmerPacker {
+	mask := ^uint64(0)
+	if k < maxPackedK {
+		mask = uint64(1)<<(2*uint(k)) - 1
+	}
+	return kmerPacker{k: k, mask: mask}
+}
+
+// add appends a base, and returns the canonical packed form of the k-mer
+// ending with it, or false if the last k bases include a non-ACGT base.
+func (p *kmerPacker) add(b byte) (uint64, bool) {
+	code := uint64(baseCode[b])
+	if code > 3 {
+		p.n = 0
+		return 0, false
+	}
+	p.fwd = (p.fwd<<2 | code) & p.mask
+	p.rev = p.rev>>2 | (3-code)<<(2*uint(p.k-1))
+	if p.n < p.k {
+		p.n++
+	}
+	if p.n < p.k {
+		return 0, false
+	}
+	if p.rev < p.fwd {
+		return p.rev, true
+	}
+	return p.fwd, true
+}
+
+// unpackKmer returns the bases of the k-mer packed by kmerPacker, in uppercase.
+func unpackKmer(kmer uint64, k int) string {
+	buf := make([]byte, k)
+	for i := k - 1; i >= 0; i-- {
+		buf[i] = "ACGT"[kmer&3]
+		kmer >>= 2
+	}
+	return string(buf)
+}
+
+// mix64 is the finalizer of the splitmix64 generator.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// BuildKmerBloom streams every sequence of f and returns a bloom filter of
+// their canonical k-mers, sized for a false positive rate of about fpRate. k
+// must be in [1, 32]. K-mers containing bases other than A, C, G and T (in
+// either case) are skipped. The filter is sized by the total sequence length,
+// which bounds the number of distinct k-mers.
+func BuildKmerBloom(f Fasta, k int, fpRate float64) (*KmerBloom, error) {
+	if k < 1 || k > maxPackedK {
+		return nil, fmt.Errorf("k must be in [1, %d]: %d", maxPackedK, k)
+	}
+	if !(fpRate > 0 && fpRate < 1) {
+		return nil, fmt.Errorf("false positive rate must be in (0, 1): %v", fpRate)
+	}
+	var total uint64
+	for _, seqName := range f.SeqNames() {
+		n, err := f.Len(seqName)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	if total == 0 {
+		total = 1
+	}
+	// Optimal parameters for n items: m = -n ln(p) / ln(2)^2 bits and
+	// m/n ln(2) hash functions.
+	nBits := uint64(math.Ceil(-float64(total) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	nHashes := int(math.Round(float64(nBits) / float64(total) * math.Ln2))
+	if nHashes < 1 {
+		nHashes = 1
+	}
+	bf := &KmerBloom{
+		k:       k,
+		nHashes: nHashes,
+		nBits:   nBits,
+		bits:    bitset.NewClearBits(int(nBits)),
+	}
+	for _, seqName := range f.SeqNames() {
+		p := newKmerPacker(k)
+		err := streamSeq(f, seqName, true, func(_ uint64, chunk string) bool {
+			for i := 0; i < len(chunk); i++ {
+				if kmer, ok := p.add(chunk[i]); ok {
+					bf.insert(kmer)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bf, nil
+}
+
+// K returns the k-mer size of the filter.
+func (bf *KmerBloom) K() int {
+	return bf.k
+}
+
+// positions calls fn with the bit positions of the packed k-mer.
+func (bf *KmerBloom) positions(kmer uint64, fn func(pos int) bool) {
+	h1 := mix64(kmer)
+	h2 := mix64(kmer^0x9e3779b97f4a7c15) | 1
+	for i := 0; i < bf.nHashes; i++ {
+		if !fn(int((h1 + uint64(i)*h2) % bf.nBits)) {
+			return
+		}
+	}
+}
+
+func (bf *KmerBloom) insert(kmer uint64) {
+	bf.positions(kmer, func(pos int) bool {
+		bitset.Set(bf.bits, pos)
+		return true
+	})
+}
+
+// MayContain returns false if kmer, or its reverse complement, definitely
+// does not occur in the FASTA the filter was built from. A true result may be
+// a false positive. It returns false if len(kmer) differs from K() or kmer
+// contains bases other than A, C, G and T.
+func (bf *KmerBloom) MayContain(kmer string) bool {
+	if len(kmer) != bf.k {
+		return false
+	}
+	p := newKmerPacker(bf.k)
+	var (
+		packed uint64
+		ok     bool
+	)
+	for i := 0; i < len(kmer); i++ {
+		packed, ok = p.add(kmer[i])
+	}
+	if !ok {
+		return false
+	}
+	found := true
+	bf.positions(packed, func(pos int) bool {
+		found = bitset.Test(bf.bits, pos)
+		return found
+	})
+	return found
+}
+
+type kmerFreqOpts struct {
+	canonical bool
+	frame     int
+	inFrame   bool
+}
+
+// KmerFreqOpt is an optional argument to KmerFreqs.
+type KmerFreqOpt func(*kmerFreqOpts)
+
+// OptCanonicalKmers makes KmerFreqs count each k-mer under its canonical form,
+// the lexicographically smaller of the k-mer and its reverse complement.
+func OptCanonicalKmers(o *kmerFreqOpts) {
+	o.canonical = true
+}
+
+// OptKmerFrame makes KmerFreqs count only the non-overlapping k-mers starting
+// frame bases after the start of the region, and every k bases from there,
+// e.g., the codons of a reading frame for k=3. frame must be in [0, k).
+func OptKmerFrame(frame int) KmerFreqOpt {
+	return func(o *kmerFreqOpts) {
+		o.frame, o.inFrame = frame, true
+	}
+}
+
+// KmerFreqs slides a window of k bases over the bases [start, end) of the
+// given sequence, and returns the number of occurrences of each k-mer, in
+// uppercase. For k=2 these are the dinucleotide frequencies. k must be in [1,
+// 32]. K-mers containing bases other than A, C, G and T (in either case) are
+// skipped.
+func KmerFreqs(f Fasta, seqName string, start, end uint64, k int, opts ...KmerFreqOpt) (map[string]uint64, error) {
+	var o kmerFreqOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if k < 1 || k > maxPackedK {
+		return nil, fmt.Errorf("k must be in [1, %d]: %d", maxPackedK, k)
+	}
+	if o.inFrame && (o.frame < 0 || o.frame >= k) {
+		return nil, fmt.Errorf("frame must be in [0, %d): %d", k, o.frame)
+	}
+	if err := checkRange(start, end); err != nil {
+		return nil, err
+	}
+	counts := make(map[uint64]uint64)
+	p := newKmerPacker(k)
+	err := streamRange(f, seqName, start, end, true, func(chunkStart uint64, chunk string) bool {
+		for i := 0; i < len(chunk); i++ {
+			canonical, ok := p.add(chunk[i])
+			if !ok {
+				continue
+			}
+			if o.inFrame {
+				// The offset in the region of the first base of the k-mer.
+				offset := chunkStart + uint64(i) + 1 - uint64(k) - start
+				if offset < uint64(o.frame) || (offset-uint64(o.frame))%uint64(k) != 0 {
+					continue
+				}
+			}
+			if o.canonical {
+				counts[canonical]++
+			} else {
+				counts[p.fwd]++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	freqs := make(map[string]uint64, len(counts))
+	for kmer, n := range counts {
+		freqs[unpackKmer(kmer, k)] = n
+	}
+	return freqs, nil
+}
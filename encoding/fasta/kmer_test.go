@@ -0,0 +1,91 @@
+package fasta_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestKmerBloom(t *testing.T) {
+	const k = 21
+	r := rand.New(rand.NewSource(0))
+	randSeq := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "ACGT"[r.Intn(4)]
+		}
+		return string(b)
+	}
+	seq1, seq2 := randSeq(5000), randSeq(3000)
+	data := ">seq1\n" + strings.ToLower(seq1[:100]) + seq1[100:] + "\n>seq2\n" + seq2[:1000] + "NN" + seq2[1000:] + "\n"
+	fa, err := fasta.New(strings.NewReader(data))
+	assert.NoError(t, err)
+	bf, err := fasta.BuildKmerBloom(fa, k, 0.01)
+	assert.NoError(t, err)
+	assert.EQ(t, bf.K(), k)
+
+	revComp := func(s string) string {
+		b := make([]byte, len(s))
+		for i := range s {
+			b[len(s)-1-i] = map[byte]byte{'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A'}[s[i]]
+		}
+		return string(b)
+	}
+	for _, seq := range []string{seq1, seq2[:1000], seq2[1000:]} {
+		for i := 0; i+k <= len(seq); i += 7 {
+			kmer := seq[i : i+k]
+			assert.True(t, bf.MayContain(kmer), kmer)
+			assert.True(t, bf.MayContain(revComp(kmer)), kmer)
+		}
+	}
+	assert.False(t, bf.MayContain(seq1[:k-1]))
+	assert.False(t, bf.MayContain(strings.Repeat("N", k)))
+
+	var falsePositives int
+	const trials = 10000
+	for i := 0; i < trials; i++ {
+		if bf.MayContain(randSeq(k)) {
+			falsePositives++
+		}
+	}
+	assert.True(t, falsePositives < trials/20, "%d false positives", falsePositives)
+
+	_, err = fasta.BuildKmerBloom(fa, 33, 0.01)
+	assert.NotNil(t, err)
+	_, err = fasta.BuildKmerBloom(fa, k, 0)
+	assert.NotNil(t, err)
+}
+
+func TestKmerFreqs(t *testing.T) {
+	fa, err := fasta.New(strings.NewReader(fastaData + ">seq3\nACNGTT\n"))
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		seq        string
+		start, end uint64
+		k          int
+		opts       []fasta.KmerFreqOpt
+		want       map[string]uint64
+	}{
+		{"seq1", 0, 12, 2, nil, map[string]uint64{"AC": 3, "CG": 3, "GT": 3, "TA": 2}},
+		{"seq1", 0, 12, 2, []fasta.KmerFreqOpt{fasta.OptCanonicalKmers}, map[string]uint64{"AC": 6, "CG": 3, "TA": 2}},
+		{"seq1", 2, 5, 1, nil, map[string]uint64{"G": 1, "T": 1, "A": 1}},
+		{"seq1", 0, 12, 3, []fasta.KmerFreqOpt{fasta.OptKmerFrame(0)}, map[string]uint64{"ACG": 1, "TAC": 1, "GTA": 1, "CGT": 1}},
+		{"seq1", 0, 12, 3, []fasta.KmerFreqOpt{fasta.OptKmerFrame(1)}, map[string]uint64{"CGT": 1, "ACG": 1, "TAC": 1}},
+		{"seq1", 1, 12, 3, []fasta.KmerFreqOpt{fasta.OptKmerFrame(2)}, map[string]uint64{"TAC": 1, "GTA": 1, "CGT": 1}},
+		{"seq3", 0, 6, 2, nil, map[string]uint64{"AC": 1, "GT": 1, "TT": 1}},
+		{"seq3", 0, 2, 3, nil, map[string]uint64{}},
+	} {
+		freqs, err := fasta.KmerFreqs(fa, tt.seq, tt.start, tt.end, tt.k, tt.opts...)
+		assert.NoError(t, err)
+		assert.EQ(t, freqs, tt.want, "%+v", tt)
+	}
+	_, err = fasta.KmerFreqs(fa, "seq1", 0, 12, 33)
+	assert.Regexp(t, err, "k must be in")
+	_, err = fasta.KmerFreqs(fa, "seq1", 0, 12, 3, fasta.OptKmerFrame(3))
+	assert.Regexp(t, err, "frame must be in")
+	_, err = fasta.KmerFreqs(fa, "seq1", 0, 13, 3)
+	assert.NotNil(t, err)
+}
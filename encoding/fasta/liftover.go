@@ -0,0 +1,245 @@
+package fasta
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Schaudge/grailbio/biosimd"
+)
+
+// chainBlock is an ungapped aligned block of a chain. tStart and qStart are
+// 0-based positions in the target and query sequence respectively. qStart is
+// on the strand given by the chain's qStrand.
+type chainBlock struct {
+	tStart, qStart, size uint64
+}
+
+// chain is one alignment chain of a UCSC chain file.
+type chain struct {
+	score        int64
+	tName        string
+	tSize        uint64
+	qName        string
+	qSize        uint64
+	qReverse     bool
+	blocks       []chainBlock // sorted by tStart.
+	tStart, tEnd uint64
+	qStart, qEnd uint64
+}
+
+// ChainFile is a parsed UCSC chain file
+// (https://genome.ucsc.edu/goldenPath/help/chain.html) describing a mapping from
+// the coordinates of an old ("target") assembly to a new ("query") assembly.
+type ChainFile struct {
+	chains   map[string][]*chain // keyed by tName, sorted by decreasing score.
+	tNames   []string            // in order of first appearance.
+	tLengths map[string]uint64
+}
+
+// ReadChainFile parses a UCSC chain file.
+func ReadChainFile(r io.Reader) (*ChainFile, error) {
+	cf := &ChainFile{
+		chains:   make(map[string][]*chain),
+		tLengths: make(map[string]uint64),
+	}
+	scanner := bufio.NewScanner(r)
+	var (
+		c          *chain
+		tPos, qPos uint64
+		lineNum    int
+	)
+	parseUint := func(s string) (uint64, error) {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("chain file line %d: %v", lineNum, err)
+		}
+		return v, nil
+	}
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "chain" {
+			if c != nil {
+				return nil, fmt.Errorf("chain file line %d: chain is missing its final block", lineNum)
+			}
+			if len(fields) < 12 {
+				return nil, fmt.Errorf("chain file line %d: malformed chain header: %s", lineNum, line)
+			}
+			c = &chain{tName: fields[2], qName: fields[7]}
+			var err error
+			if c.score, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+				return nil, fmt.Errorf("chain file line %d: %v", lineNum, err)
+			}
+			for _, f := range []struct {
+				s string
+				v *uint64
+			}{{fields[3], &c.tSize}, {fields[5], &c.tStart}, {fields[6], &c.tEnd},
+				{fields[8], &c.qSize}, {fields[10], &c.qStart}, {fields[11], &c.qEnd}} {
+				if *f.v, err = parseUint(f.s); err != nil {
+					return nil, err
+				}
+			}
+			if c.tStart > c.tEnd || c.tEnd > c.tSize || c.qStart > c.qEnd || c.qEnd > c.qSize {
+				return nil, fmt.Errorf("chain file line %d: chain coordinates out of range: %s", lineNum, line)
+			}
+			if fields[4] != "+" {
+				return nil, fmt.Errorf("chain file line %d: target strand must be '+'", lineNum)
+			}
+			c.qReverse = fields[9] == "-"
+			tPos, qPos = c.tStart, c.qStart
+			continue
+		}
+		if c == nil {
+			return nil, fmt.Errorf("chain file line %d: alignment data outside a chain", lineNum)
+		}
+		if len(fields) != 1 && len(fields) != 3 {
+			return nil, fmt.Errorf("chain file line %d: malformed alignment data: %s", lineNum, line)
+		}
+		size, err := parseUint(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		c.blocks = append(c.blocks, chainBlock{tStart: tPos, qStart: qPos, size: size})
+		tPos += size
+		qPos += size
+		if len(fields) == 1 { // Last block of the chain.
+			if tPos != c.tEnd || qPos != c.qEnd {
+				return nil, fmt.Errorf("chain file line %d: blocks do not end at the chain end", lineNum)
+			}
+			if _, ok := cf.tLengths[c.tName]; !ok {
+				cf.tNames = append(cf.tNames, c.tName)
+				cf.tLengths[c.tName] = c.tSize
+			}
+			cf.chains[c.tName] = append(cf.chains[c.tName], c)
+			c = nil
+			continue
+		}
+		dt, err := parseUint(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		dq, err := parseUint(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		tPos += dt
+		qPos += dq
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if c != nil {
+		return nil, fmt.Errorf("chain file: chain is missing its final block")
+	}
+	for _, chains := range cf.chains {
+		sort.SliceStable(chains, func(i, j int) bool { return chains[i].score > chains[j].score })
+	}
+	return cf, nil
+}
+
+type liftoverView struct {
+	base  Fasta
+	chain *ChainFile
+}
+
+// NewLiftoverView returns a Fasta that presents the sequences of base, a FASTA
+// of the new (query) assembly of the chain file, in the coordinates of the old
+// (target) assembly. SeqNames and Len report the target sequences of the
+// chain file.
+//
+// Get(seqName, start, end) returns, for each target position in [start, end),
+// the base it maps to in the new assembly, reverse-complemented if the chain
+// maps to the reverse strand. The whole range must lie within ungapped blocks
+// of a single chain, otherwise Get returns an error. Reverse-complementing is
+// only supported for the ASCII encodings of base.
+func NewLiftoverView(base Fasta, chain *ChainFile) Fasta {
+	return &liftoverView{base: base, chain: chain}
+}
+
+// Get implements Fasta.Get().
+func (v *liftoverView) Get(seqName string, start, end uint64) (string, error) {
+	if err := checkRange(start, end); err != nil {
+		return "", err
+	}
+	chains, ok := v.chain.chains[seqName]
+	if !ok {
+		return "", fmt.Errorf("sequence not found in chain file: %s", seqName)
+	}
+	for _, c := range chains {
+		if start < c.tStart || end > c.tEnd {
+			continue
+		}
+		seq, ok, err := v.getFromChain(c, start, end)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return seq, nil
+		}
+	}
+	return "", fmt.Errorf("region %s:%d-%d cannot be lifted over", seqName, start, end)
+}
+
+// getFromChain lifts [start, end) over using the given chain. It returns false
+// if the region is not covered by the blocks of c.
+func (v *liftoverView) getFromChain(c *chain, start, end uint64) (string, bool, error) {
+	// Find the last block starting at or before start.
+	i := sort.Search(len(c.blocks), func(i int) bool { return c.blocks[i].tStart > start }) - 1
+	if i < 0 {
+		return "", false, nil
+	}
+	var result strings.Builder
+	result.Grow(int(end - start))
+	for pos := start; pos < end; i++ {
+		if i >= len(c.blocks) {
+			return "", false, nil
+		}
+		b := c.blocks[i]
+		if pos < b.tStart || pos >= b.tStart+b.size {
+			return "", false, nil // pos lies in a gap.
+		}
+		pieceEnd := b.tStart + b.size
+		if pieceEnd > end {
+			pieceEnd = end
+		}
+		qStart := b.qStart + (pos - b.tStart)
+		qEnd := qStart + (pieceEnd - pos)
+		if c.qReverse {
+			qStart, qEnd = c.qSize-qEnd, c.qSize-qStart
+		}
+		seq, err := v.base.Get(c.qName, qStart, qEnd)
+		if err != nil {
+			return "", false, err
+		}
+		if c.qReverse {
+			buf := []byte(seq)
+			biosimd.ReverseComp8Inplace(buf)
+			seq = string(buf)
+		}
+		result.WriteString(seq)
+		pos = pieceEnd
+	}
+	return result.String(), true, nil
+}
+
+// Len implements Fasta.Len().
+func (v *liftoverView) Len(seqName string) (uint64, error) {
+	n, ok := v.chain.tLengths[seqName]
+	if !ok {
+		return 0, fmt.Errorf("sequence not found in chain file: %s", seqName)
+	}
+	return n, nil
+}
+
+// SeqNames implements Fasta.SeqNames().
+func (v *liftoverView) SeqNames() []string {
+	return v.chain.tNames
+}
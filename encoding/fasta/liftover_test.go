@@ -0,0 +1,51 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestLiftoverView(t *testing.T) {
+	base, err := fasta.New(strings.NewReader(">new1\nAAAACCCCGGGGTTAC\n"))
+	assert.NoError(t, err)
+	chain, err := fasta.ReadChainFile(strings.NewReader(`chain 100 old1 12 + 0 12 new1 16 + 0 14 1
+4 0 4
+4 2 0
+2
+
+chain 50 old2 4 + 0 4 new1 16 - 0 4 2
+4
+`))
+	assert.NoError(t, err)
+	view := fasta.NewLiftoverView(base, chain)
+	assert.EQ(t, view.SeqNames(), []string{"old1", "old2"})
+	n, err := view.Len("old1")
+	assert.NoError(t, err)
+	assert.EQ(t, n, uint64(12))
+
+	tests := []struct {
+		seq        string
+		start, end uint64
+		want       string
+		wantErr    bool
+	}{
+		{"old1", 0, 8, "AAAAGGGG", false},
+		{"old1", 2, 5, "AAG", false},
+		{"old1", 10, 12, "TT", false},
+		{"old1", 6, 10, "", true}, // Spans a gap in the target.
+		{"old2", 0, 4, "GTAA", false},
+		{"old2", 1, 3, "TA", false},
+		{"old3", 0, 1, "", true},
+	}
+	for _, tt := range tests {
+		got, err := view.Get(tt.seq, tt.start, tt.end)
+		assert.EQ(t, err != nil, tt.wantErr, "%s:%d-%d: %v", tt.seq, tt.start, tt.end, err)
+		assert.EQ(t, got, tt.want, "%s:%d-%d", tt.seq, tt.start, tt.end)
+	}
+
+	_, err = fasta.ReadChainFile(strings.NewReader("chain 100 old1 12 + 0 12 new1 16 + 0 14 1\n4 0 4\n"))
+	assert.NotNil(t, err)
+}
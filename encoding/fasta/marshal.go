@@ -0,0 +1,93 @@
+package fasta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// marshaledIndexVersion is the first byte of the data returned by
+// MarshalIndex. It changes whenever the layout does.
+const marshaledIndexVersion = 1
+
+// MarshalIndex implements Indexed.MarshalIndex(). After the version byte, the
+// data holds the number of entries, and then, for each entry, the length of
+// its name, its name, and its Length, Offset, LineBase and LineWidth, with all
+// integers in unsigned varint encoding.
+func (f *indexedFasta) MarshalIndex() ([]byte, error) {
+	entries := f.currentIndex().parsed
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64*(1+6*len(entries)))
+	buf = append(buf, marshaledIndexVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(entries)))
+	for _, ent := range entries {
+		buf = binary.AppendUvarint(buf, uint64(len(ent.Name)))
+		buf = append(buf, ent.Name...)
+		for _, v := range [...]uint64{ent.Length, ent.Offset, ent.LineBase, ent.LineWidth} {
+			buf = binary.AppendUvarint(buf, v)
+		}
+	}
+	return buf, nil
+}
+
+// NewIndexedFromMarshaled is like NewIndexed, but takes an index returned by
+// MarshalIndex. Of the options, OptFullLineName is not relevant, since the
+// names are stored as parsed.
+func NewIndexedFromMarshaled(fasta io.ReadSeeker, data []byte, opts ...Opt) (Indexed, error) {
+	entries, err := unmarshalIndex(data)
+	if err != nil {
+		return nil, err
+	}
+	return newLazyIndexed(fasta, entries, makeOpts(opts...))
+}
+
+func unmarshalIndex(data []byte) ([]IndexEntry, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("marshaled index is empty")
+	}
+	if data[0] != marshaledIndexVersion {
+		return nil, fmt.Errorf("unsupported marshaled index version %d", data[0])
+	}
+	pos := 1
+	next := func() (uint64, error) {
+		v, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return 0, fmt.Errorf("marshaled index is corrupt at byte %d", pos)
+		}
+		pos += n
+		return v, nil
+	}
+	n, err := next()
+	if err != nil {
+		return nil, err
+	}
+	// Each entry takes at least 5 bytes, which bounds the allocation for
+	// corrupt data.
+	if n > uint64(len(data)-pos)/5 {
+		return nil, fmt.Errorf("marshaled index is corrupt: %d entries in %d bytes", n, len(data))
+	}
+	entries := make([]IndexEntry, n)
+	for i := range entries {
+		ent := &entries[i]
+		nameLen, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if nameLen > uint64(len(data)-pos) {
+			return nil, fmt.Errorf("marshaled index is corrupt at byte %d", pos)
+		}
+		ent.Name = string(data[pos : pos+int(nameLen)])
+		pos += int(nameLen)
+		for _, field := range [...]*uint64{&ent.Length, &ent.Offset, &ent.LineBase, &ent.LineWidth} {
+			if *field, err = next(); err != nil {
+				return nil, err
+			}
+		}
+		if err := validateIndexEntry(*ent); err != nil {
+			return nil, err
+		}
+	}
+	if pos != len(data) {
+		return nil, fmt.Errorf("marshaled index has %d trailing bytes", len(data)-pos)
+	}
+	return entries, nil
+}
@@ -0,0 +1,53 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestMarshalIndex(t *testing.T) {
+	index := "seq2\t8\t44\t4\t5\nseq1\t12\t6\t5\t6\n"
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(index), fasta.OptNormalizeName(strings.ToUpper))
+	assert.NoError(t, err)
+	data, err := fa.MarshalIndex()
+	assert.NoError(t, err)
+	assert.EQ(t, data[0], byte(1))
+
+	fa2, err := fasta.NewIndexedFromMarshaled(strings.NewReader(fastaData), data)
+	assert.NoError(t, err)
+	assert.EQ(t, fa2.SeqNames(), []string{"seq1", "seq2"})
+	assert.EQ(t, fa2.IndexFileOrder(), []string{"seq2", "seq1"})
+	seq, err := fa2.Get("seq1", 3, 9)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "TACGTA")
+	fa2, err = fasta.NewIndexedFromMarshaled(strings.NewReader(fastaData), data, fasta.OptNormalizeName(strings.ToUpper))
+	assert.NoError(t, err)
+	assert.EQ(t, fa2.SeqNames(), fa.SeqNames())
+	data2, err := fa2.MarshalIndex()
+	assert.NoError(t, err)
+	assert.EQ(t, data2, data)
+
+	// Corrupt data is rejected without panicking.
+	for i := 0; i < len(data); i++ {
+		_, err := fasta.NewIndexedFromMarshaled(strings.NewReader(fastaData), data[:i])
+		assert.NotNil(t, err, "truncated to %d bytes", i)
+	}
+	_, err = fasta.NewIndexedFromMarshaled(strings.NewReader(fastaData), append(data[:len(data):len(data)], 0))
+	assert.Regexp(t, err, "1 trailing bytes")
+	_, err = fasta.NewIndexedFromMarshaled(strings.NewReader(fastaData), append([]byte{2}, data[1:]...))
+	assert.Regexp(t, err, "unsupported marshaled index version 2")
+	_, err = fasta.NewIndexedFromMarshaled(strings.NewReader(fastaData), []byte{1, 0xff, 0xff, 0xff, 0xff, 0x0f})
+	assert.Regexp(t, err, "corrupt")
+
+	// Entries are validated as when parsing a text index.
+	fa, err = fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader("seq1\t12\t6\t5\t6\n"))
+	assert.NoError(t, err)
+	data, err = fa.MarshalIndex()
+	assert.NoError(t, err)
+	data[len(data)-1] = 4 // LineWidth < LineBase.
+	_, err = fasta.NewIndexedFromMarshaled(strings.NewReader(fastaData), data)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,208 @@
+package fasta
+
+import (
+	"io"
+
+	"github.com/Schaudge/grailbase/bitset"
+	"github.com/Schaudge/grailbase/simd"
+	"github.com/Schaudge/grailbase/tsv"
+	"github.com/Schaudge/grailbase/unsafe"
+)
+
+// MaskBitmap streams the given sequence and returns a bitset, in the format
+// used by github.com/Schaudge/grailbase/bitset, with bit p set iff the base at
+// position p is lowercase (soft-masked). Use bitset.Test(bm, p) to query it.
+//
+// Combined with a Fasta created with OptClean, this separates the cleaned
+// bases from the soft-mask track. If f is an Indexed, the original case is
+// recovered regardless of its encoding; otherwise f must use RawASCII.
+func MaskBitmap(f Fasta, seqName string) ([]uintptr, error) {
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return nil, err
+	}
+	bm := bitset.NewClearBits(int(seqLen))
+	err = streamSeq(f, seqName, true, func(start uint64, chunk string) bool {
+		for i := 0; i < len(chunk); i++ {
+			if isLower(chunk[i]) {
+				bitset.Set(bm, int(start)+i)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bm, nil
+}
+
+// WriteMaskBED streams the given sequence and writes a BED line
+// "<name>\t<start>\t<end>" for each maximal run of soft-masked (lowercase)
+// bases, in increasing order, as in the repeat-mask tracks of genome
+// browsers. As for MaskBitmap, only 'a'-'z' count as lowercase. If f is an
+// Indexed, the original case is recovered regardless of its encoding;
+// otherwise f must use RawASCII.
+func WriteMaskBED(f Fasta, seqName string, out io.Writer) error {
+	w := tsv.NewWriter(out)
+	if err := writeMaskBED(f, seqName, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// WriteAllMaskBED is like WriteMaskBED, but covers all sequences of f, in
+// SeqNames() order.
+func WriteAllMaskBED(f Fasta, out io.Writer) error {
+	w := tsv.NewWriter(out)
+	for _, seqName := range f.SeqNames() {
+		if err := writeMaskBED(f, seqName, w); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func writeMaskBED(f Fasta, seqName string, w *tsv.Writer) error {
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return err
+	}
+	var (
+		inRun    bool
+		runStart uint64
+		writeErr error
+	)
+	emit := func(end uint64) bool {
+		w.WriteString(seqName)
+		w.WriteInt64(int64(runStart))
+		w.WriteInt64(int64(end))
+		writeErr = w.EndLine()
+		return writeErr == nil
+	}
+	err = streamSeq(f, seqName, true, func(start uint64, chunk string) bool {
+		bases := unsafe.StringToBytes(chunk)
+		for pos := 0; pos < len(bases); pos++ {
+			if !inRun {
+				// Bytes up to '`' are not lowercase, so runs of them, e.g.
+				// unmasked bases, are skipped with a SIMD scan.
+				if pos = simd.FirstGreater8(bases, '`', pos); pos == len(bases) {
+					break
+				}
+				if isLower(bases[pos]) {
+					inRun, runStart = true, start+uint64(pos)
+				}
+			} else if !isLower(bases[pos]) {
+				inRun = false
+				if !emit(start + uint64(pos)) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr == nil && inRun {
+		emit(seqLen)
+	}
+	return writeErr
+}
+
+// CaseBoundaries returns the positions, relative to start, of the bases in
+// [start, end) of the given sequence whose case differs from that of the
+// preceding base, i.e., where soft-masked (lowercase) stretches begin and end
+// within the range. Bytes other than lowercase letters count as uppercase. If
+// f is an Indexed, the original case is recovered regardless of its encoding;
+// otherwise f must use RawASCII.
+func CaseBoundaries(f Fasta, seqName string, start, end uint64) ([]uint64, error) {
+	seq, err := getRaw(f, seqName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var boundaries []uint64
+	for i := 1; i < len(seq); i++ {
+		if isLower(seq[i]) != isLower(seq[i-1]) {
+			boundaries = append(boundaries, uint64(i))
+		}
+	}
+	return boundaries, nil
+}
+
+// GetMaskAnnotated returns the bases [start, end) of the given sequence
+// converted to uppercase, along with a slice holding, for each base, whether
+// it was lowercase (soft-masked), computed in a single pass over the bases. If
+// f is an Indexed, the original case is recovered regardless of its encoding;
+// otherwise f must use RawASCII.
+func GetMaskAnnotated(f Fasta, seqName string, start, end uint64) (bases string, mask []bool, err error) {
+	seq, err := getRaw(f, seqName, start, end)
+	if err != nil {
+		return "", nil, err
+	}
+	buf := make([]byte, len(seq))
+	mask = make([]bool, len(seq))
+	for i := 0; i < len(seq); i++ {
+		c := seq[i]
+		if isLower(c) {
+			c -= 'a' - 'A'
+			mask[i] = true
+		}
+		buf[i] = c
+	}
+	return unsafe.BytesToString(buf), mask, nil
+}
+
+type maskOpts struct {
+	lower bool
+}
+
+// MaskOpt is an optional argument to GetMasked.
+type MaskOpt func(*maskOpts)
+
+// OptLowercaseMask makes GetMasked mask bases with 'n' instead of 'N'.
+func OptLowercaseMask(o *maskOpts) {
+	o.lower = true
+}
+
+// GetMasked is like f.Get, but returns the bases [start, end) of the given
+// sequence with those within any of maskRanges, [start, end) ranges of the
+// sequence, replaced by 'N'. Mask ranges may overlap each other and extend
+// beyond [start, end). If f is an Indexed, the masked bases are the original
+// bytes of the FASTA file, regardless of its encoding; otherwise f must use
+// RawASCII.
+func GetMasked(f Fasta, seqName string, start, end uint64, maskRanges [][2]uint64, opts ...MaskOpt) (string, error) {
+	var o maskOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	seq, err := getRaw(f, seqName, start, end)
+	if err != nil {
+		return "", err
+	}
+	mask := byte('N')
+	if o.lower {
+		mask = 'n'
+	}
+	buf := []byte(seq)
+	for _, r := range maskRanges {
+		mStart, mEnd := r[0], r[1]
+		if mStart < start {
+			mStart = start
+		}
+		if mEnd > end {
+			mEnd = end
+		}
+		if mStart >= mEnd {
+			continue
+		}
+		dst := buf[mStart-start : mEnd-start]
+		for i := range dst {
+			dst[i] = mask
+		}
+	}
+	return unsafe.BytesToString(buf), nil
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
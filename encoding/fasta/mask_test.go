@@ -0,0 +1,117 @@
+package fasta_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbase/bitset"
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestMaskBitmap(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptClean)
+	assert.NoError(t, err)
+	bm, err := fasta.MaskBitmap(fa, "seq1")
+	assert.NoError(t, err)
+	for p := 0; p < 12; p++ {
+		assert.EQ(t, bitset.Test(bm, p), p == 1, "position %d", p)
+	}
+	// The cleaned sequence is unaffected.
+	seq, err := fa.Get("seq1", 0, 3)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACG")
+
+	_, err = fasta.MaskBitmap(fa, "seq0")
+	assert.NotNil(t, err)
+}
+
+func TestCaseBoundaries(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(">s\nACgtaC\nGTNnnA\n"), strings.NewReader("s\t12\t3\t6\t7\n"), fasta.OptClean)
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		start, end uint64
+		want       []uint64
+	}{
+		{0, 12, []uint64{2, 5, 9, 11}},
+		{2, 5, nil},
+		{1, 4, []uint64{1}},
+		{4, 12, []uint64{1, 5, 7}},
+	} {
+		got, err := fasta.CaseBoundaries(fa, "s", tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, got, tt.want, "%+v", tt)
+	}
+	_, err = fasta.CaseBoundaries(fa, "s", 0, 13)
+	assert.NotNil(t, err)
+}
+
+func TestGetMasked(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptClean)
+	assert.NoError(t, err)
+	// seq1 is AcGTACGTACGT.
+	seq, err := fasta.GetMasked(fa, "seq1", 1, 11, [][2]uint64{{0, 3}, {5, 6}, {5, 7}, {9, 20}, {4, 4}})
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "NNTANNTANN")
+	seq, err = fasta.GetMasked(fa, "seq1", 0, 4, [][2]uint64{{2, 3}, {6, 8}}, fasta.OptLowercaseMask)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "AcnT")
+	seq, err = fasta.GetMasked(fa, "seq2", 0, 8, nil)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+	_, err = fasta.GetMasked(fa, "seq1", 0, 13, nil)
+	assert.NotNil(t, err)
+}
+
+func TestGetMaskAnnotated(t *testing.T) {
+	data := ">s\nACgtNn\nacGT\n"
+	indexed, err := fasta.NewIndexed(strings.NewReader(data), strings.NewReader("s\t10\t3\t6\t7\n"), fasta.OptClean)
+	assert.NoError(t, err)
+	eager, err := fasta.New(strings.NewReader(data))
+	assert.NoError(t, err)
+	for _, fa := range []fasta.Fasta{indexed, eager} {
+		bases, mask, err := fasta.GetMaskAnnotated(fa, "s", 1, 9)
+		assert.NoError(t, err)
+		assert.EQ(t, bases, "CGTNNACG")
+		assert.EQ(t, mask, []bool{false, true, true, false, true, true, true, false})
+		_, _, err = fasta.GetMaskAnnotated(fa, "s", 1, 11)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestWriteMaskBED(t *testing.T) {
+	const data = ">a\nacGTNn\nACgt\n>b\nACGT\n>c\nac\n"
+	fa, err := fasta.NewIndexed(strings.NewReader(data),
+		strings.NewReader("a\t10\t3\t6\t7\nb\t4\t18\t4\t5\nc\t2\t26\t2\t3\n"), fasta.OptClean)
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	assert.NoError(t, fasta.WriteMaskBED(fa, "a", &out))
+	assert.EQ(t, out.String(), "a\t0\t2\na\t5\t6\na\t8\t10\n")
+	out.Reset()
+	assert.NoError(t, fasta.WriteAllMaskBED(fa, &out))
+	assert.EQ(t, out.String(), "a\t0\t2\na\t5\t6\na\t8\t10\nc\t0\t2\n")
+	assert.NotNil(t, fasta.WriteMaskBED(fa, "d", &out))
+
+	// Runs spanning the chunks in which the sequence is streamed.
+	seq := strings.Repeat("ACGT", 1<<20) + strings.Repeat("acgt", 1<<18) + "A"
+	seq = seq[:3<<19] + "tt" + seq[3<<19+2:]
+	long, err := fasta.New(strings.NewReader(">long\n" + seq + "\n"))
+	assert.NoError(t, err)
+	out.Reset()
+	assert.NoError(t, fasta.WriteMaskBED(long, "long", &out))
+	assert.EQ(t, out.String(), "long\t1572864\t1572866\nlong\t4194304\t5242880\n")
+
+	// Bytes above '`' other than lowercase letters are not masked, as for
+	// MaskBitmap.
+	other, err := fasta.New(strings.NewReader(">o\nac{|}~\x80xA*n\n"))
+	assert.NoError(t, err)
+	out.Reset()
+	assert.NoError(t, fasta.WriteMaskBED(other, "o", &out))
+	assert.EQ(t, out.String(), "o\t0\t2\no\t7\t8\no\t10\t11\n")
+	bm, err := fasta.MaskBitmap(other, "o")
+	assert.NoError(t, err)
+	for i := 0; i < 11; i++ {
+		assert.EQ(t, bitset.Test(bm, i), i < 2 || i == 7 || i == 10, "position %d", i)
+	}
+}
@@ -0,0 +1,124 @@
+package fasta
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// m5Verifier is implemented by the Fastas that accept OptVerifyM5.
+type m5Verifier interface {
+	// expectedM5 returns the expected digest of the given sequence, if any.
+	expectedM5(seqName string) (string, bool)
+}
+
+func (f *fasta) expectedM5(seqName string) (string, bool) {
+	m5, ok := f.verifyM5[seqName]
+	return m5, ok
+}
+
+func (f *indexedFasta) expectedM5(seqName string) (string, bool) {
+	m5, ok := f.opts.VerifyM5[seqName]
+	return m5, ok
+}
+
+// SeqMD5 streams the given sequence and returns its MD5 digest as computed
+// for the M5 tag of SAM @SQ lines: over the bases converted to uppercase,
+// excluding whitespace and other non-printable characters. The digest is a
+// lowercase hexadecimal string. If f is an Indexed, it is computed on the
+// original bases regardless of the encoding of f; otherwise f must use
+// RawASCII. If f was created with OptVerifyM5, the digest is checked.
+func SeqMD5(f Fasta, seqName string) (string, error) {
+	h := md5.New()
+	var buf []byte
+	err := streamSeq(f, seqName, true, func(_ uint64, chunk string) bool {
+		buf = buf[:0]
+		for i := 0; i < len(chunk); i++ {
+			c := chunk[i]
+			if c < '!' || c > '~' {
+				continue
+			}
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			buf = append(buf, c)
+		}
+		h.Write(buf)
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	if v, ok := f.(m5Verifier); ok {
+		if want, ok := v.expectedM5(seqName); ok && !strings.EqualFold(want, digest) {
+			return "", fmt.Errorf("MD5 mismatch for sequence %s: expected %s, got %s", seqName, want, digest)
+		}
+	}
+	return digest, nil
+}
+
+// SeqRCMD5 is like SeqMD5, but returns the digest of the reverse complement of
+// the sequence, with bases complemented as by Complement. Since MD5 consumes
+// its input in order, the sequence is read backwards: chunks of up to
+// streamChunkSize bases are fetched starting from the end of the sequence,
+// and each is reversed and complemented in a reused buffer before being
+// hashed. Memory use is thus bounded by the chunk size, however long the
+// sequence. The digest is not checked against OptVerifyM5.
+func SeqRCMD5(f Fasta, seqName string) (string, error) {
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return "", err
+	}
+	h := md5.New()
+	var buf []byte
+	for end := seqLen; end > 0; {
+		start := uint64(0)
+		if end > streamChunkSize {
+			start = end - streamChunkSize
+		}
+		chunk, err := getRaw(f, seqName, start, end)
+		if err != nil {
+			return "", err
+		}
+		buf = buf[:0]
+		for i := len(chunk) - 1; i >= 0; i-- {
+			c := chunk[i]
+			if c < '!' || c > '~' {
+				continue
+			}
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			buf = append(buf, complementTable[c])
+		}
+		h.Write(buf)
+		end = start
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// GetAll returns the whole given sequence. If f was created with OptVerifyM5
+// and the sequence has an expected digest, GetAll also checks it with SeqMD5,
+// which reads the sequence a second time.
+func GetAll(f Fasta, seqName string) (string, error) {
+	n, err := f.Len(seqName)
+	if err != nil {
+		return "", err
+	}
+	var seq string
+	if n > 0 {
+		if seq, err = f.Get(seqName, 0, n); err != nil {
+			return "", err
+		}
+	}
+	if v, ok := f.(m5Verifier); ok {
+		if _, ok := v.expectedM5(seqName); ok {
+			if _, err := SeqMD5(f, seqName); err != nil {
+				return "", err
+			}
+		}
+	}
+	return seq, nil
+}
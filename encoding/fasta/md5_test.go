@@ -0,0 +1,81 @@
+package fasta_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestSeqMD5(t *testing.T) {
+	const (
+		seq1MD5 = "31e91beccf6059ff57c696827c0c6a4b"
+		seq2MD5 = "cc0af3a4fedb18378b4b57b98068e69f"
+	)
+	m5 := map[string]string{"seq1": strings.ToUpper(seq1MD5), "seq2": "00000000000000000000000000000000"}
+	for _, fa := range []fasta.Fasta{
+		func() fasta.Fasta {
+			fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+				fasta.OptClean, fasta.OptVerifyM5(m5))
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.New(strings.NewReader(fastaData), fasta.OptVerifyM5(m5))
+			assert.NoError(t, err)
+			return fa
+		}(),
+	} {
+		digest, err := fasta.SeqMD5(fa, "seq1")
+		assert.NoError(t, err)
+		assert.EQ(t, digest, seq1MD5)
+		seq, err := fasta.GetAll(fa, "seq1")
+		assert.NoError(t, err)
+		assert.EQ(t, strings.ToUpper(seq), "ACGTACGTACGT")
+
+		_, err = fasta.SeqMD5(fa, "seq2")
+		assert.Regexp(t, err, "MD5 mismatch for sequence seq2: expected 0+, got "+seq2MD5)
+		_, err = fasta.GetAll(fa, "seq2")
+		assert.Regexp(t, err, "MD5 mismatch")
+		_, err = fasta.GetAll(fa, "seq3")
+		assert.NotNil(t, err)
+	}
+
+	// Without OptVerifyM5, digests are not checked.
+	fa, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	digest, err := fasta.SeqMD5(fa, "seq2")
+	assert.NoError(t, err)
+	assert.EQ(t, digest, seq2MD5)
+}
+
+func TestSeqRCMD5(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptClean)
+	assert.NoError(t, err)
+	// seq1 is its own reverse complement.
+	digest, err := fasta.SeqRCMD5(fa, "seq1")
+	assert.NoError(t, err)
+	assert.EQ(t, digest, "31e91beccf6059ff57c696827c0c6a4b")
+	_, err = fasta.SeqRCMD5(fa, "seq3")
+	assert.NotNil(t, err)
+
+	// A sequence spanning several chunks.
+	r := rand.New(rand.NewSource(0))
+	seq := make([]byte, 5<<20/2)
+	for i := range seq {
+		seq[i] = "ACGTNacgtn"[r.Intn(10)]
+	}
+	long, err := fasta.New(strings.NewReader(">long\n" + string(seq) + "\n"))
+	assert.NoError(t, err)
+	rc := make([]byte, len(seq))
+	fasta.ReverseComplement(rc, bytes.ToUpper(seq))
+	want := md5.Sum(rc)
+	digest, err = fasta.SeqRCMD5(long, "long")
+	assert.NoError(t, err)
+	assert.EQ(t, digest, hex.EncodeToString(want[:]))
+}
@@ -0,0 +1,80 @@
+package fasta
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Schaudge/grailbase/unsafe"
+)
+
+// encodeCached returns seq, a substring of a cached sequence in RawASCII,
+// in the given encoding.
+func encodeCached(seq string, enc Encoding) string {
+	if enc == RawASCII {
+		return seq
+	}
+	if enc == TwoBit {
+		return packTwoBit(seq)
+	}
+	buf := []byte(seq)
+	encodeInplace(buf, enc)
+	return unsafe.BytesToString(buf)
+}
+
+// Prefetch implements Indexed.Prefetch().
+func (f *indexedFasta) Prefetch(seqNames ...string) error {
+	idx := f.currentIndex()
+	seqs := make(map[string]string, len(seqNames))
+	for _, seqName := range seqNames {
+		ent, ok := f.lookup(seqName)
+		if !ok {
+			return fmt.Errorf("sequence not found in index: %s", seqName)
+		}
+		var seq strings.Builder
+		seq.Grow(int(ent.Length))
+		for start := uint64(0); start < ent.Length; start += streamChunkSize {
+			end := start + streamChunkSize
+			if end > ent.Length {
+				end = ent.Length
+			}
+			// Bypass the current cache, so that Prefetch rereads the file.
+			chunk, err := f.readChunk(ent, start, end)
+			if err != nil {
+				return err
+			}
+			seq.WriteString(chunk)
+		}
+		seqs[ent.Name] = seq.String()
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.currentIndex() != idx {
+		return fmt.Errorf("index was reloaded during Prefetch")
+	}
+	for name, seq := range f.cache {
+		if _, ok := seqs[name]; !ok {
+			seqs[name] = seq
+		}
+	}
+	f.cache = seqs
+	return nil
+}
+
+// readChunk reads the bases [start, end) of the sequence described by ent from
+// the file, in RawASCII.
+func (f *indexedFasta) readChunk(ent IndexEntry, start, end uint64) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return "", fmt.Errorf("Prefetch called on a closed Fasta")
+	}
+	return f.getEntry(ent, start, end, RawASCII)
+}
+
+// Reset implements Indexed.Reset().
+func (f *indexedFasta) Reset() {
+	f.mutex.Lock()
+	f.cache = nil
+	f.mutex.Unlock()
+}
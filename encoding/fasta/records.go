@@ -0,0 +1,143 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Record describes the layout of one FASTA record in a file, as found by
+// ScanRecords.
+type Record struct {
+	// Name is the sequence name: the header line up to the first space.
+	Name string
+	// HeaderOffset is the file offset of the record's '>'.
+	HeaderOffset int64
+	// BodyOffset is the file offset of the first byte after the header line.
+	BodyOffset int64
+	// BodyBytes is the number of bytes from BodyOffset to the next header or
+	// the end of the file, including line terminators.
+	BodyBytes int64
+	// Length is the number of bases of the sequence.
+	Length uint64
+	// LineBases and LineWidth are the number of bases in the first line of the
+	// body, and its length including the line terminator. They are zero for an
+	// empty sequence.
+	LineBases, LineWidth uint64
+}
+
+// RecordIter iterates over the records of a FASTA file.
+type RecordIter interface {
+	// Scan advances to the next record, which is then available through
+	// Record. It returns false at the end of the file or on error.
+	Scan() bool
+	// Record returns the record found by the last call to Scan.
+	Record() Record
+	// Err returns the error, if any, that stopped the iteration.
+	Err() error
+}
+
+type recordIter struct {
+	r      *bufio.Reader
+	off    int64 // file offset of the next byte of r.
+	cur    Record
+	err    error
+	done   bool
+	header []byte // header line of the next record, without '>'.
+	hdrOff int64
+}
+
+// ScanRecords returns an iterator over the records of the FASTA file r,
+// starting at its current offset. It reads the file sequentially without
+// decoding the sequences, reporting the byte layout of each record. Offsets
+// are relative to the start of r.
+func ScanRecords(r io.ReadSeeker) (RecordIter, error) {
+	off, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	it := &recordIter{r: bufio.NewReader(r), off: off}
+	// Find the first header, skipping blank lines.
+	for {
+		line, err := it.readLine()
+		if len(bytes.TrimRight(line, "\r\n")) > 0 {
+			if line[0] != '>' {
+				lineOff := it.off - int64(len(line))
+				if line[0] == '@' {
+					it.readLine() // The sequence line.
+					if third, _ := it.readLine(); isFASTQStart(line, third) {
+						return nil, ErrLooksLikeFASTQ
+					}
+				}
+				return nil, fmt.Errorf("malformed FASTA file: data before the first header at offset %d", lineOff)
+			}
+			it.header, it.hdrOff = bytes.TrimRight(line[1:], "\r\n"), it.off-int64(len(line))
+			return it, nil
+		}
+		if err == io.EOF {
+			it.done = true
+			return it, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine reads the next line of the file, including its terminator.
+func (it *recordIter) readLine() ([]byte, error) {
+	line, err := it.r.ReadBytes('\n')
+	it.off += int64(len(line))
+	return line, err
+}
+
+// Scan implements RecordIter.Scan().
+func (it *recordIter) Scan() bool {
+	if it.done {
+		return false
+	}
+	rec := Record{
+		Name:         strings.Split(string(it.header), " ")[0],
+		HeaderOffset: it.hdrOff,
+		BodyOffset:   it.off,
+	}
+	it.header = nil
+	for {
+		line, err := it.readLine()
+		if err != nil && err != io.EOF {
+			it.err, it.done = err, true
+			return false
+		}
+		trimmed := bytes.TrimRight(line, "\r\n")
+		if len(trimmed) > 0 && trimmed[0] == '>' {
+			it.header, it.hdrOff = trimmed[1:], it.off-int64(len(line))
+			rec.BodyBytes = it.hdrOff - rec.BodyOffset
+			break
+		}
+		if len(trimmed) > 0 {
+			if rec.LineWidth == 0 {
+				rec.LineBases, rec.LineWidth = uint64(len(trimmed)), uint64(len(line))
+			}
+			rec.Length += uint64(len(trimmed))
+		}
+		if err == io.EOF {
+			rec.BodyBytes = it.off - rec.BodyOffset
+			it.done = true
+			break
+		}
+	}
+	it.cur = rec
+	return true
+}
+
+// Record implements RecordIter.Record().
+func (it *recordIter) Record() Record {
+	return it.cur
+}
+
+// Err implements RecordIter.Err().
+func (it *recordIter) Err() error {
+	return it.err
+}
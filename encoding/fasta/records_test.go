@@ -0,0 +1,58 @@
+package fasta_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func scanAllRecords(t *testing.T, r io.ReadSeeker) []fasta.Record {
+	it, err := fasta.ScanRecords(r)
+	assert.NoError(t, err)
+	var records []fasta.Record
+	for it.Scan() {
+		records = append(records, it.Record())
+	}
+	assert.NoError(t, it.Err())
+	return records
+}
+
+func TestScanRecords(t *testing.T) {
+	assert.EQ(t, scanAllRecords(t, strings.NewReader(fastaData)), []fasta.Record{
+		{Name: "seq1", HeaderOffset: 0, BodyOffset: 6, BodyBytes: 15, Length: 12, LineBases: 5, LineWidth: 6},
+		{Name: "seq2", HeaderOffset: 21, BodyOffset: 44, BodyBytes: 10, Length: 8, LineBases: 4, LineWidth: 5},
+	})
+
+	// Offsets are relative to the start of the reader, and the last line need
+	// not be terminated.
+	r := strings.NewReader("XX\n\n>a\r\nAC\r\nG\r\n>b\n>c x\nTT")
+	_, err := r.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EQ(t, scanAllRecords(t, r), []fasta.Record{
+		{Name: "a", HeaderOffset: 4, BodyOffset: 8, BodyBytes: 7, Length: 3, LineBases: 2, LineWidth: 4},
+		{Name: "b", HeaderOffset: 15, BodyOffset: 18, BodyBytes: 0},
+		{Name: "c", HeaderOffset: 18, BodyOffset: 23, BodyBytes: 2, Length: 2, LineBases: 2, LineWidth: 2},
+	})
+
+	assert.EQ(t, len(scanAllRecords(t, strings.NewReader(""))), 0)
+	_, err = fasta.ScanRecords(strings.NewReader("ACGT\n>a\n"))
+	assert.Regexp(t, err, "data before the first header")
+}
+
+func TestFASTQInput(t *testing.T) {
+	const fastq = "@read1 x\nACGT\n+\nIIII\n@read2\nAC\n+read2\nII\n"
+	_, err := fasta.ScanRecords(strings.NewReader(fastq))
+	assert.True(t, errors.Is(err, fasta.ErrLooksLikeFASTQ))
+	_, err = fasta.New(strings.NewReader("\n" + fastq))
+	assert.True(t, errors.Is(err, fasta.ErrLooksLikeFASTQ))
+
+	// Other data before the first header is not mistaken for FASTQ.
+	_, err = fasta.ScanRecords(strings.NewReader("@x\nAC\nGT\n>a\nAC\n"))
+	assert.Regexp(t, err, "data before the first header at offset 0")
+	_, err = fasta.New(strings.NewReader(">a\n@x\nAC\n+\n"))
+	assert.NoError(t, err)
+}
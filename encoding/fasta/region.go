@@ -0,0 +1,24 @@
+package fasta
+
+import (
+	"fmt"
+)
+
+// Region is a 0-based half-open range [Start, End) of a sequence.
+type Region struct {
+	SeqName    string
+	Start, End uint64
+}
+
+// String returns the region in "name:start-end" form, with 0-based half-open
+// coordinates.
+func (r Region) String() string {
+	return fmt.Sprintf("%s:%d-%d", r.SeqName, r.Start, r.End)
+}
+
+// StrandedRange is a 0-based half-open range [Start, End) of a sequence, on
+// the reverse strand if Reverse is set.
+type StrandedRange struct {
+	Start, End uint64
+	Reverse    bool
+}
@@ -0,0 +1,124 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// maxRepairHeaderLen bounds the length of the header lines that
+// RepairIndexOffsets checks.
+const maxRepairHeaderLen = 64 << 10
+
+// RepairIndexOffsets detects indexes (*.fai) whose offsets are all off by the
+// same amount, as after adding or removing lines at the start of the FASTA
+// file, and returns the corrected index entries. It finds the header of the
+// sequence with the lowest offset in the FASTA file to compute the shift, then
+// checks that every shifted offset follows the header line of its sequence.
+// It returns an error if any does not, i.e., the offsets are not shifted by a
+// constant.
+func RepairIndexOffsets(fasta io.ReadSeeker, index io.Reader) ([]IndexEntry, error) {
+	entries, err := parseIndex(index, opts{})
+	if err != nil || len(entries) == 0 {
+		return entries, err
+	}
+	first := entries[0]
+	for _, ent := range entries[1:] {
+		if ent.Offset < first.Offset {
+			first = ent
+		}
+	}
+	bodyOffset, err := findBodyOffset(fasta, first.Name)
+	if err != nil {
+		return nil, err
+	}
+	delta := bodyOffset - int64(first.Offset)
+	var buf []byte
+	for i := range entries {
+		ent := &entries[i]
+		off := int64(ent.Offset) + delta
+		if off < 0 {
+			return nil, fmt.Errorf("offsets are not shifted by a constant %d: sequence %s would start at offset %d",
+				delta, ent.Name, off)
+		}
+		if buf, err = readHeaderBefore(fasta, off, buf); err != nil {
+			return nil, err
+		}
+		if !isHeaderOf(buf, ent.Name) {
+			return nil, fmt.Errorf("offsets are not shifted by a constant %d: no header of sequence %s before offset %d",
+				delta, ent.Name, off)
+		}
+		ent.Offset = uint64(off)
+	}
+	return entries, nil
+}
+
+// findBodyOffset returns the offset of the first byte after the header line of
+// the given sequence in r. Unlike ScanRecords, it tolerates other data before
+// the header.
+func findBodyOffset(r io.ReadSeeker, seqName string) (int64, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	br := bufio.NewReader(r)
+	var (
+		off     int64
+		atStart = true // whether off is at the start of a line.
+		found   bool   // whether the header has been found.
+	)
+	for {
+		// Long lines are returned in pieces, with bufio.ErrBufferFull.
+		piece, err := br.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return 0, err
+		}
+		off += int64(len(piece))
+		if atStart && isHeaderOf(bytes.TrimRight(piece, "\r\n"), seqName) {
+			found = true
+		}
+		atStart = len(piece) > 0 && piece[len(piece)-1] == '\n'
+		if found && (atStart || err == io.EOF) {
+			return off, nil
+		}
+		if err == io.EOF {
+			return 0, fmt.Errorf("header of sequence %s not found", seqName)
+		}
+	}
+}
+
+// readHeaderBefore returns the line of r ending just before off, without its
+// line terminator, or an empty line if off does not follow a newline. It uses
+// buf as scratch space.
+func readHeaderBefore(r io.ReadSeeker, off int64, buf []byte) ([]byte, error) {
+	start := off - maxRepairHeaderLen
+	if start < 0 {
+		start = 0
+	}
+	if cap(buf) < int(off-start) {
+		buf = make([]byte, off-start)
+	}
+	buf = buf[:off-start]
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	buf = buf[:n]
+	if n < int(off-start) || n == 0 || buf[n-1] != '\n' {
+		return buf[:0], nil
+	}
+	line := bytes.TrimSuffix(buf[:n-1], []byte{'\r'})
+	return line[bytes.LastIndexByte(line, '\n')+1:], nil
+}
+
+// isHeaderOf reports whether line is a FASTA header line of the given
+// sequence.
+func isHeaderOf(line []byte, seqName string) bool {
+	if len(line) < 1+len(seqName) || line[0] != '>' || string(line[1:1+len(seqName)]) != seqName {
+		return false
+	}
+	return len(line) == 1+len(seqName) || line[1+len(seqName)] == ' ' || line[1+len(seqName)] == '\t'
+}
@@ -0,0 +1,49 @@
+package fasta_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestRepairIndexOffsets(t *testing.T) {
+	want, err := fasta.ReadIndex(strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	got, err := fasta.RepairIndexOffsets(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	assert.EQ(t, got, want)
+
+	// Adding a comment line shifts both offsets by its length.
+	data := "; A comment.\n" + fastaData
+	got, err = fasta.RepairIndexOffsets(strings.NewReader(data), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	assert.EQ(t, len(got), 2)
+	assert.EQ(t, got[0].Offset, want[0].Offset+13)
+	assert.EQ(t, got[1].Offset, want[1].Offset+13)
+	var index strings.Builder
+	for _, ent := range got {
+		fmt.Fprintf(&index, "%s\t%d\t%d\t%d\t%d\n", ent.Name, ent.Length, ent.Offset, ent.LineBase, ent.LineWidth)
+	}
+	fa, err := fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index.String()))
+	assert.NoError(t, err)
+	seq, err := fa.Get("seq2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+
+	// Lengthening the second header shifts only the second offset.
+	data = strings.Replace(fastaData, "A viral sequence", "A longer viral sequence", 1)
+	_, err = fasta.RepairIndexOffsets(strings.NewReader(data), strings.NewReader(fastaIndex))
+	assert.Regexp(t, err, "offsets are not shifted by a constant 0: no header of sequence seq2 before offset 44")
+
+	// A CRLF file indexed as LF.
+	data = strings.ReplaceAll(fastaData, "\n", "\r\n")
+	_, err = fasta.RepairIndexOffsets(strings.NewReader(data), strings.NewReader(fastaIndex))
+	assert.Regexp(t, err, "offsets are not shifted by a constant 1: no header of sequence seq2")
+
+	data = strings.Replace(fastaData, ">seq1", ">chr1", 1)
+	_, err = fasta.RepairIndexOffsets(strings.NewReader(data), strings.NewReader(fastaIndex))
+	assert.Regexp(t, err, "header of sequence seq1 not found")
+}
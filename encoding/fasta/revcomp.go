@@ -0,0 +1,70 @@
+package fasta
+
+import (
+	"github.com/Schaudge/grailbase/unsafe"
+	"github.com/Schaudge/grailbio/biosimd"
+)
+
+// complementTable maps each IUPAC nucleotide code to its complement,
+// preserving case. Other bytes map to themselves.
+var complementTable = func() (t [256]byte) {
+	for i := range t {
+		t[i] = byte(i)
+	}
+	for _, pair := range []string{"AT", "CG", "RY", "KM", "BV", "DH", "SS", "WW", "NN"} {
+		for _, p := range []string{pair, string([]byte{pair[0] + 'a' - 'A', pair[1] + 'a' - 'A'})} {
+			t[p[0]], t[p[1]] = p[1], p[0]
+		}
+	}
+	t['U'], t['u'] = 'A', 'a'
+	return
+}()
+
+// Complement returns the complement of the IUPAC nucleotide code b, preserving
+// case: A<->T, C<->G, R<->Y, K<->M, B<->V, D<->H, and S, W and N are their own
+// complements. U is complemented to A. Other bytes are returned unchanged.
+func Complement(b byte) byte {
+	return complementTable[b]
+}
+
+// ReverseComplement writes the reverse complement of src, as defined by
+// Complement, to dst. dst and src must have the same length, and must either
+// be the same slice or not overlap. Sequences consisting only of uppercase
+// A/C/G/T/N are processed with SIMD.
+func ReverseComplement(dst, src []byte) {
+	n := len(src)
+	if len(dst) != n {
+		panic("fasta.ReverseComplement: dst and src must have the same length")
+	}
+	if n == 0 {
+		return
+	}
+	if !biosimd.IsNonACGTNPresent(src) {
+		if &dst[0] == &src[0] {
+			biosimd.ReverseComp8InplaceNoValidate(dst)
+		} else {
+			biosimd.ReverseComp8NoValidate(dst, src)
+		}
+		return
+	}
+	for i, j := 0, n-1; i <= j; i, j = i+1, j-1 {
+		a, b := src[i], src[j]
+		dst[i], dst[j] = complementTable[b], complementTable[a]
+	}
+}
+
+// GetComplement is like f.Get, but returns the complement, as defined by
+// Complement, of the original bases, without reversing them. This is the
+// other strand as displayed below the top strand, e.g. by genome browsers.
+// If f is an Indexed, its configured encoding is ignored.
+func GetComplement(f Fasta, seqName string, start, end uint64) (string, error) {
+	seq, err := getRaw(f, seqName, start, end)
+	if err != nil {
+		return "", err
+	}
+	comp := make([]byte, len(seq))
+	for i := 0; i < len(seq); i++ {
+		comp[i] = complementTable[seq[i]]
+	}
+	return unsafe.BytesToString(comp), nil
+}
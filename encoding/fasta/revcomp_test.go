@@ -0,0 +1,73 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestComplement(t *testing.T) {
+	// The full IUPAC truth table, in uppercase.
+	table := map[byte]byte{
+		'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A', 'U': 'A',
+		'R': 'Y', 'Y': 'R', 'K': 'M', 'M': 'K',
+		'B': 'V', 'V': 'B', 'D': 'H', 'H': 'D',
+		'S': 'S', 'W': 'W', 'N': 'N',
+	}
+	for b, want := range table {
+		assert.EQ(t, fasta.Complement(b), want, "%c", b)
+		assert.EQ(t, fasta.Complement(b+'a'-'A'), want+'a'-'A', "%c", b+'a'-'A')
+		if b != 'U' {
+			// Complementing twice is the identity.
+			assert.EQ(t, fasta.Complement(fasta.Complement(b)), b, "%c", b)
+		}
+	}
+	for _, b := range []byte{'-', '*', '.', 'X', 'x', 'E', 0, 15} {
+		assert.EQ(t, fasta.Complement(b), b, "%q", b)
+	}
+}
+
+func TestReverseComplement(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{"", ""},
+		{"ACGTN", "NACGT"},
+		{"acgtRYKMbvdhSWn-", "-nWSdhbvKMRYacgt"},
+		{strings.Repeat("AACGTN", 10), strings.Repeat("NACGTT", 10)},
+		{strings.Repeat("AACGtN", 10), strings.Repeat("NaCGTT", 10)},
+	} {
+		dst := make([]byte, len(tt.src))
+		fasta.ReverseComplement(dst, []byte(tt.src))
+		assert.EQ(t, string(dst), tt.want)
+
+		// In place.
+		buf := []byte(tt.src)
+		fasta.ReverseComplement(buf, buf)
+		assert.EQ(t, string(buf), tt.want)
+	}
+}
+
+func TestGetComplement(t *testing.T) {
+	indexed, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	eager, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	for _, fa := range []fasta.Fasta{indexed, eager} {
+		seq, err := fasta.GetComplement(fa, "seq1", 0, 6)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, "TgCATG")
+		seq, err = fasta.GetComplement(fa, "seq2", 6, 8)
+		assert.NoError(t, err)
+		assert.EQ(t, seq, "CA")
+		_, err = fasta.GetComplement(fa, "seq2", 0, 9)
+		assert.NotNil(t, err)
+	}
+	fa, err := fasta.New(strings.NewReader(">iupac\nRYKMbvdhSWNU-\n"))
+	assert.NoError(t, err)
+	seq, err := fasta.GetComplement(fa, "iupac", 0, 13)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "YRMKvbhdSWNA-")
+}
@@ -0,0 +1,29 @@
+package fasta
+
+import (
+	"regexp"
+)
+
+// SeqNamesMatching returns the names of the sequences of f that match re, in
+// SeqNames() order, which for an Indexed is file offset order.
+func SeqNamesMatching(f Fasta, re *regexp.Regexp) []string {
+	var names []string
+	for _, name := range f.SeqNames() {
+		if re.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GroupSeqs groups the names of the sequences of f by the key fn returns for
+// them, e.g., to shard work by contig group. Within each group, names are in
+// SeqNames() order, which for an Indexed is file offset order.
+func GroupSeqs(f Fasta, fn func(name string) string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, name := range f.SeqNames() {
+		key := fn(name)
+		groups[key] = append(groups[key], name)
+	}
+	return groups
+}
@@ -0,0 +1,35 @@
+package fasta_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestSeqNamesMatching(t *testing.T) {
+	data := ">chr2\nA\n>chr1\nA\n>chr1_alt\nA\n>chrX\nA\n>chrUn_gl1\nA\n"
+	fa, err := fasta.New(strings.NewReader(data))
+	assert.NoError(t, err)
+	assert.EQ(t, fasta.SeqNamesMatching(fa, regexp.MustCompile(`^chr[0-9]+$`)), []string{"chr2", "chr1"})
+	assert.EQ(t, fasta.SeqNamesMatching(fa, regexp.MustCompile(`_`)), []string{"chr1_alt", "chrUn_gl1"})
+	assert.EQ(t, len(fasta.SeqNamesMatching(fa, regexp.MustCompile(`^seq`))), 0)
+
+	groups := fasta.GroupSeqs(fa, func(name string) string {
+		switch {
+		case strings.Contains(name, "_"):
+			return "other"
+		case name == "chrX" || name == "chrY":
+			return "sex"
+		default:
+			return "autosome"
+		}
+	})
+	assert.EQ(t, groups, map[string][]string{
+		"autosome": {"chr2", "chr1"},
+		"sex":      {"chrX"},
+		"other":    {"chr1_alt", "chrUn_gl1"},
+	})
+}
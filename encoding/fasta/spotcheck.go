@@ -0,0 +1,83 @@
+package fasta
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// SpotCheck is a quick test of the consistency of a FASTA file and its index
+// (*.fai). For samples random positions of randomly chosen sequences, it reads
+// the line of the FASTA file that the index places the position on, and checks
+// that the byte at the position is a plausible base, i.e., a letter, '-' or
+// '*', and that the line is followed by a line terminator where the index line
+// length implies it. It returns an error describing the first inconsistency
+// found. Unlike a full validation, its cost does not depend on the size of the
+// file, so it is suitable for checking large references in CI pipelines.
+func SpotCheck(fasta io.ReadSeeker, index io.Reader, samples int) error {
+	entries, err := parseIndex(index, opts{})
+	if err != nil {
+		return err
+	}
+	nonEmpty := entries[:0]
+	for _, ent := range entries {
+		if ent.Length > 0 {
+			nonEmpty = append(nonEmpty, ent)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var buf []byte
+	for i := 0; i < samples; i++ {
+		ent := nonEmpty[rng.Intn(len(nonEmpty))]
+		pos := uint64(rng.Int63n(int64(ent.Length)))
+		line := pos / ent.LineBase
+		lineBases := ent.LineBase
+		if n := ent.Length - line*ent.LineBase; n < lineBases {
+			lineBases = n // The last line may be shorter.
+		}
+		lineOff := ent.Offset + line*ent.LineWidth
+		if n := int(lineBases + ent.LineWidth - ent.LineBase); cap(buf) < n {
+			buf = make([]byte, n)
+		} else {
+			buf = buf[:n]
+		}
+		if _, err := fasta.Seek(int64(lineOff), io.SeekStart); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(fasta, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		linePos := pos - line*ent.LineBase
+		if uint64(n) <= linePos {
+			return fmt.Errorf("%s:%d: offset %d is past the end of the file", ent.Name, pos, lineOff+linePos)
+		}
+		if b := buf[linePos]; !isPlausibleBase(b) {
+			return fmt.Errorf("%s:%d: expected a base at offset %d, found %q", ent.Name, pos, lineOff+linePos, b)
+		}
+		if n < len(buf) {
+			lastLine := line*ent.LineBase+lineBases == ent.Length
+			if lastLine && uint64(n) == lineBases {
+				continue // The file may lack a final line terminator.
+			}
+			return fmt.Errorf("%s:%d: line at offset %d is truncated", ent.Name, pos, lineOff)
+		}
+		term := buf[lineBases:]
+		for j, b := range term {
+			if b != '\n' && (b != '\r' || j == len(term)-1) {
+				return fmt.Errorf("%s:%d: expected a line terminator at offset %d, found %q",
+					ent.Name, pos, lineOff+lineBases+uint64(j), b)
+			}
+		}
+	}
+	return nil
+}
+
+// isPlausibleBase reports whether b may be a base of a FASTA sequence.
+func isPlausibleBase(b byte) bool {
+	return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || b == '-' || b == '*'
+}
@@ -0,0 +1,34 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestSpotCheck(t *testing.T) {
+	for _, tt := range []struct {
+		name, data, index string
+	}{
+		{"lf", fastaData, fastaIndex},
+		{"crlf", strings.ReplaceAll(fastaData, "\n", "\r\n"), "seq1\t12\t7\t5\t7\nseq2\t8\t49\t4\t6\n"},
+		{"no final newline", strings.TrimSuffix(fastaData, "\n"), fastaIndex},
+		{"empty sequence", ">empty\n" + fastaData, "empty\t0\t7\t0\t0\nseq1\t12\t13\t5\t6\nseq2\t8\t51\t4\t5\n"},
+	} {
+		assert.NoError(t, fasta.SpotCheck(strings.NewReader(tt.data), strings.NewReader(tt.index), 1000), tt.name)
+	}
+
+	for _, tt := range []struct {
+		name, index, err string
+	}{
+		{"shifted offset", "seq1\t12\t5\t5\t6\n", "^seq1:[0-9]+: expected a (base|line terminator) at offset"},
+		{"wrong line length", "seq1\t12\t6\t6\t7\n", "^seq1:[0-9]+: expected a (base|line terminator) at offset"},
+		{"past end of file", "seq2\t8\t54\t4\t5\n", "past the end of the file|truncated"},
+	} {
+		err := fasta.SpotCheck(strings.NewReader(fastaData), strings.NewReader(tt.index), 1000)
+		assert.Regexp(t, err, tt.err, tt.name)
+	}
+	assert.NoError(t, fasta.SpotCheck(strings.NewReader(fastaData), strings.NewReader(""), 10))
+}
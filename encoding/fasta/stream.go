@@ -0,0 +1,53 @@
+package fasta
+
+// streamChunkSize is the number of bases fetched per Get call by helpers that
+// stream through whole sequences.
+const streamChunkSize = 1 << 20
+
+// getRaw is like f.Get, but returns the original bytes of the FASTA file,
+// including case, if f is an Indexed. Otherwise it returns f.Get().
+func getRaw(f Fasta, seqName string, start, end uint64) (string, error) {
+	if ix, ok := f.(Indexed); ok {
+		return ix.GetEnc(seqName, start, end, RawASCII)
+	}
+	return f.Get(seqName, start, end)
+}
+
+// streamSeq calls fn on consecutive chunks of at most streamChunkSize bases
+// covering the whole sequence seqName, in order. start is the position of the
+// chunk within the sequence. If raw is set, chunks are fetched with getRaw,
+// otherwise with f.Get. Streaming stops early if fn returns false.
+func streamSeq(f Fasta, seqName string, raw bool, fn func(start uint64, chunk string) bool) error {
+	seqLen, err := f.Len(seqName)
+	if err != nil {
+		return err
+	}
+	return streamRange(f, seqName, 0, seqLen, raw, fn)
+}
+
+// streamRange is like streamSeq, but covers only the bases [start, end) of the
+// sequence.
+func streamRange(f Fasta, seqName string, start, end uint64, raw bool, fn func(start uint64, chunk string) bool) error {
+	for ; start < end; start += streamChunkSize {
+		chunkEnd := start + streamChunkSize
+		if chunkEnd > end {
+			chunkEnd = end
+		}
+		var (
+			chunk string
+			err   error
+		)
+		if raw {
+			chunk, err = getRaw(f, seqName, start, chunkEnd)
+		} else {
+			chunk, err = f.Get(seqName, start, chunkEnd)
+		}
+		if err != nil {
+			return err
+		}
+		if !fn(start, chunk) {
+			break
+		}
+	}
+	return nil
+}
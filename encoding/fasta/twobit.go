@@ -0,0 +1,84 @@
+package fasta
+
+import "fmt"
+
+// seqEncoder is implemented by the Fastas whose Get may return sequences in
+// the TwoBit encoding, and by the views that wrap them.
+type seqEncoder interface {
+	// seqEncoding returns the encoding in which Get returns the given
+	// sequence.
+	seqEncoding(seqName string) Encoding
+}
+
+func (f *fasta) seqEncoding(seqName string) Encoding {
+	if f.normalize != nil {
+		seqName = f.normalize(seqName)
+	}
+	if e, ok := f.seqEnc[seqName]; ok {
+		return e
+	}
+	return f.enc
+}
+
+func (v *refOrderView) seqEncoding(seqName string) Encoding {
+	return encodingOf(v.Fasta, seqName)
+}
+
+func (v *prioritizedView) seqEncoding(seqName string) Encoding {
+	return encodingOf(v.choose(seqName), seqName)
+}
+
+func (f *instrumented) seqEncoding(seqName string) Encoding {
+	return encodingOf(f.Fasta, seqName)
+}
+
+// encodingOf returns the encoding in which f.Get returns the given sequence,
+// or RawASCII if f does not report it.
+func encodingOf(f Fasta, seqName string) Encoding {
+	if s, ok := f.(seqEncoder); ok {
+		return s.seqEncoding(seqName)
+	}
+	return RawASCII
+}
+
+// checkUnpacked returns an error if f returns the given sequence in the
+// TwoBit encoding, which the named helper cannot handle since it slices the
+// results of Get by base offsets.
+func checkUnpacked(f Fasta, seqName, helper string) error {
+	if encodingOf(f, seqName) == TwoBit {
+		return fmt.Errorf("%s does not support the TwoBit encoding of sequence %s", helper, seqName)
+	}
+	return nil
+}
+
+// packTwoBit packs seq in the TwoBit encoding.
+func packTwoBit(seq string) string {
+	packed := make([]byte, (len(seq)+3)/4)
+	for i := 0; i < len(seq); i++ {
+		code := baseCode[seq[i]]
+		if code > 3 {
+			code = 0
+		}
+		packed[i/4] |= code << (6 - 2*uint(i%4))
+	}
+	return string(packed)
+}
+
+// GetOneHot returns the bases [start, end) of the given sequence one-hot
+// encoded, as a row-major (end-start)x4 matrix whose columns stand for A, C, G
+// and T, in either case. Other bases, such as 'N', have all-zero rows. If f is
+// an Indexed, the original bases are used regardless of its encoding;
+// otherwise f must use RawASCII, CleanASCII or Seq8.
+func GetOneHot(f Fasta, seqName string, start, end uint64) ([]float32, error) {
+	seq, err := getRaw(f, seqName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	oneHot := make([]float32, 4*len(seq))
+	for i := 0; i < len(seq); i++ {
+		if code := baseCode[seq[i]]; code <= 3 {
+			oneHot[4*i+int(code)] = 1
+		}
+	}
+	return oneHot, nil
+}
@@ -0,0 +1,107 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestTwoBit(t *testing.T) {
+	for _, fa := range []fasta.Fasta{
+		func() fasta.Fasta {
+			fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+				fasta.OptEncoding(fasta.TwoBit), fasta.OptPadN)
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.New(strings.NewReader(fastaData), fasta.OptEncoding(fasta.TwoBit), fasta.OptPadN)
+			assert.NoError(t, err)
+			return fa
+		}(),
+		func() fasta.Fasta {
+			fa, err := fasta.New(strings.NewReader(fastaData), fasta.OptEncoding(fasta.TwoBit), fasta.OptPadN,
+				fasta.OptIndex([]byte(fastaIndex)))
+			assert.NoError(t, err)
+			return fa
+		}(),
+	} {
+		for _, tt := range []struct {
+			start, end uint64
+			want       string
+		}{
+			{0, 4, "\x1b"},          // AcGT = 00 01 10 11
+			{0, 5, "\x1b\x00"},      // A
+			{1, 6, "\x6c\x40"},      // cGTA C = 01 10 11 00, 01
+			{10, 14, "\xb0"},        // GT, padded with NN
+			{0, 12, "\x1b\x1b\x1b"}, // whole sequence
+			{2, 3, "\x80"},          // G
+			{11, 12, "\xc0"},        // T
+		} {
+			seq, err := fa.Get("seq1", tt.start, tt.end)
+			assert.NoError(t, err)
+			assert.EQ(t, seq, tt.want, "%+v", tt)
+		}
+		l, err := fa.Len("seq1")
+		assert.NoError(t, err)
+		assert.EQ(t, l, uint64(12))
+	}
+}
+
+func TestGetOneHot(t *testing.T) {
+	fa, err := fasta.NewIndexed(strings.NewReader(">s\nAcGtN\n"), strings.NewReader("s\t5\t3\t5\t6\n"),
+		fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	oneHot, err := fasta.GetOneHot(fa, "s", 0, 5)
+	assert.NoError(t, err)
+	assert.EQ(t, oneHot, []float32{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+		0, 0, 0, 0,
+	})
+	oneHot, err = fasta.GetOneHot(fa, "s", 3, 4)
+	assert.NoError(t, err)
+	assert.EQ(t, oneHot, []float32{0, 0, 0, 1})
+	_, err = fasta.GetOneHot(fa, "s", 3, 6)
+	assert.NotNil(t, err)
+}
+
+func TestTwoBitUnsupported(t *testing.T) {
+	data := ">s\n" + strings.Repeat("ACGTTGCA", 20) + "\n>t\nACGT\n"
+	eager, err := fasta.New(strings.NewReader(data), fasta.OptEncoding(fasta.TwoBit))
+	assert.NoError(t, err)
+	indexed, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex),
+		fasta.OptSeqEncoding(map[string]fasta.Encoding{"seq1": fasta.TwoBit}))
+	assert.NoError(t, err)
+	prioritized := fasta.NewPrioritized(indexed, eager)
+	for _, tt := range []struct {
+		fa      fasta.Fasta
+		seqName string
+	}{
+		{eager, "s"},
+		{indexed, "seq1"},
+		{prioritized, "seq1"},
+		{prioritized, "t"},
+	} {
+		_, err := fasta.GetBatch(tt.fa, tt.seqName, [][2]uint64{{0, 4}, {2, 3}})
+		assert.Regexp(t, err, "GetBatch does not support the TwoBit encoding of sequence "+tt.seqName)
+		_, _, _, err = fasta.GetBatchMerged(tt.fa, tt.seqName, [][2]uint64{{0, 4}})
+		assert.Regexp(t, err, "GetBatchMerged does not support")
+		_, err = fasta.GetConcat(tt.fa, tt.seqName, []fasta.StrandedRange{{Start: 0, End: 4}})
+		assert.Regexp(t, err, "GetConcat does not support")
+		_, _, _, err = fasta.GetFlank(tt.fa, tt.seqName, 1, 1)
+		assert.Regexp(t, err, "GetFlank does not support")
+		_, _, err = fasta.GetWithGapInfo(tt.fa, tt.seqName, 0, 2, 1)
+		assert.Regexp(t, err, "GetWithGapInfo does not support")
+		_, err = fasta.AsReferenceGetter(tt.fa, []string{tt.seqName})(0, 0, 2)
+		assert.Regexp(t, err, "AsReferenceGetter does not support")
+	}
+	// Other sequences are unaffected.
+	seqs, err := fasta.GetBatch(prioritized, "seq2", [][2]uint64{{0, 4}, {6, 8}})
+	assert.NoError(t, err)
+	assert.EQ(t, seqs, []string{"ACGT", "GT"})
+}
@@ -0,0 +1,134 @@
+package fasta
+
+import (
+	"fmt"
+
+	"github.com/Schaudge/grailbase/unsafe"
+	"github.com/Schaudge/grailbio/biosimd"
+)
+
+type validateOpts struct {
+	maxPerSeq int
+}
+
+// ValidateOpt is an optional argument to ValidateAlphabet.
+type ValidateOpt func(*validateOpts)
+
+// OptMaxInvalidPerSeq caps the number of disallowed positions reported for each
+// sequence. Scanning of a sequence stops once n positions have been found. The
+// default, 0, reports every position.
+func OptMaxInvalidPerSeq(n int) ValidateOpt {
+	return func(o *validateOpts) {
+		o.maxPerSeq = n
+	}
+}
+
+// ValidateAlphabet streams every sequence in f and checks that each byte is
+// allowed, i.e., allowed[b] is true. It returns, for each sequence containing
+// at least one disallowed byte, the 0-based positions of those bytes in
+// increasing order. Sequences without disallowed bytes are omitted from the
+// result.
+//
+// The check is applied to the bytes returned by f.Get, so f should normally be
+// created with the RawASCII encoding.
+func ValidateAlphabet(f Fasta, allowed [256]bool, opts ...ValidateOpt) (map[string][]uint64, error) {
+	var o validateOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// If the allowed set includes capital ACGTN, chunks consisting only of those
+	// bytes (the common case) can be skipped with a single SIMD scan.
+	acgtnAllowed := allowed['A'] && allowed['C'] && allowed['G'] && allowed['T'] && allowed['N']
+	invalid := make(map[string][]uint64)
+	for _, seqName := range f.SeqNames() {
+		var positions []uint64
+		err := streamSeq(f, seqName, false, func(start uint64, chunk string) bool {
+			if acgtnAllowed && !biosimd.IsNonACGTNPresent(unsafe.StringToBytes(chunk)) {
+				return true
+			}
+			for i := 0; i < len(chunk); i++ {
+				if allowed[chunk[i]] {
+					continue
+				}
+				positions = append(positions, start+uint64(i))
+				if o.maxPerSeq > 0 && len(positions) >= o.maxPerSeq {
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(positions) > 0 {
+			invalid[seqName] = positions
+		}
+	}
+	return invalid, nil
+}
+
+// VerifyEncodingRoundTrip streams the given sequence and checks that
+// converting it to enc and back to ASCII is lossless, up to case, which only
+// RawASCII preserves. Seq8 and Seq8IUPAC are decoded with
+// biosimd.Seq8ToASCIIInplace, with code 0 standing for the '-' gaps of
+// Seq8IUPAC, and TwoBit is decoded to ACGT. It returns an error at the first
+// base that does not survive, e.g. an 'R' under Seq8, which comes back as 'N'.
+//
+// If f is an Indexed, its original bases are checked; otherwise f should be
+// created with the RawASCII encoding.
+func VerifyEncodingRoundTrip(f Fasta, seqName string, enc Encoding) error {
+	if enc >= EncodingLimit {
+		return fmt.Errorf("invalid encoding value: %d", enc)
+	}
+	var (
+		buf      []byte
+		mismatch error
+	)
+	err := streamSeq(f, seqName, true, func(start uint64, chunk string) bool {
+		buf = append(buf[:0], chunk...)
+		roundTrip(buf, enc)
+		for i := 0; i < len(chunk); i++ {
+			if upper(buf[i]) != upper(chunk[i]) {
+				mismatch = fmt.Errorf("sequence %s, position %d: %q becomes %q in encoding %d",
+					seqName, start+uint64(i), chunk[i], buf[i], enc)
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return mismatch
+}
+
+// roundTrip converts the ASCII bases in seq to enc and back, in place.
+func roundTrip(seq []byte, enc Encoding) {
+	switch enc {
+	case TwoBit:
+		packed := packTwoBit(unsafe.BytesToString(seq))
+		for i := range seq {
+			seq[i] = "ACGT"[packed[i/4]>>(6-2*uint(i%4))&3]
+		}
+	case Seq8, Seq8IUPAC:
+		encodeInplace(seq, enc)
+		biosimd.Seq8ToASCIIInplace(seq)
+		if enc == Seq8IUPAC {
+			for i, b := range seq {
+				if b == '=' {
+					seq[i] = '-'
+				}
+			}
+		}
+	default:
+		encodeInplace(seq, enc)
+	}
+}
+
+// upper converts b to uppercase if it is a lowercase ASCII letter.
+func upper(b byte) byte {
+	if 'a' <= b && b <= 'z' {
+		return b - ('a' - 'A')
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package fasta_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestValidateAlphabet(t *testing.T) {
+	data := `>seq1
+ACGTN
+ACXTA
+>seq2
+acgt
+>seq3
+ACGT
+`
+	var allowed [256]bool
+	for _, b := range []byte("ACGTNacgtn") {
+		allowed[b] = true
+	}
+	fa, err := fasta.New(strings.NewReader(data))
+	assert.NoError(t, err)
+	invalid, err := fasta.ValidateAlphabet(fa, allowed)
+	assert.NoError(t, err)
+	assert.EQ(t, invalid, map[string][]uint64{"seq1": {7}})
+
+	// Only allow capital letters.
+	for _, b := range []byte("acgtn") {
+		allowed[b] = false
+	}
+	invalid, err = fasta.ValidateAlphabet(fa, allowed)
+	assert.NoError(t, err)
+	assert.EQ(t, invalid, map[string][]uint64{"seq1": {7}, "seq2": {0, 1, 2, 3}})
+
+	invalid, err = fasta.ValidateAlphabet(fa, allowed, fasta.OptMaxInvalidPerSeq(2))
+	assert.NoError(t, err)
+	assert.EQ(t, invalid, map[string][]uint64{"seq1": {7}, "seq2": {0, 1}})
+}
+
+func TestVerifyEncodingRoundTrip(t *testing.T) {
+	data := ">acgt\nACGTacgt\nAC\n>n\nACGTN\nn\n>iupac\nACRYSW\nKM-BDH\nV\n>u\nACGU\n"
+	index := "acgt\t10\t6\t8\t9\nn\t6\t21\t5\t6\niupac\t13\t36\t6\t7\nu\t4\t55\t4\t5\n"
+	fa, err := fasta.NewIndexed(strings.NewReader(data), strings.NewReader(index), fasta.OptEncoding(fasta.Seq8))
+	assert.NoError(t, err)
+	eager, err := fasta.New(strings.NewReader(data))
+	assert.NoError(t, err)
+	for _, f := range []fasta.Fasta{fa, eager} {
+		for _, tt := range []struct {
+			seq  string
+			enc  fasta.Encoding
+			want string
+		}{
+			{"acgt", fasta.RawASCII, ""},
+			{"acgt", fasta.CleanASCII, ""},
+			{"acgt", fasta.Seq8, ""},
+			{"acgt", fasta.TwoBit, ""},
+			{"acgt", fasta.Seq8IUPAC, ""},
+			{"n", fasta.Seq8, ""},
+			{"n", fasta.TwoBit, `position 4: 'N' becomes 'A'`},
+			{"iupac", fasta.RawASCII, ""},
+			{"iupac", fasta.Seq8IUPAC, ""},
+			{"iupac", fasta.Seq8, `sequence iupac, position 2: 'R' becomes 'N'`},
+			{"iupac", fasta.CleanASCII, `position 2: 'R' becomes 'N'`},
+			{"u", fasta.Seq8IUPAC, `position 3: 'U' becomes 'T'`},
+		} {
+			err := fasta.VerifyEncodingRoundTrip(f, tt.seq, tt.enc)
+			if tt.want == "" {
+				assert.NoError(t, err, "%s %d", tt.seq, tt.enc)
+			} else {
+				assert.Regexp(t, err, tt.want)
+			}
+		}
+	}
+	assert.NotNil(t, fasta.VerifyEncodingRoundTrip(fa, "missing", fasta.Seq8))
+	assert.NotNil(t, fasta.VerifyEncodingRoundTrip(fa, "acgt", fasta.EncodingLimit))
+}
@@ -0,0 +1,150 @@
+package fasta
+
+import (
+	"fmt"
+	"sync"
+)
+
+// GetByIndex is like f.Get, but identifies the sequence by its 0-based index
+// in f.SeqNames(). Combined with NewWithRefOrder, refID can be the reference
+// ID of a BAM record.
+func GetByIndex(f Fasta, refID int, start, end uint64) (string, error) {
+	seqNames := f.SeqNames()
+	if refID < 0 || refID >= len(seqNames) {
+		return "", fmt.Errorf("reference ID %d out of range [0, %d)", refID, len(seqNames))
+	}
+	return f.Get(seqNames[refID], start, end)
+}
+
+type refOrderView struct {
+	Fasta
+	seqNames []string
+}
+
+// NewWithRefOrder returns a view of base whose SeqNames() returns refOrder,
+// e.g., the @SQ order of a BAM header, so that GetByIndex interprets reference
+// IDs in that order. It returns an error if any name in refOrder is not in
+// base.
+func NewWithRefOrder(base Fasta, refOrder []string) (Fasta, error) {
+	for _, seqName := range refOrder {
+		if _, err := base.Len(seqName); err != nil {
+			return nil, err
+		}
+	}
+	return &refOrderView{Fasta: base, seqNames: append([]string(nil), refOrder...)}, nil
+}
+
+// SeqNames implements Fasta.SeqNames(). Like indexedFasta.SeqNames, it
+// returns a copy.
+func (v *refOrderView) SeqNames() []string {
+	return append([]string(nil), v.seqNames...)
+}
+
+type prioritizedView struct {
+	primary, secondary Fasta
+	seqNames           []string
+}
+
+// NewPrioritized returns a Fasta holding the sequences of both primary and
+// secondary, e.g., a host and a pathogen reference. Get and Len use primary
+// for the sequences it has, as reported by its Len, and secondary for the
+// others, so primary takes precedence for names present in both. SeqNames
+// returns the names of primary followed by those only in secondary.
+func NewPrioritized(primary, secondary Fasta) Fasta {
+	seqNames := append([]string(nil), primary.SeqNames()...)
+	for _, seqName := range secondary.SeqNames() {
+		if _, err := primary.Len(seqName); err != nil {
+			seqNames = append(seqNames, seqName)
+		}
+	}
+	return &prioritizedView{primary: primary, secondary: secondary, seqNames: seqNames}
+}
+
+// choose returns the Fasta that serves the given sequence.
+func (v *prioritizedView) choose(seqName string) Fasta {
+	if _, err := v.primary.Len(seqName); err != nil {
+		if _, err := v.secondary.Len(seqName); err == nil {
+			return v.secondary
+		}
+	}
+	return v.primary
+}
+
+// Get implements Fasta.Get().
+func (v *prioritizedView) Get(seqName string, start, end uint64) (string, error) {
+	return v.choose(seqName).Get(seqName, start, end)
+}
+
+// Len implements Fasta.Len().
+func (v *prioritizedView) Len(seqName string) (uint64, error) {
+	return v.choose(seqName).Len(seqName)
+}
+
+// SeqNames implements Fasta.SeqNames(). Like indexedFasta.SeqNames, it
+// returns a copy.
+func (v *prioritizedView) SeqNames() []string {
+	return append([]string(nil), v.seqNames...)
+}
+
+// refGetterWindow is the minimum number of bases fetched by the functions
+// returned by AsReferenceGetter.
+const refGetterWindow = 16 << 10
+
+// AsReferenceGetter returns a function that returns the bases [start, end) of
+// the sequence with the given reference ID, e.g., to compute the MD tags of
+// BAM records without depending on this package. Reference IDs index
+// refOrder, typically the @SQ order of a BAM header; if refOrder is nil, they
+// index f.SeqNames(). The function caches a window of at least
+// refGetterWindow bases around the last range it fetched, so that queries for
+// nearby records, as in coordinate-sorted BAM files, are served without
+// calling f.Get. It returns a new slice on each call, and is thread-safe.
+func AsReferenceGetter(f Fasta, refOrder []string) func(refID int, start, end int) ([]byte, error) {
+	if refOrder == nil {
+		refOrder = f.SeqNames()
+	}
+	var (
+		mu     sync.Mutex
+		cached struct {
+			refID      int
+			start, end int
+			seq        string
+		}
+	)
+	cached.refID = -1
+	return func(refID int, start, end int) ([]byte, error) {
+		if refID < 0 || refID >= len(refOrder) {
+			return nil, fmt.Errorf("reference ID %d out of range [0, %d)", refID, len(refOrder))
+		}
+		seqName := refOrder[refID]
+		if start < 0 || end <= start {
+			return nil, fmt.Errorf("invalid range for %s: [%d, %d)", seqName, start, end)
+		}
+		if err := checkUnpacked(f, seqName, "AsReferenceGetter"); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if refID != cached.refID || start < cached.start || end > cached.end {
+			seqLen, err := f.Len(seqName)
+			if err != nil {
+				return nil, err
+			}
+			if uint64(end) > seqLen {
+				return nil, fmt.Errorf("end is past end of sequence %s: %d", seqName, seqLen)
+			}
+			windowEnd := end
+			if windowEnd-start < refGetterWindow {
+				windowEnd = start + refGetterWindow
+				if uint64(windowEnd) > seqLen {
+					windowEnd = int(seqLen)
+				}
+			}
+			seq, err := f.Get(seqName, uint64(start), uint64(windowEnd))
+			if err != nil {
+				return nil, err
+			}
+			cached.refID, cached.start, cached.end, cached.seq = refID, start, windowEnd, seq
+		}
+		return []byte(cached.seq[start-cached.start : end-cached.start]), nil
+	}
+}
@@ -0,0 +1,107 @@
+package fasta_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestRefOrder(t *testing.T) {
+	base, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	seq, err := fasta.GetByIndex(base, 0, 0, 2)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "Ac")
+
+	refOrder := []string{"seq2", "seq1"}
+	fa, err := fasta.NewWithRefOrder(base, refOrder)
+	assert.NoError(t, err)
+	// Neither refOrder nor the result of SeqNames alias the view's names.
+	refOrder[0] = "x"
+	sort.Strings(fa.SeqNames())
+	assert.EQ(t, fa.SeqNames(), []string{"seq2", "seq1"})
+	seq, err = fasta.GetByIndex(fa, 0, 0, 2)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "AC")
+	seq, err = fasta.GetByIndex(fa, 1, 1, 3)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "cG")
+	_, err = fasta.GetByIndex(fa, 2, 0, 1)
+	assert.Regexp(t, err, "out of range")
+	_, err = fasta.GetByIndex(fa, -1, 0, 1)
+	assert.Regexp(t, err, "out of range")
+
+	_, err = fasta.NewWithRefOrder(base, []string{"seq2", "seq3"})
+	assert.Regexp(t, err, "seq3")
+}
+
+func TestAsReferenceGetter(t *testing.T) {
+	seq1 := strings.Repeat("ACGTa", 10000)
+	base, err := fasta.New(strings.NewReader(fastaData + ">seq3\n" + seq1 + "\n"))
+	assert.NoError(t, err)
+	fa, stats := fasta.NewInstrumented(base)
+	get := fasta.AsReferenceGetter(fa, []string{"seq3", "seq1"})
+	for _, tt := range []struct {
+		refID, start, end int
+		want              string
+		calls             int64
+	}{
+		{0, 10, 20, seq1[10:20], 1},
+		{0, 100, 200, seq1[100:200], 1},
+		{0, 5, 10, seq1[5:10], 2},
+		{0, 49990, 50000, seq1[49990:50000], 3},
+		{0, 49995, 50000, seq1[49995:50000], 3},
+		{1, 0, 12, "AcGTACGTACGT", 4},
+		{1, 4, 6, "AC", 4},
+		{0, 0, 20000, seq1[:20000], 5},
+	} {
+		b, err := get(tt.refID, tt.start, tt.end)
+		assert.NoError(t, err)
+		assert.EQ(t, string(b), tt.want)
+		calls := stats.Calls()
+		assert.EQ(t, calls["seq1"]+calls["seq3"], tt.calls, "%+v", tt)
+	}
+
+	get = fasta.AsReferenceGetter(base, nil)
+	b, err := get(1, 2, 6)
+	assert.NoError(t, err)
+	assert.EQ(t, string(b), "GTAC")
+	_, err = get(3, 0, 1)
+	assert.Regexp(t, err, "reference ID 3 out of range")
+	_, err = get(0, -1, 1)
+	assert.Regexp(t, err, "invalid range")
+	_, err = get(0, 2, 13)
+	assert.Regexp(t, err, "end is past end of sequence seq1")
+}
+
+func TestPrioritized(t *testing.T) {
+	primary, err := fasta.New(strings.NewReader(fastaData))
+	assert.NoError(t, err)
+	secondary, err := fasta.New(strings.NewReader(">virus\nTTTT\n>seq2\nGGG\n"))
+	assert.NoError(t, err)
+	fa := fasta.NewPrioritized(primary, secondary)
+	fa.SeqNames()[0] = "x"
+	assert.EQ(t, fa.SeqNames(), []string{"seq1", "seq2", "virus"})
+
+	seq, err := fa.Get("seq2", 0, 4)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGT")
+	n, err := fa.Len("seq2")
+	assert.NoError(t, err)
+	assert.EQ(t, n, uint64(8))
+	seq, err = fa.Get("virus", 1, 3)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "TT")
+	n, err = fa.Len("virus")
+	assert.NoError(t, err)
+	assert.EQ(t, n, uint64(4))
+
+	// Range errors are not hidden by the fallback.
+	_, err = fa.Get("seq2", 0, 9)
+	assert.NotNil(t, err)
+	_, err = fa.Len("seq3")
+	assert.Regexp(t, err, "seq3")
+}
@@ -0,0 +1,78 @@
+package fasta
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// WatchIndex implements Indexed.WatchIndex().
+func (f *indexedFasta) WatchIndex(path string, interval time.Duration, onError func(error)) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("WatchIndex: interval must be positive, got %v", interval)
+	}
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+	last, err := os.Stat(path)
+	if err != nil {
+		report(err)
+	}
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				report(err)
+				continue
+			}
+			if last != nil && os.SameFile(info, last) &&
+				info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			// A failed reload is reported once, not retried until the next
+			// change.
+			last = info
+			if err := f.reloadIndex(path); err != nil {
+				report(fmt.Errorf("reloading index %s: %w", path, err))
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+// reloadIndex parses the index file at path and makes it the index of f.
+func (f *indexedFasta) reloadIndex(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	entries, err := parseIndex(file, f.opts)
+	if err != nil {
+		return err
+	}
+	// The encodings set with OptSeqEncoding do not depend on the index, so
+	// only their check is needed.
+	idx, _, err := newCheckedSeqIndex(entries, f.opts)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.index.Store(idx)
+	// Cached sequences need not match the new index.
+	f.cache = nil
+	return nil
+}
@@ -0,0 +1,178 @@
+package fasta_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil"
+	"github.com/grailbio/testutil/assert"
+)
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchIndex(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t, "", "")
+	defer cleanup()
+	path := filepath.Join(dir, "test.fa.fai")
+	// replace atomically replaces the index file, as when rotating references,
+	// so that the watcher never sees a partially written file.
+	replace := func(index string) {
+		tmp := path + ".tmp"
+		assert.NoError(t, os.WriteFile(tmp, []byte(index), 0644))
+		assert.NoError(t, os.Rename(tmp, path))
+	}
+	replace(fastaIndex)
+
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	assert.NoError(t, fa.Prefetch("seq1"))
+	errs := make(chan error, 10)
+	stop, err := fa.WatchIndex(path, time.Millisecond, func(err error) { errs <- err })
+	assert.NoError(t, err)
+	defer stop()
+
+	// Rename seq2.
+	replace(strings.Replace(fastaIndex, "seq2", "chr2", 1))
+	waitFor(t, func() bool { return fa.SeqNames()[1] == "chr2" })
+	seq, err := fa.Get("chr2", 0, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGT")
+	_, err = fa.Get("seq2", 0, 8)
+	assert.Regexp(t, err, "sequence not found")
+	seq, err = fa.Get("seq1", 0, 12)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "AcGTACGTACGT")
+
+	// A malformed index is reported, and the current one stays in place.
+	replace("chr2\tx\n")
+	select {
+	case err := <-errs:
+		assert.Regexp(t, err, "reloading index .*test.fa.fai")
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload error not reported")
+	}
+	seq, err = fa.Get("chr2", 4, 8)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGT")
+
+	_, err = fa.WatchIndex(path, 0, nil)
+	assert.Regexp(t, err, "interval must be positive")
+
+	stop()
+	stop()
+	replace(fastaIndex)
+	time.Sleep(20 * time.Millisecond)
+	assert.EQ(t, fa.SeqNames(), []string{"seq1", "chr2"})
+}
+
+func TestWatchIndexConcurrentReads(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t, "", "")
+	defer cleanup()
+	path := filepath.Join(dir, "test.fa.fai")
+	indexes := []string{fastaIndex, strings.Replace(fastaIndex, "seq2", "chr2", 1)}
+	assert.NoError(t, os.WriteFile(path, []byte(indexes[0]), 0644))
+
+	fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex))
+	assert.NoError(t, err)
+	stop, err := fa.WatchIndex(path, time.Millisecond, func(err error) { t.Error(err) })
+	assert.NoError(t, err)
+	defer stop()
+
+	var (
+		wg   sync.WaitGroup
+		done = make(chan struct{})
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				names := fa.SeqNames()
+				if len(names) != 2 || names[0] != "seq1" || (names[1] != "seq2" && names[1] != "chr2") {
+					t.Errorf("inconsistent names %v", names)
+					return
+				}
+				// Callers may modify the result.
+				names[0], names[1] = names[1], "x"
+				if order := fa.IndexFileOrder(); order[0] != "seq1" {
+					t.Errorf("inconsistent index file order %v", order)
+					return
+				}
+				// The second sequence may have been renamed since.
+				if n, err := fa.Len(names[0]); err == nil && n != 8 {
+					t.Errorf("length of %s is %d", names[0], n)
+					return
+				}
+				if seq, err := fa.Get("seq1", 0, 12); err != nil || seq != "AcGTACGTACGT" {
+					t.Errorf("got %q, %v", seq, err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 20; i++ {
+		tmp := path + ".tmp"
+		assert.NoError(t, os.WriteFile(tmp, []byte(indexes[i%2]), 0644))
+		assert.NoError(t, os.Rename(tmp, path))
+		want := "seq2"
+		if i%2 == 1 {
+			want = "chr2"
+		}
+		waitFor(t, func() bool { return fa.SeqNames()[1] == want })
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestWatchIndexChecksOpts(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t, "", "")
+	defer cleanup()
+	path := filepath.Join(dir, "test.fa.fai")
+	assert.NoError(t, os.WriteFile(path, []byte(fastaIndex), 0644))
+
+	for _, opt := range []fasta.Opt{
+		fasta.OptNameMapFile(strings.NewReader("seq2\tchr2\n")),
+		fasta.OptSeqEncoding(map[string]fasta.Encoding{"seq2": fasta.Seq8}),
+	} {
+		fa, err := fasta.NewIndexed(strings.NewReader(fastaData), strings.NewReader(fastaIndex), opt)
+		assert.NoError(t, err)
+		errs := make(chan error, 10)
+		stop, err := fa.WatchIndex(path, time.Millisecond, func(err error) { errs <- err })
+		assert.NoError(t, err)
+
+		// An index that drops a sequence named by the options is rejected.
+		tmp := path + ".tmp"
+		assert.NoError(t, os.WriteFile(tmp, []byte(strings.Replace(fastaIndex, "seq2", "seq3", 1)), 0644))
+		assert.NoError(t, os.Rename(tmp, path))
+		select {
+		case err := <-errs:
+			assert.Regexp(t, err, "sequence not found: seq2")
+		case <-time.After(5 * time.Second):
+			t.Fatal("reload error not reported")
+		}
+		stop()
+		n, err := fa.Len("seq2")
+		assert.NoError(t, err)
+		assert.EQ(t, n, uint64(8))
+		assert.NoError(t, os.WriteFile(path, []byte(fastaIndex), 0644))
+	}
+}
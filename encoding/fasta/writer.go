@@ -0,0 +1,217 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Schaudge/grailbase/tsv"
+	"github.com/Schaudge/grailbase/unsafe"
+)
+
+type writerOpts struct {
+	terminator []byte
+}
+
+// WriterOpt is an optional argument to NewWriter.
+type WriterOpt func(*writerOpts)
+
+// OptLineTerminator sets the byte sequence that ends each line written by a
+// Writer. It must be "\n" (the default) or "\r\n".
+func OptLineTerminator(t []byte) WriterOpt {
+	return func(o *writerOpts) {
+		o.terminator = t
+	}
+}
+
+// Writer writes FASTA records with a fixed line width, and records the index
+// (*.fai) of the data written so far.
+type Writer struct {
+	w          *bufio.Writer
+	lineWidth  int
+	terminator []byte
+	entries    []IndexEntry
+	names      map[string]bool
+	off        int64 // number of bytes written so far.
+
+	inSeq bool       // true between startSeq and endSeq.
+	cur   IndexEntry // index entry of the record being written.
+	col   int        // number of bases on the current line.
+}
+
+// NewWriter creates a Writer that writes to w, wrapping sequences so that each
+// line holds at most lineWidth bases. Call Flush once done.
+func NewWriter(w io.Writer, lineWidth int, opts ...WriterOpt) (*Writer, error) {
+	o := writerOpts{terminator: []byte{'\n'}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if lineWidth <= 0 {
+		return nil, fmt.Errorf("line width must be positive: %d", lineWidth)
+	}
+	if !bytes.Equal(o.terminator, []byte("\n")) && !bytes.Equal(o.terminator, []byte("\r\n")) {
+		return nil, fmt.Errorf("unsupported line terminator: %q", o.terminator)
+	}
+	return &Writer{
+		w:          bufio.NewWriter(w),
+		lineWidth:  lineWidth,
+		terminator: append([]byte(nil), o.terminator...),
+		names:      make(map[string]bool),
+	}, nil
+}
+
+// WriteSeq writes a record with the given name and sequence.
+func (w *Writer) WriteSeq(seqName, seq string) error {
+	if err := w.startSeq(seqName); err != nil {
+		return err
+	}
+	if err := w.writeBases(seq); err != nil {
+		return err
+	}
+	return w.endSeq()
+}
+
+// WriteSeqStream starts a record with the given name, and returns a writer of
+// its bases, for sequences too large to hold in memory or whose length is not
+// known up front. Closing the writer ends the record and adds it to the index;
+// no other record may be started until then. Errors, such as an invalid name,
+// are returned by the first Write or by Close.
+func (w *Writer) WriteSeqStream(seqName string) io.WriteCloser {
+	s := &seqStream{w: w}
+	if s.err = w.startSeq(seqName); s.err == nil {
+		s.open = true
+	}
+	return s
+}
+
+// seqStream implements Writer.WriteSeqStream.
+type seqStream struct {
+	w    *Writer
+	err  error
+	open bool
+}
+
+// Write implements io.Writer. p must hold only bases, without line
+// terminators.
+func (s *seqStream) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if !s.open {
+		return 0, fmt.Errorf("write to closed sequence stream")
+	}
+	if err := s.w.writeBases(unsafe.BytesToString(p)); err != nil {
+		s.err = err
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer.
+func (s *seqStream) Close() error {
+	if !s.open {
+		return s.err
+	}
+	s.open = false
+	if err := s.w.endSeq(); err != nil && s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
+
+// startSeq writes the header line of a new record.
+func (w *Writer) startSeq(seqName string) error {
+	if w.inSeq {
+		return fmt.Errorf("cannot start sequence %s while writing %s", seqName, w.cur.Name)
+	}
+	if seqName == "" || strings.ContainsAny(seqName, " \t\r\n") {
+		return fmt.Errorf("invalid sequence name: %q", seqName)
+	}
+	if w.names[seqName] {
+		return fmt.Errorf("duplicate sequence name: %s", seqName)
+	}
+	if err := w.write(">" + seqName); err != nil {
+		return err
+	}
+	if err := w.write(string(w.terminator)); err != nil {
+		return err
+	}
+	w.names[seqName] = true
+	w.cur = IndexEntry{
+		Name:      seqName,
+		Offset:    uint64(w.off),
+		LineBase:  uint64(w.lineWidth),
+		LineWidth: uint64(w.lineWidth + len(w.terminator)),
+	}
+	w.inSeq, w.col = true, 0
+	return nil
+}
+
+// writeBases appends bases to the current record, wrapping lines as needed.
+func (w *Writer) writeBases(seq string) error {
+	for len(seq) > 0 {
+		if w.col == w.lineWidth {
+			if err := w.write(string(w.terminator)); err != nil {
+				return err
+			}
+			w.col = 0
+		}
+		n := w.lineWidth - w.col
+		if n > len(seq) {
+			n = len(seq)
+		}
+		if err := w.write(seq[:n]); err != nil {
+			return err
+		}
+		w.col += n
+		w.cur.Length += uint64(n)
+		seq = seq[n:]
+	}
+	return nil
+}
+
+// endSeq terminates the last line of the current record, and adds it to the
+// index.
+func (w *Writer) endSeq() error {
+	w.inSeq = false
+	w.entries = append(w.entries, w.cur)
+	if w.col == 0 {
+		return nil
+	}
+	return w.write(string(w.terminator))
+}
+
+func (w *Writer) write(s string) error {
+	n, err := w.w.WriteString(s)
+	w.off += int64(n)
+	return err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Index returns the index entries of the records written so far.
+func (w *Writer) Index() []IndexEntry {
+	return append([]IndexEntry(nil), w.entries...)
+}
+
+// WriteIndex writes the index (*.fai) of the records written so far to out.
+// The index can be passed to NewIndexed along with the written FASTA data.
+func (w *Writer) WriteIndex(out io.Writer) error {
+	tsvOut := tsv.NewWriter(out)
+	for _, ent := range w.entries {
+		tsvOut.WriteString(ent.Name)
+		tsvOut.WriteUint64(ent.Length)
+		tsvOut.WriteUint64(ent.Offset)
+		tsvOut.WriteUint64(ent.LineBase)
+		tsvOut.WriteUint64(ent.LineWidth)
+		if err := tsvOut.EndLine(); err != nil {
+			return err
+		}
+	}
+	return tsvOut.Flush()
+}
@@ -0,0 +1,106 @@
+package fasta_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+)
+
+func TestWriter(t *testing.T) {
+	seqs := []struct{ name, seq string }{
+		{"seq1", "ACGTACGTACGT"},
+		{"seq2", "ACGTA"},
+		{"seq3", "ACGTAC"},
+	}
+	for _, tt := range []struct {
+		terminator string
+		want       string
+	}{
+		{"\n", ">seq1\nACGTA\nCGTAC\nGT\n>seq2\nACGTA\n>seq3\nACGTA\nC\n"},
+		{"\r\n", ">seq1\r\nACGTA\r\nCGTAC\r\nGT\r\n>seq2\r\nACGTA\r\n>seq3\r\nACGTA\r\nC\r\n"},
+	} {
+		var out, index bytes.Buffer
+		w, err := fasta.NewWriter(&out, 5, fasta.OptLineTerminator([]byte(tt.terminator)))
+		assert.NoError(t, err)
+		for _, s := range seqs {
+			assert.NoError(t, w.WriteSeq(s.name, s.seq))
+		}
+		assert.NoError(t, w.Flush())
+		assert.NoError(t, w.WriteIndex(&index))
+		assert.EQ(t, out.String(), tt.want)
+
+		// The index must match the one generated from the output.
+		var generated bytes.Buffer
+		assert.NoError(t, fasta.GenerateIndex(&generated, bytes.NewReader(out.Bytes())))
+		assert.EQ(t, index.String(), generated.String())
+
+		fa, err := fasta.NewIndexed(bytes.NewReader(out.Bytes()), bytes.NewReader(index.Bytes()))
+		assert.NoError(t, err)
+		for _, s := range seqs {
+			for start := 0; start < len(s.seq); start++ {
+				for end := start + 1; end <= len(s.seq); end++ {
+					got, err := fa.Get(s.name, uint64(start), uint64(end))
+					assert.NoError(t, err)
+					assert.EQ(t, got, s.seq[start:end])
+				}
+			}
+		}
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	var out bytes.Buffer
+	_, err := fasta.NewWriter(&out, 5, fasta.OptLineTerminator([]byte("\r")))
+	assert.Regexp(t, err, "unsupported line terminator")
+	_, err = fasta.NewWriter(&out, 0)
+	assert.Regexp(t, err, "line width must be positive")
+
+	w, err := fasta.NewWriter(&out, 5)
+	assert.NoError(t, err)
+	assert.NoError(t, w.WriteSeq("a", "ACGT"))
+	assert.Regexp(t, w.WriteSeq("a", "ACGT"), "duplicate sequence name")
+	assert.Regexp(t, w.WriteSeq("b c", "ACGT"), "invalid sequence name")
+	assert.EQ(t, len(w.Index()), 1)
+	assert.NoError(t, w.Flush())
+	assert.True(t, strings.HasPrefix(out.String(), ">a\n"))
+}
+
+func TestWriteSeqStream(t *testing.T) {
+	var out, index bytes.Buffer
+	w, err := fasta.NewWriter(&out, 5)
+	assert.NoError(t, err)
+	assert.NoError(t, w.WriteSeq("seq1", "ACG"))
+	s := w.WriteSeqStream("seq2")
+	for _, chunk := range []string{"AC", "GTACG", "", "TACGTA", "C"} {
+		n, err := s.Write([]byte(chunk))
+		assert.NoError(t, err)
+		assert.EQ(t, n, len(chunk))
+	}
+	// The record is only indexed once the stream is closed.
+	assert.EQ(t, len(w.Index()), 1)
+	assert.Regexp(t, w.WriteSeq("seq3", "A"), "cannot start sequence seq3 while writing seq2")
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Close())
+	_, err = s.Write([]byte("A"))
+	assert.Regexp(t, err, "closed sequence stream")
+	assert.NoError(t, w.WriteSeq("seq3", "A"))
+
+	s = w.WriteSeqStream("seq1")
+	_, err = s.Write([]byte("A"))
+	assert.Regexp(t, err, "duplicate sequence name: seq1")
+	assert.Regexp(t, s.Close(), "duplicate sequence name: seq1")
+	assert.NoError(t, w.WriteSeqStream("empty").Close())
+
+	assert.NoError(t, w.Flush())
+	assert.NoError(t, w.WriteIndex(&index))
+	assert.EQ(t, out.String(), ">seq1\nACG\n>seq2\nACGTA\nCGTAC\nGTAC\n>seq3\nA\n>empty\n")
+	assert.EQ(t, index.String(), "seq1\t3\t6\t5\t6\nseq2\t14\t16\t5\t6\nseq3\t1\t39\t5\t6\nempty\t0\t48\t5\t6\n")
+	fa, err := fasta.NewIndexed(bytes.NewReader(out.Bytes()), bytes.NewReader(index.Bytes()))
+	assert.NoError(t, err)
+	seq, err := fa.Get("seq2", 0, 14)
+	assert.NoError(t, err)
+	assert.EQ(t, seq, "ACGTACGTACGTAC")
+}
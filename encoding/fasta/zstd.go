@@ -0,0 +1,121 @@
+package fasta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// zstdSeekableMagic ends the seek table of a seekable zstd file.
+	zstdSeekableMagic = 0x8F92EAB1
+	// zstdSeekTableFooterSize is the size of the seek table footer.
+	zstdSeekTableFooterSize = 9
+)
+
+// readZstdSeekTable parses the seek table of a file in the zstd seekable
+// format
+// (https://github.com/facebook/zstd/blob/dev/contrib/seekable_format/zstd_seekable_compression_format.md).
+// r may hold the whole skippable frame containing the table, or just its
+// contents.
+func readZstdSeekTable(r io.Reader) ([]compressedBlock, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading zstd seek table: %v", err)
+	}
+	if len(data) < zstdSeekTableFooterSize {
+		return nil, fmt.Errorf("zstd seek table is truncated")
+	}
+	footer := data[len(data)-zstdSeekTableFooterSize:]
+	if binary.LittleEndian.Uint32(footer[5:]) != zstdSeekableMagic {
+		return nil, fmt.Errorf("invalid zstd seek table: bad magic number")
+	}
+	nFrames := uint64(binary.LittleEndian.Uint32(footer))
+	descriptor := footer[4]
+	if descriptor&0x7c != 0 {
+		return nil, fmt.Errorf("invalid zstd seek table: reserved descriptor bits are set")
+	}
+	entrySize := uint64(8)
+	if descriptor&0x80 != 0 {
+		entrySize = 12 // Entries include a checksum.
+	}
+	if nFrames*entrySize > uint64(len(data)-zstdSeekTableFooterSize) {
+		return nil, fmt.Errorf("zstd seek table is truncated: %d frames", nFrames)
+	}
+	entries := data[len(data)-zstdSeekTableFooterSize-int(nFrames*entrySize):]
+	var (
+		blocks     []compressedBlock
+		cOff, uOff uint64
+	)
+	for i := uint64(0); i < nFrames; i++ {
+		e := entries[i*entrySize:]
+		cSize := uint64(binary.LittleEndian.Uint32(e))
+		uSize := uint64(binary.LittleEndian.Uint32(e[4:]))
+		if uSize > 0 {
+			blocks = append(blocks, compressedBlock{cOff: cOff, uOff: uOff, cSize: cSize})
+		}
+		cOff += cSize
+		uOff += uSize
+	}
+	return blocks, nil
+}
+
+// zstdDecoder decompresses the frames of a seekable zstd file for a
+// blockReader.
+type zstdDecoder struct {
+	r   io.ReadSeeker
+	dec *zstd.Decoder
+	raw []byte // temp for compressed frame contents.
+}
+
+// decode decompresses the frame b into dst.
+func (d *zstdDecoder) decode(b compressedBlock, dst []byte) ([]byte, error) {
+	if _, err := d.r.Seek(int64(b.cOff), io.SeekStart); err != nil {
+		return nil, err
+	}
+	if uint64(cap(d.raw)) < b.cSize {
+		d.raw = make([]byte, b.cSize)
+	}
+	d.raw = d.raw[:b.cSize]
+	if _, err := io.ReadFull(d.r, d.raw); err != nil {
+		return nil, fmt.Errorf("zstd frame at offset %d: %v", b.cOff, err)
+	}
+	data, err := d.dec.DecodeAll(d.raw, dst[:0])
+	if err != nil {
+		return nil, fmt.Errorf("zstd frame at offset %d: %v", b.cOff, err)
+	}
+	return data, nil
+}
+
+// NewIndexedZstd creates a new Fasta like NewIndexed, for a FASTA file
+// compressed in the zstd seekable format, i.e., as a sequence of independent
+// zstd frames. seekTable holds the seek table listing the sizes of the frames,
+// which is stored in a skippable frame at the end of such files; it may be
+// read from the end of fasta or from a separate file. fai is the index of the
+// uncompressed FASTA. Each Get decompresses the frames covering the requested
+// range, keeping the last one in memory. Close releases the zstd decoder.
+func NewIndexedZstd(fasta io.ReadSeeker, fai io.Reader, seekTable io.Reader, opts ...Opt) (Indexed, error) {
+	blocks, err := readZstdSeekTable(seekTable)
+	if err != nil {
+		return nil, err
+	}
+	parsedOpts := makeOpts(opts...)
+	entries, err := parseIndex(fai, parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	d := &zstdDecoder{r: fasta, dec: dec}
+	f, err := newLazyIndexed(newBlockReader(blocks, d.decode), entries, parsedOpts)
+	if err != nil {
+		dec.Close()
+		return nil, err
+	}
+	f.release = dec.Close
+	return f, nil
+}
@@ -0,0 +1,82 @@
+package fasta_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Schaudge/grailbio/encoding/fasta"
+	"github.com/grailbio/testutil/assert"
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeSeekableZstd compresses data as a seekable zstd file with frames of
+// frameSize uncompressed bytes, and returns it along with its seek table.
+func writeSeekableZstd(t *testing.T, data string, frameSize int, checksums bool) (file, seekTable []byte) {
+	enc, err := zstd.NewWriter(nil)
+	assert.NoError(t, err)
+	var out, entries bytes.Buffer
+	nFrames := 0
+	for start := 0; start < len(data); start += frameSize {
+		end := start + frameSize
+		if end > len(data) {
+			end = len(data)
+		}
+		frame := enc.EncodeAll([]byte(data[start:end]), nil)
+		out.Write(frame)
+		assert.NoError(t, binary.Write(&entries, binary.LittleEndian, []uint32{uint32(len(frame)), uint32(end - start)}))
+		if checksums {
+			assert.NoError(t, binary.Write(&entries, binary.LittleEndian, uint32(0)))
+		}
+		nFrames++
+	}
+	var table bytes.Buffer
+	descriptor := byte(0)
+	if checksums {
+		descriptor = 0x80
+	}
+	assert.NoError(t, binary.Write(&table, binary.LittleEndian, []uint32{0x184D2A5E, uint32(entries.Len() + 9)}))
+	table.Write(entries.Bytes())
+	assert.NoError(t, binary.Write(&table, binary.LittleEndian, uint32(nFrames)))
+	table.WriteByte(descriptor)
+	assert.NoError(t, binary.Write(&table, binary.LittleEndian, uint32(0x8F92EAB1)))
+	out.Write(table.Bytes())
+	return out.Bytes(), table.Bytes()
+}
+
+func TestIndexedZstd(t *testing.T) {
+	seq := strings.Repeat("ACGTTGCAAC", 1000)
+	data := ">s\n" + seq + "\n" + fastaData
+	var index bytes.Buffer
+	assert.NoError(t, fasta.GenerateIndex(&index, strings.NewReader(data)))
+	for _, checksums := range []bool{false, true} {
+		file, seekTable := writeSeekableZstd(t, data, 1000, checksums)
+		fa, err := fasta.NewIndexedZstd(bytes.NewReader(file), bytes.NewReader(index.Bytes()), bytes.NewReader(seekTable))
+		assert.NoError(t, err)
+		for _, r := range [][2]uint64{{0, 10}, {990, 1010}, {0, 10000}, {5000, 7500}, {9999, 10000}} {
+			got, err := fa.Get("s", r[0], r[1])
+			assert.NoError(t, err)
+			assert.EQ(t, got, seq[r[0]:r[1]])
+		}
+		got, err := fa.Get("seq2", 0, 8)
+		assert.NoError(t, err)
+		assert.EQ(t, got, "ACGTACGT")
+	}
+
+	// Close releases the decoder, along with its goroutines.
+	file, seekTable := writeSeekableZstd(t, data, 1000, false)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		fa, err := fasta.NewIndexedZstd(bytes.NewReader(file), bytes.NewReader(index.Bytes()), bytes.NewReader(seekTable))
+		assert.NoError(t, err)
+		_, err = fa.Get("s", 0, 10)
+		assert.NoError(t, err)
+		assert.NoError(t, fa.Close())
+	}
+	waitFor(t, func() bool { return runtime.NumGoroutine() < before+10 })
+
+	_, err := fasta.NewIndexedZstd(bytes.NewReader(nil), bytes.NewReader(index.Bytes()), strings.NewReader("garbage data"))
+	assert.Regexp(t, err, "bad magic number")
+}